@@ -1,9 +1,11 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"sync"
 
@@ -24,10 +26,17 @@ type Server struct {
 	watcher      *watcher.FileWatcher
 	watcherMutex sync.Mutex
 	wsClients    map[*websocket.Conn]bool
+	wsMutex      sync.Mutex // Protegge wsClients e serializza le scritture sulle connessioni
 	wsUpgrader   websocket.Upgrader
 	port         int
+	parsers      map[string]*parser.IncrementalParser // Parser incrementali per file o buffer
+	parserOrder  []string                             // Chiavi di parsers, dalla meno usata di recente
+	parsersMutex sync.Mutex
 }
 
+// maxCachedParsers limita i parser incrementali in cache; oltre si scarta il meno usato di recente
+const maxCachedParsers = 32
+
 // ServerConfig configurazione del server
 type ServerConfig struct {
 	Port         int
@@ -58,6 +67,7 @@ func NewServer(config ServerConfig) *Server {
 		router:    router,
 		compiler:  config.Compiler,
 		wsClients: make(map[*websocket.Conn]bool),
+		parsers:   make(map[string]*parser.IncrementalParser),
 		wsUpgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -81,6 +91,9 @@ func (s *Server) setupRoutes() {
 		api.POST("/story/parse", s.parseStory)
 		api.POST("/story/compile", s.compileStory)
 		api.POST("/story/validate", s.validateStory)
+		api.POST("/story/export/twine", s.exportTwineArchive)
+		api.POST("/story/convert/twee1", s.convertTwee1)
+		api.POST("/story/edit", s.editStory)
 
 		// Passage endpoints
 		api.GET("/story/:file/passages", s.getPassages)
@@ -117,6 +130,89 @@ func (s *Server) Start() error {
 // Handlers
 // ============================================
 
+// StorySource indica da dove leggere la storia in una richiesta
+// file_path (file singolo) e paths (file e directory di progetto) possono essere combinati;
+// in alternativa content contiene il sorgente .twee (es. un buffer non salvato dell'editor)
+type StorySource struct {
+	FilePath string   `json:"file_path"`
+	Paths    []string `json:"paths"`
+	Content  string   `json:"content"`
+	Name     string   `json:"name"` // Nome usato nelle posizioni per content (default "buffer.twee")
+}
+
+// sources restituisce i path indicati nella richiesta
+func (src StorySource) sources() ([]string, error) {
+	sources := []string{}
+	if src.FilePath != "" {
+		sources = append(sources, src.FilePath)
+	}
+	sources = append(sources, src.Paths...)
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("specificare file_path, paths o content")
+	}
+	return sources, nil
+}
+
+// name restituisce il nome del sorgente in memoria
+func (src StorySource) name() string {
+	if src.Name != "" {
+		return src.Name
+	}
+	return "buffer.twee"
+}
+
+// loadStory carica la storia dal contenuto, da un file singolo o da un progetto multi-file
+// I file .html pubblicati da Twine 2 vengono importati; i file .twee singoli passano
+// dal parser incrementale in cache, che riparsa solo i passaggi cambiati dall'ultima richiesta
+func (s *Server) loadStory(src StorySource) (*parser.Story, error) {
+	if src.Content != "" {
+		return parser.ParseString(src.Content, src.name())
+	}
+
+	if len(src.Paths) == 0 && src.FilePath != "" {
+		if parser.IsTwineHTMLFile(src.FilePath) {
+			return parser.NewTwineImporter(src.FilePath).ImportStory()
+		}
+
+		s.parsersMutex.Lock()
+		defer s.parsersMutex.Unlock()
+		story, _, err := s.incrementalParser(src.FilePath).Reload()
+		return story, err
+	}
+
+	sources, err := src.sources()
+	if err != nil {
+		return nil, err
+	}
+	return parser.NewProjectLoader(sources...).Load()
+}
+
+// incrementalParser restituisce il parser incrementale in cache per la chiave indicata
+// La chiave è il path del file o il nome del buffer; va chiamato con parsersMutex acquisito
+func (s *Server) incrementalParser(key string) *parser.IncrementalParser {
+	ip, exists := s.parsers[key]
+	if !exists {
+		ip = parser.NewIncrementalParser(key)
+		s.parsers[key] = ip
+	}
+
+	// Sposta la chiave in fondo: è la più usata di recente
+	for i, cached := range s.parserOrder {
+		if cached == key {
+			s.parserOrder = append(s.parserOrder[:i], s.parserOrder[i+1:]...)
+			break
+		}
+	}
+	s.parserOrder = append(s.parserOrder, key)
+
+	for len(s.parserOrder) > maxCachedParsers {
+		delete(s.parsers, s.parserOrder[0])
+		s.parserOrder = s.parserOrder[1:]
+	}
+	return ip
+}
+
 // healthCheck verifica lo stato del server
 func (s *Server) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -127,7 +223,7 @@ func (s *Server) healthCheck(c *gin.Context) {
 
 // ValidateStoryRequest richiesta di validazione
 type ValidateStoryRequest struct {
-	FilePath string `json:"file_path" binding:"required"`
+	StorySource
 }
 
 // validateStory valida un file .twee
@@ -138,15 +234,30 @@ func (s *Server) validateStory(c *gin.Context) {
 		return
 	}
 
-	tweeParser := parser.NewTweeParser(req.FilePath)
-	validation := tweeParser.Validate()
+	if req.Content != "" {
+		c.JSON(http.StatusOK, parser.ValidateString(req.Content, req.name()))
+		return
+	}
+
+	sources, err := req.sources()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var validation *parser.ValidationResult
+	if len(sources) == 1 && parser.IsTwineHTMLFile(sources[0]) {
+		validation = parser.NewTwineImporter(sources[0]).Validate()
+	} else {
+		validation = parser.NewProjectLoader(sources...).Validate()
+	}
 
 	c.JSON(http.StatusOK, validation)
 }
 
 // ParseStoryRequest richiesta di parsing
 type ParseStoryRequest struct {
-	FilePath string `json:"file_path" binding:"required"`
+	StorySource
 }
 
 // parseStory parsa un file .twee
@@ -158,8 +269,7 @@ func (s *Server) parseStory(c *gin.Context) {
 	}
 
 	// Parse il file
-	tweeParser := parser.NewTweeParser(req.FilePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(req.StorySource)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -179,31 +289,44 @@ func (s *Server) parseStory(c *gin.Context) {
 	enrichedPassages := make(map[string]interface{})
 	for title, passage := range story.Passages {
 		enrichedPassages[title] = gin.H{
-			"title":     passage.Title,
-			"tags":      passage.Tags,
-			"content":   passage.Content,
-			"links":     storyFormat.ParseLinks(passage.Content),
-			"variables": storyFormat.ParseVariables(passage.Content),
-			"preview":   storyFormat.StripCode(passage.Content),
+			"title":      passage.Title,
+			"kind":       passage.Kind(),
+			"tags":       passage.Tags,
+			"content":    passage.Content,
+			"links":      storyFormat.ParseLinks(passage.Content),
+			"variables":  storyFormat.ParseVariables(passage.Content),
+			"preview":    storyFormat.StripCode(passage.Content),
+			"position":   passage.Position,
+			"size":       passage.Size,
+			"metadata":   passage.Metadata,
+			"file":       passage.File,
+			"header":     passage.Header,
+			"body_start": passage.BodyStart,
+			"body_end":   passage.BodyEnd,
+			"link_refs":  storyFormat.FindLinks(passage.Content),
+			"macros":     storyFormat.FindMacros(passage.Content),
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"story": gin.H{
-			"title":    story.Title,
-			"format":   story.Format,
-			"passages": enrichedPassages,
-			"count":    len(story.Passages),
+			"title":      story.Title,
+			"format":     story.Format,
+			"story_data": story.StoryData,
+			"dialect":    story.Dialect,
+			"passages":   enrichedPassages,
+			"order":      story.Order,
+			"count":      len(story.Passages),
 		},
 	})
 }
 
 // CompileStoryRequest richiesta di compilazione
 type CompileStoryRequest struct {
-	FilePath string `json:"file_path" binding:"required"`
-	Format   string `json:"format"`
-	Output   string `json:"output"`
+	StorySource
+	Format string `json:"format"`
+	Output string `json:"output"`
 }
 
 // compileStory compila un file .twee
@@ -222,8 +345,20 @@ func (s *Server) compileStory(c *gin.Context) {
 		req.Output = "output.html"
 	}
 
+	// Tweego compila solo file su disco
+	if req.Content != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "la compilazione richiede file_path o paths"})
+		return
+	}
+
+	sources, err := req.sources()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Compila
-	result, err := s.compiler.Compile(req.FilePath, &compiler.CompileOptions{
+	result, err := s.compiler.CompileProject(sources, &compiler.CompileOptions{
 		Format: req.Format,
 		Output: req.Output,
 	})
@@ -243,12 +378,195 @@ func (s *Server) compileStory(c *gin.Context) {
 	})
 }
 
+// ExportTwineRequest richiesta di esportazione in archivio Twine 2
+type ExportTwineRequest struct {
+	StorySource
+	Output string `json:"output"`
+}
+
+// exportTwineArchive esporta la storia come archivio della libreria Twine 2
+// Senza output l'archivio viene restituito direttamente come text/html
+func (s *Server) exportTwineArchive(c *gin.Context) {
+	var req ExportTwineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	story, err := s.loadStory(req.StorySource)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := story.WriteTwineArchive(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Output == "" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	if err := os.WriteFile(req.Output, buf.Bytes(), 0644); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"output_file": req.Output,
+		"passages":    len(story.Passages),
+	})
+}
+
+// ConvertTwee1Request richiesta di conversione Twee 1 → Twee 3
+type ConvertTwee1Request struct {
+	StorySource
+	Format        string `json:"format"`
+	FormatVersion string `json:"format_version"`
+	Output        string `json:"output"`
+}
+
+// convertTwee1 converte una storia Twee 1 in sorgente Twee 3
+// Restituisce il sorgente convertito (o lo scrive in output) e il resoconto della migrazione
+func (s *Server) convertTwee1(c *gin.Context) {
+	var req ConvertTwee1Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	story, err := s.loadStory(req.StorySource)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	converted, report, err := parser.NewTwee1Converter(req.Format, req.FormatVersion).Convert(story)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := converted.WriteTwee(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"report":  report,
+	}
+
+	if req.Output == "" {
+		response["content"] = buf.String()
+	} else {
+		if err := os.WriteFile(req.Output, buf.Bytes(), 0644); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response["output_file"] = req.Output
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EditStoryRequest richiesta di modifica incrementale di un file o di un buffer
+// Con edits le modifiche (range in byte) vengono applicate al sorgente in cache, in ordine;
+// con content il sorgente viene sostituito; altrimenti file_path viene riletto da disco
+type EditStoryRequest struct {
+	StorySource
+	Edits []parser.Edit `json:"edits"`
+}
+
+// editStory applica modifiche al sorgente riparsando solo i passaggi interessati
+// Il change set viene restituito e inviato ai client WebSocket
+func (s *Server) editStory(c *gin.Context) {
+	var req EditStoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := req.FilePath
+	if key == "" {
+		key = req.name()
+	}
+	if len(req.Paths) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La modifica incrementale supporta un solo file o buffer"})
+		return
+	}
+
+	s.parsersMutex.Lock()
+	ip := s.incrementalParser(key)
+
+	var story *parser.Story
+	var changes *parser.ChangeSet
+	var err error
+
+	switch {
+	case len(req.Edits) > 0:
+		// Il primo caricamento di un file avviene da disco
+		if ip.Story() == nil && req.FilePath != "" {
+			_, err = ip.Load()
+		}
+		changes = &parser.ChangeSet{Added: []string{}, Removed: []string{}, Modified: []string{}}
+		for _, edit := range req.Edits {
+			if err != nil {
+				break
+			}
+			var editChanges *parser.ChangeSet
+			if story, editChanges, err = ip.ApplyEdit(edit); err == nil {
+				changes.Merge(editChanges)
+			}
+		}
+	case req.Content != "":
+		story, changes, err = ip.Update([]byte(req.Content))
+	case req.FilePath != "":
+		story, changes, err = ip.Reload()
+	default:
+		err = fmt.Errorf("specificare edits, content o file_path")
+	}
+	s.parsersMutex.Unlock()
+
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Passaggi aggiunti o modificati, per aggiornare il client senza un nuovo parsing
+	passages := make(map[string]*parser.Passage, len(changes.Added)+len(changes.Modified))
+	for _, title := range append(append([]string{}, changes.Added...), changes.Modified...) {
+		passages[title] = story.Passages[title]
+	}
+
+	if !changes.IsEmpty() {
+		s.broadcast(gin.H{
+			"type":      "passages_changed",
+			"path":      filepath.Base(key),
+			"full_path": key,
+			"changes":   changes,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"changes":  changes,
+		"passages": passages,
+		"order":    story.Order,
+		"count":    len(story.Passages),
+	})
+}
+
 // getPassages ottiene tutti i passaggi
 func (s *Server) getPassages(c *gin.Context) {
 	filePath := c.Param("file")
 	
-	tweeParser := parser.NewTweeParser(filePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(StorySource{FilePath: filePath})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -257,6 +575,7 @@ func (s *Server) getPassages(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"passages": story.Passages,
+		"order":    story.Order,
 	})
 }
 
@@ -265,8 +584,7 @@ func (s *Server) getPassage(c *gin.Context) {
 	filePath := c.Param("file")
 	passageTitle := c.Param("title")
 	
-	tweeParser := parser.NewTweeParser(filePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(StorySource{FilePath: filePath})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -291,12 +609,22 @@ func (s *Server) getPassage(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"passage": gin.H{
-			"title":     passage.Title,
-			"tags":      passage.Tags,
-			"content":   passage.Content,
-			"links":     storyFormat.ParseLinks(passage.Content),
-			"variables": storyFormat.ParseVariables(passage.Content),
-			"preview":   storyFormat.StripCode(passage.Content),
+			"title":      passage.Title,
+			"kind":       passage.Kind(),
+			"tags":       passage.Tags,
+			"content":    passage.Content,
+			"links":      storyFormat.ParseLinks(passage.Content),
+			"variables":  storyFormat.ParseVariables(passage.Content),
+			"preview":    storyFormat.StripCode(passage.Content),
+			"position":   passage.Position,
+			"size":       passage.Size,
+			"metadata":   passage.Metadata,
+			"file":       passage.File,
+			"header":     passage.Header,
+			"body_start": passage.BodyStart,
+			"body_end":   passage.BodyEnd,
+			"link_refs":  storyFormat.FindLinks(passage.Content),
+			"macros":     storyFormat.FindMacros(passage.Content),
 		},
 	})
 }
@@ -307,6 +635,7 @@ type StartWatcherRequest struct {
 	Format      string   `json:"format"`
 	Output      string   `json:"output"`
 	AutoCompile bool     `json:"auto_compile"`
+	Project     bool     `json:"project"`
 }
 
 // startWatcher avvia il file watcher
@@ -340,6 +669,7 @@ func (s *Server) startWatcher(c *gin.Context) {
 			Output: req.Output,
 		},
 		AutoCompile: req.AutoCompile,
+		Project:     req.Project,
 	}
 
 	fw, err := watcher.NewFileWatcher(config)
@@ -433,8 +763,10 @@ func (s *Server) getVersion(c *gin.Context) {
 
 // ValidatePathRequest richiesta di validazione path
 type ValidatePathRequest struct {
-	FilePath string   `json:"file_path" binding:"required"`
-	Path     []string `json:"path" binding:"required"`
+	StorySource
+	Path  []string `json:"path" binding:"required"`
+	Debug bool     `json:"debug"`          // Esegue anche i passaggi debug-*
+	Seed  *int64   `json:"seed,omitempty"` // Seed delle scelte casuali
 }
 
 // validatePath valida un percorso
@@ -446,8 +778,7 @@ func (s *Server) validatePath(c *gin.Context) {
 	}
 
 	// Parse la storia
-	tweeParser := parser.NewTweeParser(req.FilePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(req.StorySource)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -464,6 +795,10 @@ func (s *Server) validatePath(c *gin.Context) {
 
 	// Crea simulator (usa automaticamente story.Format)
 	simulator := simulator.NewPathSimulator(story)
+	simulator.SetDebug(req.Debug)
+	if req.Seed != nil {
+		simulator.SetSeed(*req.Seed)
+	}
 	errors := simulator.ValidatePath(req.Path)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -475,8 +810,11 @@ func (s *Server) validatePath(c *gin.Context) {
 
 // SimulatePathRequest richiesta di simulazione path
 type SimulatePathRequest struct {
-	FilePath string   `json:"file_path" binding:"required"`
-	Path     []string `json:"path" binding:"required"`
+	StorySource
+	Path      []string `json:"path" binding:"required"`
+	Debug     bool     `json:"debug"`          // Esegue anche i passaggi debug-*
+	Seed      *int64   `json:"seed,omitempty"` // Seed delle scelte casuali, per riprodurre una simulazione
+	Enumerate bool     `json:"enumerate"`      // Simula tutti gli esiti delle scelte casuali
 }
 
 // simulatePath simula l'esecuzione di un percorso
@@ -488,8 +826,7 @@ func (s *Server) simulatePath(c *gin.Context) {
 	}
 
 	// Parse la storia
-	tweeParser := parser.NewTweeParser(req.FilePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(req.StorySource)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -506,6 +843,14 @@ func (s *Server) simulatePath(c *gin.Context) {
 
 	// Crea simulator (usa automaticamente story.Format)
 	sim := simulator.NewPathSimulator(story)
+	sim.SetDebug(req.Debug)
+	if req.Enumerate {
+		c.JSON(http.StatusOK, sim.EnumerateOutcomes(req.Path))
+		return
+	}
+	if req.Seed != nil {
+		sim.SetSeed(*req.Seed)
+	}
 	result := sim.SimulatePath(req.Path)
 
 	c.JSON(http.StatusOK, result)
@@ -513,9 +858,9 @@ func (s *Server) simulatePath(c *gin.Context) {
 
 // SuggestPathsRequest richiesta di suggerimento percorsi
 type SuggestPathsRequest struct {
-	FilePath      string `json:"file_path" binding:"required"`
-	StartPassage  string `json:"start_passage" binding:"required"`
-	MaxDepth      int    `json:"max_depth"`
+	StorySource
+	StartPassage string `json:"start_passage" binding:"required"`
+	MaxDepth     int    `json:"max_depth"`
 }
 
 // suggestPaths suggerisce percorsi validi
@@ -531,8 +876,7 @@ func (s *Server) suggestPaths(c *gin.Context) {
 	}
 
 	// Parse la storia
-	tweeParser := parser.NewTweeParser(req.FilePath)
-	story, err := tweeParser.Parse()
+	story, err := s.loadStory(req.StorySource)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -573,14 +917,18 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
+	s.wsMutex.Lock()
 	s.wsClients[conn] = true
 	log.Printf("🔌 Client WebSocket connesso (totale: %d)", len(s.wsClients))
+	s.wsMutex.Unlock()
 
 	for {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
+			s.wsMutex.Lock()
 			delete(s.wsClients, conn)
 			log.Printf("🔌 Client WebSocket disconnesso (totale: %d)", len(s.wsClients))
+			s.wsMutex.Unlock()
 			break
 		}
 	}
@@ -599,13 +947,26 @@ func (s *Server) broadcastWatcherEvents() {
 			"full_path": event.Path,
 			"timestamp": event.Timestamp,
 		}
+		if event.Changes != nil {
+			message["changes"] = event.Changes
+		}
 
-		for client := range s.wsClients {
-			if err := client.WriteJSON(message); err != nil {
-				log.Printf("Errore invio WebSocket: %v", err)
-				client.Close()
-				delete(s.wsClients, client)
-			}
+		s.broadcast(message)
+	}
+}
+
+// broadcast invia un messaggio a tutti i client WebSocket
+// Viene chiamata sia dal watcher sia dalle richieste HTTP: il lock evita scritture concorrenti
+// sulla stessa connessione, che gorilla/websocket non permette
+func (s *Server) broadcast(message gin.H) {
+	s.wsMutex.Lock()
+	defer s.wsMutex.Unlock()
+
+	for client := range s.wsClients {
+		if err := client.WriteJSON(message); err != nil {
+			log.Printf("Errore invio WebSocket: %v", err)
+			client.Close()
+			delete(s.wsClients, client)
 		}
 	}
 }
\ No newline at end of file
@@ -7,6 +7,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"tweego-editor/parser"
 )
 
 // TweegoWrapper gestisce l'integrazione con Tweego (wrapper esterno)
@@ -66,16 +68,30 @@ func NewTweegoWrapper(tweegoPath string, workDir string) (*TweegoWrapper, error)
 
 // Compile compila un file .twee in HTML usando Tweego
 func (tw *TweegoWrapper) Compile(inputFile string, options *CompileOptions) (*CompileResult, error) {
+	return tw.CompileProject([]string{inputFile}, options)
+}
+
+// CompileProject compila un progetto composto da più file e/o directory
+// Tweego unisce da solo tutti i sorgenti, visitando le directory ricorsivamente
+func (tw *TweegoWrapper) CompileProject(inputs []string, options *CompileOptions) (*CompileResult, error) {
 	result := &CompileResult{
 		Success: false,
 	}
 
-	// Validazione pre-compilazione
-	if err := tw.validateBeforeCompile(inputFile, options); err != nil {
+	if len(inputs) == 0 {
+		err := fmt.Errorf("nessun input da compilare")
 		result.ErrorMessage = err.Error()
 		return result, err
 	}
 
+	// Validazione pre-compilazione
+	for _, input := range inputs {
+		if err := tw.validateBeforeCompile(input, options); err != nil {
+			result.ErrorMessage = err.Error()
+			return result, err
+		}
+	}
+
 	// Opzioni di default
 	if options == nil {
 		options = &CompileOptions{
@@ -116,8 +132,8 @@ func (tw *TweegoWrapper) Compile(inputFile string, options *CompileOptions) (*Co
 	// Argomenti aggiuntivi
 	args = append(args, options.AdditionalArgs...)
 
-	// Input file
-	args = append(args, inputFile)
+	// Input (file e directory)
+	args = append(args, inputs...)
 
 	// Esegui tweego
 	cmd := exec.Command(tw.tweegoPath, args...)
@@ -230,14 +246,17 @@ func (tw *TweegoWrapper) validateBeforeCompile(inputFile string, options *Compil
 	if err != nil {
 		return fmt.Errorf("impossibile leggere info file: %w", err)
 	}
-	
-	if fileInfo.Size() == 0 {
-		return fmt.Errorf("file input vuoto: %s", inputFile)
-	}
 
-	// 3. Verifica che il file sia .twee
-	if !strings.HasSuffix(strings.ToLower(inputFile), ".twee") {
-		return fmt.Errorf("il file deve avere estensione .twee")
+	// Le directory di progetto vengono visitate da Tweego
+	if !fileInfo.IsDir() {
+		if fileInfo.Size() == 0 {
+			return fmt.Errorf("file input vuoto: %s", inputFile)
+		}
+
+		// 3. Verifica che il file sia .twee o .tw
+		if !parser.IsTweeFile(inputFile) {
+			return fmt.Errorf("il file deve avere estensione .twee o .tw")
+		}
 	}
 
 	// 4. VALIDAZIONE FORMATO - SEMPRE OBBLIGATORIA
@@ -0,0 +1,244 @@
+package harlowe
+
+// ============================================
+// AST DEL MARKUP
+// ============================================
+
+// Node è un nodo del markup di un passaggio
+// Offset e Length si riferiscono al contenuto originale del passaggio
+type Node interface {
+	Span() (offset, length int)
+}
+
+// TextNode è testo semplice (inclusi HTML, commenti e verbatim)
+type TextNode struct {
+	Text   string
+	Offset int
+	Length int
+}
+
+// LinkNode è un link [[...]] già diviso in testo e target
+type LinkNode struct {
+	Text   string
+	Target string
+	Offset int
+	Length int
+}
+
+// VariableNode è una variabile stampata nel testo ($nome o _nome)
+type VariableNode struct {
+	Name   string
+	Temp   bool
+	Offset int
+	Length int
+}
+
+// MacroNode è una chiamata di macro nel markup
+// Se gli argomenti non sono validi Args è vuoto e Err contiene l'errore di parsing
+type MacroNode struct {
+	Name   string
+	Args   []Expr
+	Err    error
+	Raw    string
+	Offset int
+	Length int
+	Hook   *HookNode // Hook a cui la macro è attaccata, es. (if: $x)[...]
+}
+
+// HookNode è un hook [...] o un hook con nome |nome>[...] / [...]<nome|
+type HookNode struct {
+	Name     string
+	Source   string // Contenuto tra le parentesi quadre
+	Children []Node
+	Changers []*MacroNode // Macro attaccate all'hook, nell'ordine in cui compaiono
+	Offset   int
+	Length   int
+}
+
+func (n *TextNode) Span() (int, int)     { return n.Offset, n.Length }
+func (n *LinkNode) Span() (int, int)     { return n.Offset, n.Length }
+func (n *VariableNode) Span() (int, int) { return n.Offset, n.Length }
+func (n *MacroNode) Span() (int, int)    { return n.Offset, n.Length }
+func (n *HookNode) Span() (int, int)     { return n.Offset, n.Length }
+
+// ============================================
+// AST DELLE ESPRESSIONI
+// ============================================
+
+// Expr è un nodo di un'espressione Harlowe
+type Expr interface {
+	exprNode()
+}
+
+// NumberExpr è un numero letterale
+type NumberExpr struct {
+	Value float64
+}
+
+// StringExpr è una stringa letterale
+type StringExpr struct {
+	Value string
+}
+
+// BoolExpr è true o false
+type BoolExpr struct {
+	Value bool
+}
+
+// VariableExpr è una variabile di storia ($nome) o temporanea (_nome)
+type VariableExpr struct {
+	Name string
+	Temp bool
+}
+
+// HookRefExpr è un riferimento a un hook con nome (?nome)
+type HookRefExpr struct {
+	Name string
+}
+
+// IdentExpr è una parola chiave usata come valore: it, visits, nomi di datatype
+type IdentExpr struct {
+	Name string
+}
+
+// MacroExpr è una chiamata di macro dentro un'espressione
+type MacroExpr struct {
+	Name   string
+	Args   []Expr
+	Raw    string
+	Offset int
+	Length int
+}
+
+// PropertyExpr è l'accesso a una proprietà: $a's nome, nome of $a, $a's ($chiave)
+// Name è il nome scritto direttamente; Key l'espressione calcolata (uno dei due è vuoto)
+type PropertyExpr struct {
+	Target Expr
+	Name   string
+	Key    Expr
+}
+
+// UnaryExpr è un operatore prefisso: not, -
+type UnaryExpr struct {
+	Op      string
+	Operand Expr
+}
+
+// BinaryExpr è un operatore binario
+// Op è la forma canonica: "is not", "does not contain", "is a", "to", ...
+type BinaryExpr struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// SpreadExpr è un argomento di macro preceduto da "...": i suoi elementi diventano argomenti
+type SpreadExpr struct {
+	Operand Expr
+}
+
+// LambdaExpr è una lambda passata a una macro: "_x where _x > 2", "_x via _x * 2",
+// "_x making _tot via _tot + _x", "each _x", "where it > 2"
+// Senza Param l'elemento è accessibile solo come "it"
+type LambdaExpr struct {
+	Param  string
+	Making string
+	Where  Expr
+	Via    Expr
+	Each   bool
+}
+
+func (*NumberExpr) exprNode()   {}
+func (*StringExpr) exprNode()   {}
+func (*BoolExpr) exprNode()     {}
+func (*VariableExpr) exprNode() {}
+func (*HookRefExpr) exprNode()  {}
+func (*IdentExpr) exprNode()    {}
+func (*MacroExpr) exprNode()    {}
+func (*PropertyExpr) exprNode() {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
+func (*SpreadExpr) exprNode()   {}
+func (*LambdaExpr) exprNode()   {}
+
+// Datatype è il valore di un nome di tipo (number, string, array, ...) usato con "is a"
+type Datatype struct {
+	Name string
+}
+
+// datatypeNames associa i nomi di datatype (e le abbreviazioni) al nome canonico
+var datatypeNames = map[string]string{
+	"number":  "number",
+	"num":     "number",
+	"string":  "string",
+	"str":     "string",
+	"boolean": "boolean",
+	"bool":    "boolean",
+	"array":   "array",
+	"datamap": "datamap",
+	"dm":      "datamap",
+	"dataset": "dataset",
+	"ds":      "dataset",
+	"any":     "any",
+}
+
+// ============================================
+// VISITA DELL'AST
+// ============================================
+
+// walkNodes visita i nodi in ordine di documento, scendendo negli hook
+// Se visit restituisce false i figli del nodo non vengono visitati
+func walkNodes(nodes []Node, visit func(Node) bool) {
+	for _, node := range nodes {
+		if !visit(node) {
+			continue
+		}
+		if hook, ok := node.(*HookNode); ok {
+			walkNodes(hook.Children, visit)
+		}
+	}
+}
+
+// walkExpr visita un'espressione e le sue sottoespressioni in ordine
+func walkExpr(expr Expr, visit func(Expr)) {
+	if expr == nil {
+		return
+	}
+	visit(expr)
+
+	switch e := expr.(type) {
+	case *MacroExpr:
+		for _, arg := range e.Args {
+			walkExpr(arg, visit)
+		}
+	case *PropertyExpr:
+		walkExpr(e.Target, visit)
+		walkExpr(e.Key, visit)
+	case *UnaryExpr:
+		walkExpr(e.Operand, visit)
+	case *SpreadExpr:
+		walkExpr(e.Operand, visit)
+	case *LambdaExpr:
+		walkExpr(e.Where, visit)
+		walkExpr(e.Via, visit)
+	case *BinaryExpr:
+		walkExpr(e.Left, visit)
+		walkExpr(e.Right, visit)
+	}
+}
+
+// walkMacroExprs visita le macro annidate negli argomenti delle macro del markup
+func walkMacroExprs(nodes []Node, visit func(*MacroExpr)) {
+	walkNodes(nodes, func(node Node) bool {
+		if macro, ok := node.(*MacroNode); ok {
+			for _, arg := range macro.Args {
+				walkExpr(arg, func(expr Expr) {
+					if inner, ok := expr.(*MacroExpr); ok {
+						visit(inner)
+					}
+				})
+			}
+		}
+		return true
+	})
+}
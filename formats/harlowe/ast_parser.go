@@ -0,0 +1,776 @@
+package harlowe
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ============================================
+// PARSER DEL MARKUP
+// ============================================
+
+// ParseMarkup costruisce l'AST del markup di un passaggio
+// Hook non chiusi e parentesi quadre spaiate restano testo, come in Harlowe
+func ParseMarkup(content string) []Node {
+	type frame struct {
+		open  markupToken
+		nodes []Node
+	}
+	stack := []*frame{{}}
+
+	appendNode := func(node Node) {
+		top := stack[len(stack)-1]
+		top.nodes = append(top.nodes, node)
+	}
+	appendText := func(offset, end int) {
+		appendNode(&TextNode{Text: content[offset:end], Offset: offset, Length: end - offset})
+	}
+
+	for _, token := range lexMarkup(content) {
+		switch token.kind {
+		case markupText:
+			appendText(token.offset, token.end)
+
+		case markupLink:
+			appendNode(newLinkNode(content, token))
+
+		case markupVariable:
+			appendNode(&VariableNode{Name: token.name, Temp: token.temp, Offset: token.offset, Length: token.end - token.offset})
+
+		case markupMacro:
+			appendNode(newMacroNode(content, token))
+
+		case markupHookOpen:
+			stack = append(stack, &frame{open: token})
+
+		case markupHookClose:
+			if len(stack) == 1 {
+				appendText(token.offset, token.end)
+				continue
+			}
+
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			name := top.open.name
+			if name == "" {
+				name = token.name
+			}
+			hook := &HookNode{
+				Name:     name,
+				Source:   content[top.open.end:token.offset],
+				Children: mergeText(top.nodes),
+				Offset:   top.open.offset,
+				Length:   token.end - top.open.offset,
+			}
+			appendNode(hook)
+		}
+	}
+
+	// Hook rimasti aperti: l'apertura diventa testo e i figli risalgono al genitore
+	for len(stack) > 1 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		appendText(top.open.offset, top.open.end)
+		for _, node := range top.nodes {
+			appendNode(node)
+		}
+	}
+
+	nodes := mergeText(stack[0].nodes)
+	attachHooks(nodes)
+	return nodes
+}
+
+// newLinkNode divide un link in testo e target
+// Gestisce [[Link]], [[Testo->Link]] e [[Link<-Testo]]
+func newLinkNode(content string, token markupToken) *LinkNode {
+	inner := content[token.offset+2 : token.end-2]
+	text, target := inner, inner
+
+	// "->" più a destra vince, "<-" più a sinistra vince (come in Harlowe)
+	if idx := strings.LastIndex(inner, "->"); idx != -1 {
+		text, target = inner[:idx], inner[idx+2:]
+	} else if idx := strings.Index(inner, "<-"); idx != -1 {
+		target, text = inner[:idx], inner[idx+2:]
+	}
+
+	return &LinkNode{
+		Text:   strings.TrimSpace(text),
+		Target: strings.TrimSpace(target),
+		Offset: token.offset,
+		Length: token.end - token.offset,
+	}
+}
+
+// newMacroNode parsa gli argomenti di una macro
+func newMacroNode(content string, token markupToken) *MacroNode {
+	macro := &MacroNode{
+		Name:   token.name,
+		Raw:    content[token.offset:token.end],
+		Offset: token.offset,
+		Length: token.end - token.offset,
+	}
+
+	p := &exprParser{src: content, tokens: token.code}
+	args, err := p.parseArguments()
+	if err != nil {
+		macro.Err = fmt.Errorf("(%s:) %w", token.name, err)
+	} else {
+		macro.Args = args
+	}
+	return macro
+}
+
+// mergeText unisce i nodi di testo consecutivi
+func mergeText(nodes []Node) []Node {
+	merged := []Node{}
+	for _, node := range nodes {
+		text, isText := node.(*TextNode)
+		if isText && len(merged) > 0 {
+			if prev, ok := merged[len(merged)-1].(*TextNode); ok && prev.Offset+prev.Length == text.Offset {
+				prev.Text += text.Text
+				prev.Length += text.Length
+				continue
+			}
+		}
+		merged = append(merged, node)
+	}
+	return merged
+}
+
+// attachHooks collega gli hook alle macro che li precedono
+// Più macro adiacenti si attaccano tutte allo stesso hook; tra macro e hook sono
+// ammessi solo spazi sulla stessa riga
+func attachHooks(nodes []Node) {
+	pending := []*MacroNode{}
+
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *MacroNode:
+			pending = append(pending, n)
+		case *HookNode:
+			for _, macro := range pending {
+				macro.Hook = n
+			}
+			n.Changers = pending
+			pending = nil
+			attachHooks(n.Children)
+		case *TextNode:
+			if strings.Trim(n.Text, " \t") != "" {
+				pending = nil
+			}
+		default:
+			pending = nil
+		}
+	}
+}
+
+// ============================================
+// PARSER DELLE ESPRESSIONI
+// ============================================
+
+// ParseExpression costruisce l'AST di un'espressione Harlowe
+func ParseExpression(expression string) (Expr, error) {
+	tokens, _, err := lexCode(expression, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{src: expression, tokens: tokens}
+	if p.atEnd() {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, fmt.Errorf("unexpected '%s' in expression: %s", p.peek().Text, expression)
+	}
+	return expr, nil
+}
+
+// exprParser è un parser a discesa ricorsiva sui token del codice
+// Precedenza, dalla più bassa: to/into, or, and, not, is/is not,
+// contains/is in/is a/matches, < > <= >=, + -, * / %, - unario, of, 's
+// Lo spread (...) e le lambda sono ammessi solo come argomenti di una macro
+type exprParser struct {
+	src    string
+	tokens []Token
+	pos    int
+}
+
+func (p *exprParser) atEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *exprParser) peekAt(n int) Token {
+	if p.pos+n >= len(p.tokens) {
+		return Token{Kind: TokenEOF, Offset: len(p.src)}
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *exprParser) next() Token {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// isWord verifica se il token n posizioni avanti è la parola indicata
+func (p *exprParser) isWord(n int, word string) bool {
+	token := p.peekAt(n)
+	return token.Kind == TokenIdent && strings.EqualFold(token.Value, word)
+}
+
+// isWords verifica se i token successivi formano la sequenza di parole indicata
+func (p *exprParser) isWords(words ...string) bool {
+	for i, word := range words {
+		if !p.isWord(i, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *exprParser) isOperator(op string) bool {
+	token := p.peek()
+	return token.Kind == TokenOperator && token.Value == op
+}
+
+// parseArguments parsa gli argomenti separati da virgole di una macro
+func (p *exprParser) parseArguments() ([]Expr, error) {
+	args := []Expr{}
+	for !p.atEnd() {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.atEnd() {
+			break
+		}
+		if p.peek().Kind != TokenComma {
+			return nil, fmt.Errorf("expected ',' but found '%s'", p.peek().Text)
+		}
+		p.next()
+	}
+	return args, nil
+}
+
+// parseArgument parsa un argomento di macro: una lambda o un'espressione,
+// eventualmente preceduta dallo spread "..."
+func (p *exprParser) parseArgument() (Expr, error) {
+	if p.startsLambda() {
+		return p.parseLambda()
+	}
+	if !p.isOperator("...") {
+		return p.parseExpression()
+	}
+	p.next()
+
+	operand, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return &SpreadExpr{Operand: operand}, nil
+}
+
+// startsLambda verifica se i token successivi iniziano una lambda
+func (p *exprParser) startsLambda() bool {
+	if p.isWord(0, "each") && p.peekAt(1).Kind == TokenTempVariable {
+		return true
+	}
+	if p.isWord(0, "where") || p.isWord(0, "via") {
+		return true
+	}
+	return p.peek().Kind == TokenTempVariable &&
+		(p.isWord(1, "where") || p.isWord(1, "via") || p.isWord(1, "making"))
+}
+
+// parseLambda parsa il parametro e le clausole making, where e via di una lambda
+// Ogni clausola può comparire una volta sola, in qualsiasi ordine
+func (p *exprParser) parseLambda() (Expr, error) {
+	lambda := &LambdaExpr{}
+	if p.isWord(0, "each") {
+		p.next()
+		lambda.Each = true
+	}
+	if p.peek().Kind == TokenTempVariable {
+		lambda.Param = p.next().Value
+	}
+
+	for {
+		var clause string
+		switch {
+		case p.isWord(0, "making"), p.isWord(0, "where"), p.isWord(0, "via"):
+			clause = strings.ToLower(p.next().Value)
+		default:
+			return lambda, nil
+		}
+
+		if clause == "making" {
+			token := p.next()
+			if token.Kind != TokenTempVariable {
+				return nil, fmt.Errorf("'making' must be followed by a temp variable, not '%s'", token.Text)
+			}
+			if lambda.Making != "" {
+				return nil, fmt.Errorf("a lambda can only have one 'making' clause")
+			}
+			lambda.Making = token.Value
+			continue
+		}
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		target := &lambda.Where
+		if clause == "via" {
+			target = &lambda.Via
+		}
+		if *target != nil {
+			return nil, fmt.Errorf("a lambda can only have one '%s' clause", clause)
+		}
+		*target = expr
+	}
+}
+
+// parseExpression è il livello più basso: assegnazioni "X to Y" e "X into Y"
+func (p *exprParser) parseExpression() (Expr, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"to", "into"} {
+		if p.isWord(0, op) {
+			p.next()
+			right, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return &BinaryExpr{Op: op, Left: left, Right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord(0, "or") {
+		p.next()
+		right, err := p.parseLogicalOperand(left, p.parseAnd)
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord(0, "and") {
+		p.next()
+		right, err := p.parseLogicalOperand(left, p.parseNot)
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseLogicalOperand parsa l'operando destro di and/or
+// Se inizia direttamente con un operatore di confronto il soggetto è quello del confronto
+// a sinistra (confronto eliso): "$a > 2 and < 5" equivale a "$a > 2 and $a < 5"
+func (p *exprParser) parseLogicalOperand(left Expr, parse func() (Expr, error)) (Expr, error) {
+	subject := comparisonSubject(left)
+	if subject == nil || !p.startsComparison() {
+		return parse()
+	}
+
+	expr, err := p.parseComparisonRest(subject)
+	if err != nil {
+		return nil, err
+	}
+	if expr, err = p.parseMembershipRest(expr); err != nil {
+		return nil, err
+	}
+	return p.parseIsRest(expr)
+}
+
+// startsComparison verifica se il token corrente è un operatore di confronto
+func (p *exprParser) startsComparison() bool {
+	return p.isWord(0, "is") || p.membershipOp() != "" ||
+		p.isOperator("<") || p.isOperator(">") || p.isOperator("<=") || p.isOperator(">=")
+}
+
+// comparisonSubject restituisce l'operando sinistro dell'ultimo confronto di un'espressione
+func comparisonSubject(expr Expr) Expr {
+	binary, ok := expr.(*BinaryExpr)
+	if !ok {
+		return nil
+	}
+
+	switch binary.Op {
+	case "and", "or":
+		return comparisonSubject(binary.Right)
+	case "is", "is not", "contains", "does not contain", "is in", "is not in",
+		"is a", "is not a", "matches", "does not match", "<", ">", "<=", ">=":
+		return binary.Left
+	}
+	return nil
+}
+
+func (p *exprParser) parseNot() (Expr, error) {
+	if p.isWord(0, "not") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "not", Operand: operand}, nil
+	}
+	return p.parseIs()
+}
+
+// parseIs gestisce "is" e "is not"; "is in", "is a" e le loro negazioni
+// appartengono al livello superiore
+func (p *exprParser) parseIs() (Expr, error) {
+	left, err := p.parseMembership()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseIsRest(left)
+}
+
+func (p *exprParser) parseIsRest(left Expr) (Expr, error) {
+	for p.isWord(0, "is") && p.membershipOp() == "" {
+		p.next()
+		op := "is"
+		if p.isWord(0, "not") {
+			p.next()
+			op = "is not"
+		}
+		right, err := p.parseMembership()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// membershipOp riconosce gli operatori di appartenenza e tipo senza consumarli
+// Restituisce la forma canonica e il numero di parole, o "" se non ce n'è uno
+func (p *exprParser) membershipOp() string {
+	op, _ := p.membershipOpLen()
+	return op
+}
+
+func (p *exprParser) membershipOpLen() (string, int) {
+	switch {
+	case p.isWord(0, "contains"):
+		return "contains", 1
+	case p.isWords("does", "not", "contain"):
+		return "does not contain", 3
+	case p.isWords("is", "in"):
+		return "is in", 2
+	case p.isWords("is", "not", "in"):
+		return "is not in", 3
+	case p.isWords("is", "a"), p.isWords("is", "an"):
+		return "is a", 2
+	case p.isWords("is", "not", "a"), p.isWords("is", "not", "an"):
+		return "is not a", 3
+	case p.isWord(0, "matches"):
+		return "matches", 1
+	case p.isWords("does", "not", "match"):
+		return "does not match", 3
+	}
+	return "", 0
+}
+
+func (p *exprParser) parseMembership() (Expr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseMembershipRest(left)
+}
+
+func (p *exprParser) parseMembershipRest(left Expr) (Expr, error) {
+	for {
+		op, words := p.membershipOpLen()
+		if op == "" {
+			return left, nil
+		}
+		p.pos += words
+
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *exprParser) parseComparison() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseComparisonRest(left)
+}
+
+func (p *exprParser) parseComparisonRest(left Expr) (Expr, error) {
+	for p.isOperator("<") || p.isOperator(">") || p.isOperator("<=") || p.isOperator(">=") {
+		op := p.next().Value
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("+") || p.isOperator("-") {
+		op := p.next().Value
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("*") || p.isOperator("/") || p.isOperator("%") {
+		op := p.next().Value
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (Expr, error) {
+	if p.isOperator("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		// -5 è un numero letterale, non un'operazione
+		if num, ok := operand.(*NumberExpr); ok {
+			return &NumberExpr{Value: -num.Value}, nil
+		}
+		return &UnaryExpr{Op: "-", Operand: operand}, nil
+	}
+	return p.parseOf()
+}
+
+// parseOf gestisce "proprietà of valore", equivalente a "valore's proprietà"
+func (p *exprParser) parseOf() (Expr, error) {
+	left, err := p.parsePossessive()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isWord(0, "of") {
+		return left, nil
+	}
+	p.next()
+
+	target, err := p.parseOf()
+	if err != nil {
+		return nil, err
+	}
+
+	// Una parola a sinistra è il nome della proprietà; altrimenti è una chiave calcolata
+	if ident, ok := left.(*IdentExpr); ok && ident.Name != "it" {
+		return &PropertyExpr{Target: target, Name: ident.Name}, nil
+	}
+	return &PropertyExpr{Target: target, Key: left}, nil
+}
+
+func (p *exprParser) parsePossessive() (Expr, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Kind == TokenPossessive {
+		p.next()
+		if expr, err = p.parseProperty(expr); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// parseProperty parsa il nome dopo 's: una parola, un numero, (espressione) o (macro:)
+func (p *exprParser) parseProperty(target Expr) (Expr, error) {
+	token := p.next()
+
+	switch token.Kind {
+	case TokenIdent:
+		return &PropertyExpr{Target: target, Name: token.Value}, nil
+	case TokenNumber:
+		return &PropertyExpr{Target: target, Key: &NumberExpr{Value: token.Number}}, nil
+	case TokenString:
+		return &PropertyExpr{Target: target, Key: &StringExpr{Value: token.Value}}, nil
+	case TokenLParen:
+		key, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().Kind != TokenRParen {
+			return nil, fmt.Errorf("missing ')' after computed property")
+		}
+		return &PropertyExpr{Target: target, Key: key}, nil
+	case TokenMacroOpen:
+		// $arr's (a: 1, 3): la macro calcola le posizioni
+		key, err := p.parseMacroCall(token)
+		if err != nil {
+			return nil, err
+		}
+		return &PropertyExpr{Target: target, Key: key}, nil
+	case TokenEOF:
+		return nil, fmt.Errorf("missing property name after 's")
+	}
+	return nil, fmt.Errorf("'%s' is not a valid property name", token.Text)
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	token := p.next()
+
+	switch token.Kind {
+	case TokenNumber:
+		return &NumberExpr{Value: token.Number}, nil
+
+	case TokenString:
+		return &StringExpr{Value: token.Value}, nil
+
+	case TokenVariable:
+		return &VariableExpr{Name: token.Value}, nil
+
+	case TokenTempVariable:
+		return &VariableExpr{Name: token.Value, Temp: true}, nil
+
+	case TokenHookRef:
+		return &HookRefExpr{Name: token.Value}, nil
+
+	case TokenMacroOpen:
+		return p.parseMacroCall(token)
+
+	case TokenLParen:
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().Kind != TokenRParen {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return expr, nil
+
+	case TokenIdent:
+		switch strings.ToLower(token.Value) {
+		case "true":
+			return &BoolExpr{Value: true}, nil
+		case "false":
+			return &BoolExpr{Value: false}, nil
+		case "its":
+			// "its length" equivale a "it's length"
+			return p.parseProperty(&IdentExpr{Name: "it"})
+		}
+		if !unicode.IsLetter([]rune(token.Value)[0]) && !isOrdinalName(token.Value) {
+			return nil, fmt.Errorf("unexpected '%s'", token.Text)
+		}
+		return &IdentExpr{Name: token.Value}, nil
+
+	case TokenOperator:
+		switch token.Value {
+		case "...":
+			return nil, fmt.Errorf("'...' can only be used in front of a macro argument")
+		case "=", "==":
+			return nil, fmt.Errorf("'%s' is not an operator: use 'is' to compare values or 'to' in (set:)", token.Value)
+		}
+		return nil, fmt.Errorf("unexpected operator '%s'", token.Value)
+
+	case TokenEOF:
+		return nil, fmt.Errorf("expression ends unexpectedly")
+	}
+
+	return nil, fmt.Errorf("unexpected '%s'", token.Text)
+}
+
+// parseMacroCall parsa gli argomenti di una macro annidata fino alla ")"
+func (p *exprParser) parseMacroCall(open Token) (Expr, error) {
+	args := []Expr{}
+	for p.peek().Kind != TokenRParen {
+		if p.atEnd() {
+			return nil, fmt.Errorf("(%s:) is missing its closing ')'", open.Value)
+		}
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.peek().Kind == TokenComma {
+			p.next()
+		} else if p.peek().Kind != TokenRParen {
+			return nil, fmt.Errorf("expected ',' but found '%s'", p.peek().Text)
+		}
+	}
+	closing := p.next()
+
+	end := closing.Offset + 1
+	return &MacroExpr{
+		Name:   open.Value,
+		Args:   args,
+		Raw:    p.src[open.Offset:end],
+		Offset: open.Offset,
+		Length: end - open.Offset,
+	}, nil
+}
+
+// isOrdinalName verifica se una parola che inizia con cifre è un nome di posizione (1st, 2ndlast, 1stto3rd, ...)
+func isOrdinalName(word string) bool {
+	if _, ok := ordinalIndex(word); ok {
+		return true
+	}
+	_, _, ok := rangeIndex(word)
+	return ok
+}
@@ -0,0 +1,255 @@
+package harlowe
+
+import (
+	"testing"
+)
+
+// ============================================
+// Test: Lexer del codice
+// ============================================
+
+func TestLexCodeStringsAndPossessive(t *testing.T) {
+	tokens, _, err := lexCode(`$Mago's vita + "a + b's" - 'x'`, 0, false)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	kinds := []TokenKind{TokenVariable, TokenPossessive, TokenIdent, TokenOperator, TokenString, TokenOperator, TokenString}
+	if len(tokens) != len(kinds) {
+		t.Fatalf("Expected %d tokens, got %d: %+v", len(kinds), len(tokens), tokens)
+	}
+	for i, kind := range kinds {
+		if tokens[i].Kind != kind {
+			t.Errorf("Token %d (%s): expected kind %d, got %d", i, tokens[i].Text, kind, tokens[i].Kind)
+		}
+	}
+
+	if tokens[4].Value != "a + b's" {
+		t.Errorf("Expected string 'a + b's', got '%s'", tokens[4].Value)
+	}
+
+	t.Log("✅ Stringhe con operatori e possessivi distinti")
+}
+
+// ============================================
+// Test: Parser delle espressioni
+// ============================================
+
+func TestParseExpressionPrecedence(t *testing.T) {
+	expr, err := ParseExpression(`$a is 1 or $b > 2 and not $c`)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// or è il livello più basso: (a is 1) or ((b > 2) and (not c))
+	or, ok := expr.(*BinaryExpr)
+	if !ok || or.Op != "or" {
+		t.Fatalf("Expected 'or' at the root, got %#v", expr)
+	}
+	if is, ok := or.Left.(*BinaryExpr); !ok || is.Op != "is" {
+		t.Errorf("Expected 'is' on the left, got %#v", or.Left)
+	}
+	and, ok := or.Right.(*BinaryExpr)
+	if !ok || and.Op != "and" {
+		t.Fatalf("Expected 'and' on the right, got %#v", or.Right)
+	}
+	if not, ok := and.Right.(*UnaryExpr); !ok || not.Op != "not" {
+		t.Errorf("Expected 'not' operand, got %#v", and.Right)
+	}
+
+	t.Log("✅ Precedenza or < and < not < is < confronti")
+}
+
+func TestParseExpressionProperties(t *testing.T) {
+	expr, err := ParseExpression(`vita of $Mago's stats`)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	of, ok := expr.(*PropertyExpr)
+	if !ok || of.Name != "vita" {
+		t.Fatalf("Expected property 'vita', got %#v", expr)
+	}
+	inner, ok := of.Target.(*PropertyExpr)
+	if !ok || inner.Name != "stats" {
+		t.Fatalf("Expected $Mago's stats as target, got %#v", of.Target)
+	}
+
+	if _, err := ParseExpression(`$a == 1`); err == nil {
+		t.Error("Expected error for '=='")
+	}
+
+	t.Log("✅ 's e of producono lo stesso accesso a proprietà")
+}
+
+// ============================================
+// Test: Parser del markup
+// ============================================
+
+func TestParseMarkupNestedHooks(t *testing.T) {
+	content := `(if: $x)[Fuori [dentro (set: $y to "]")] [[Link->Meta]]]|nome>[Nominato] fine]`
+	nodes := ParseMarkup(content)
+
+	if len(nodes) != 4 {
+		t.Fatalf("Expected macro, hook, named hook and text, got %d nodes: %#v", len(nodes), nodes)
+	}
+
+	macro, ok := nodes[0].(*MacroNode)
+	if !ok || macro.Name != "if" || macro.Hook == nil {
+		t.Fatalf("Expected (if:) with attached hook, got %#v", nodes[0])
+	}
+
+	hook := nodes[1].(*HookNode)
+	if macro.Hook != hook {
+		t.Error("Expected (if:) to be attached to the following hook")
+	}
+	if hook.Source != `Fuori [dentro (set: $y to "]")] [[Link->Meta]]` {
+		t.Errorf("Unexpected hook source: %q", hook.Source)
+	}
+
+	// Hook annidato con dentro una macro la cui stringa contiene "]"
+	inner, ok := hook.Children[1].(*HookNode)
+	if !ok {
+		t.Fatalf("Expected nested hook, got %#v", hook.Children)
+	}
+	if set, ok := inner.Children[1].(*MacroNode); !ok || set.Name != "set" || set.Err != nil {
+		t.Errorf("Expected valid (set:) inside nested hook, got %#v", inner.Children)
+	}
+
+	named, ok := nodes[2].(*HookNode)
+	if !ok || named.Name != "nome" || named.Source != "Nominato" {
+		t.Errorf("Expected named hook 'nome', got %#v", nodes[2])
+	}
+
+	// La "]" finale è spaiata: resta testo
+	if text, ok := nodes[3].(*TextNode); !ok || text.Text != " fine]" {
+		t.Errorf("Expected trailing text, got %#v", nodes[3])
+	}
+
+	t.Log("✅ Hook annidati, hook con nome e parentesi spaiate")
+}
+
+// ============================================
+// Test: Evaluator sull'AST
+// ============================================
+
+func TestEvaluateExpressionAST(t *testing.T) {
+	state := map[string]interface{}{
+		"vita": 50.0,
+		"nome": "Mario",
+		"Mago": map[string]interface{}{"vita": 30.0},
+		"inv":  []interface{}{"spada", "scudo"},
+	}
+	eval := NewHarloweEvaluator(state)
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`$vita - 10`, 40.0},
+		{`-5 + $vita`, 45.0},
+		{`$vita - -5`, 55.0},
+		{`"a - b" + "+c"`, "a - b+c"},
+		{`$nome is "Mario" and $vita > 20`, true},
+		{`not $vita is 50`, false},
+		{`$Mago's vita + 1`, 31.0},
+		{`vita of $Mago`, 30.0},
+		{`$inv's last`, "scudo"},
+		{`"scudo" is in $inv`, true},
+		{`$inv's length is 2`, true},
+		{`$vita is a number`, true},
+		{`(a: 1, (a: 2, 3))'s 2nd's 1st`, 2.0},
+	}
+
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+
+	t.Log("✅ Espressioni valutate sull'AST")
+}
+
+func TestParseVariablesDocumentOrder(t *testing.T) {
+	h := NewHarloweFormat()
+	vars := h.ParseVariables(`(set: $a to "x to y", $n to 1)[(set: $n to it + 1)] (put: $n + 1 into $z)(set: $b to $a + "!")`)
+
+	if vars["a"] != "x to y" {
+		t.Errorf("Expected string with ' to ' preserved, got %v", vars["a"])
+	}
+	if vars["n"] != 2.0 {
+		t.Errorf("Expected n = 2 (set inside hook, using it), got %v", vars["n"])
+	}
+	if vars["z"] != 3.0 {
+		t.Errorf("Expected z = 3 from (put:), got %v", vars["z"])
+	}
+	if vars["b"] != "x to y!" {
+		t.Errorf("Expected b = 'x to y!', got %v", vars["b"])
+	}
+
+	macros := h.FindMacros(`(set: $l to (a: (dm: "k", 1)))`)
+	names := []string{}
+	for _, macro := range macros {
+		names = append(names, macro.Name)
+	}
+	if len(names) != 3 || names[0] != "set" || names[1] != "a" || names[2] != "dm" {
+		t.Errorf("Expected [set a dm], got %v", names)
+	}
+
+	t.Log("✅ Macro eseguite in ordine di documento")
+}
+
+// ============================================
+// Test: Variabili temporanee e scope
+// ============================================
+
+func TestTempVariableScopes(t *testing.T) {
+	h := NewHarloweFormat()
+	eval := NewHarloweEvaluator(nil)
+
+	content := `(set: _i to 1, _inv to (dm: "oro", 2))(set: _inv's oro to it + _i)
+(if: _i is 1)[(set: _i to 5, _nascosta to true)(set: $dentro to _i)]
+(set: $fuori to _i)(move: _inv into $inv)`
+	if err := h.ProcessPassageContent(content, eval); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	state := eval.GetState()
+	if state["dentro"] != 5.0 {
+		t.Errorf("Expected hook to see its own _i = 5, got %v", state["dentro"])
+	}
+	if state["fuori"] != 1.0 {
+		t.Errorf("Expected passage _i unchanged by the hook, got %v", state["fuori"])
+	}
+	if inv, ok := state["inv"].(map[string]interface{}); !ok || inv["oro"] != 3.0 {
+		t.Errorf("Expected _inv moved into $inv with oro = 3, got %v", state["inv"])
+	}
+
+	temps := eval.GetTempState()
+	if _, exists := temps["nascosta"]; exists {
+		t.Error("Expected hook temp variable not to leak into the passage scope")
+	}
+	if _, exists := state["i"]; exists {
+		t.Error("Expected temp variables to stay out of the story state")
+	}
+
+	if _, err := eval.EvaluateExpression(`_nascosta`); err == nil {
+		t.Error("Expected error reading a temp variable outside its hook")
+	}
+
+	if err := ParseAssignment(`_scelta to "nord"`, eval); err != nil || eval.GetTempState()["scelta"] != "nord" {
+		t.Errorf("Expected ParseAssignment to set _scelta, got %v (%v)", eval.GetTempState()["scelta"], err)
+	}
+
+	vars := h.ParseVariables(`(set: $a to 1, _b to 2)`)
+	if vars["a"] != 1.0 || vars["_b"] != 2.0 {
+		t.Errorf("Expected story and temp variables from ParseVariables, got %v", vars)
+	}
+
+	t.Log("✅ Temp variables are scoped to passages and hooks")
+}
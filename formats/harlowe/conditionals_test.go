@@ -515,4 +515,241 @@ func TestNestedConditionals(t *testing.T) {
 	}
 	
 	t.Log("✅ Nested conditionals work (sequential processing)")
-}
\ No newline at end of file
+}
+
+// ============================================
+// Test 4.11: Operatori aritmetici e precedenza
+// ============================================
+
+func TestArithmeticOperators(t *testing.T) {
+	state := map[string]interface{}{
+		"a": 5.0,
+		"b": 4.0,
+	}
+	
+	eval := NewHarloweEvaluator(state)
+	
+	tests := []struct {
+		expr     string
+		expected float64
+		name     string
+	}{
+		{"$a * 2 + ($b - 1)", 13, "Multiplication before addition"},
+		{"$a + $b * 2", 13, "Multiplication binds tighter"},
+		{"($a + $b) * 2", 18, "Parentheses grouping"},
+		{"$b / 2 - 1", 1, "Division"},
+		{"$a % 3", 2, "Modulo"},
+		{"10 - $a - 2", 3, "Left associativity"},
+		{"-$a * 2", -10, "Unary minus"},
+		{"2 * -3", -6, "Negative literal"},
+	}
+	
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+		
+		if result != test.expected {
+			t.Errorf("[%s] %s: expected %v, got %v", test.name, test.expr, test.expected, result)
+		}
+	}
+	
+	// Divisione per zero: errore come in Harlowe
+	if _, err := eval.EvaluateExpression("$a / 0"); err == nil {
+		t.Error("Expected error dividing by zero")
+	}
+	
+	t.Log("✅ Arithmetic operators respect precedence")
+}
+
+// ============================================
+// Test 4.12: Operatori logici e precedenza
+// ============================================
+
+func TestLogicalOperatorPrecedence(t *testing.T) {
+	state := map[string]interface{}{
+		"x": 5.0,
+		"y": 3.0,
+	}
+	
+	eval := NewHarloweEvaluator(state)
+	handler := NewConditionalHandler(eval)
+	
+	tests := []struct {
+		expr     string
+		expected bool
+		name     string
+	}{
+		{"(if: $x > 3 and not $y is 2)[OK]", true, "not binds looser than is"},
+		{"(if: $x > 3 and not $y is 3)[OK]", false, "not negates the comparison"},
+		{"(if: $x is 1 or $y is 3 and $x is 5)[OK]", true, "and binds tighter than or"},
+		{"(if: ($x is 1 or $y is 3) and $x is 4)[OK]", false, "Parentheses override precedence"},
+		{"(if: $x > 3 and < 6)[OK]", true, "Elided comparison (true)"},
+		{"(if: $x > 3 and < 5)[OK]", false, "Elided comparison (false)"},
+		{"(if: $x is 4 or is 5)[OK]", true, "Elided is"},
+		{"(if: $x * 2 >= $y + 7)[OK]", true, "Arithmetic inside comparison"},
+		{"(if: $x is not $y)[OK]", true, "is not"},
+	}
+	
+	for _, test := range tests {
+		result, err := handler.ProcessConditionalChain(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+		
+		if result.ConditionMet != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.name, test.expected, result.ConditionMet)
+		}
+	}
+	
+	t.Log("✅ and/or/not precedence works correctly")
+}
+
+// ============================================
+// Test 4.13: CONTAINS e IS IN su tutti i tipi
+// ============================================
+
+func TestContainsAndIsIn(t *testing.T) {
+	state := map[string]interface{}{
+		"inv":    []interface{}{"spada", "scudo"},
+		"nome":   "Mario Rossi",
+		"stats":  map[string]interface{}{"forza": 10.0},
+		"chiavi": map[string]bool{"rossa": true},
+	}
+	
+	eval := NewHarloweEvaluator(state)
+	handler := NewConditionalHandler(eval)
+	
+	tests := []struct {
+		expr     string
+		expected bool
+		name     string
+	}{
+		{`(if: $inv contains "spada")[OK]`, true, "Array contains"},
+		{`(if: "arco" is in $inv)[OK]`, false, "Array is in"},
+		{`(if: "arco" is not in $inv)[OK]`, true, "Array is not in"},
+		{`(if: $nome contains "Ross")[OK]`, true, "String contains"},
+		{`(if: $stats contains "forza")[OK]`, true, "Datamap contains key"},
+		{`(if: $stats does not contain "magia")[OK]`, true, "Datamap does not contain"},
+		{`(if: "rossa" is in $chiavi)[OK]`, true, "Dataset is in"},
+		{`(if: $inv contains "spada" and "scudo" is in $inv)[OK]`, true, "Combined with and"},
+	}
+	
+	for _, test := range tests {
+		result, err := handler.ProcessConditionalChain(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+		
+		if result.ConditionMet != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.name, test.expected, result.ConditionMet)
+		}
+	}
+	
+	// Una stringa può contenere solo stringhe
+	if _, err := eval.EvaluateExpression(`$nome contains 5`); err == nil {
+		t.Error("Expected error for string contains number")
+	}
+	
+	t.Log("✅ contains and is in work on arrays, strings, datamaps and datasets")
+}
+
+// ============================================
+// Test 4.14: Spread, IS su collezioni e operatori su array
+// ============================================
+
+func TestSpreadAndCollections(t *testing.T) {
+	state := map[string]interface{}{
+		"inv": []interface{}{"spada", "scudo", "spada"},
+	}
+	
+	eval := NewHarloweEvaluator(state)
+	
+	tests := []struct {
+		expr     string
+		expected bool
+		name     string
+	}{
+		{`(a: ...$inv, "arco") is (a: "spada", "scudo", "spada", "arco")`, true, "Spread array"},
+		{`(a: ..."ab") is (a: "a", "b")`, true, "Spread string"},
+		{`$inv - (a: "spada") is (a: "scudo")`, true, "Array difference"},
+		{`(dm: "a", 1) is (dm: "a", 1)`, true, "Datamap equality"},
+		{`"1" is 1`, false, "Different types are never equal"},
+		{`(ds: 1, 2) + (ds: 3) contains 3`, true, "Dataset union"},
+	}
+	
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+		
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.name, test.expected, result)
+		}
+	}
+	
+	if _, err := eval.EvaluateExpression(`...$inv`); err == nil {
+		t.Error("Expected error for spread outside a macro call")
+	}
+	
+	t.Log("✅ Spread and collection operators work correctly")
+}
+
+// ============================================
+// Test 4.15: Conditionals nel contenuto del passaggio
+// ============================================
+
+func TestProcessPassageContentConditionals(t *testing.T) {
+	h := NewHarloweFormat()
+
+	tests := []struct {
+		content  string
+		expected map[string]interface{}
+		name     string
+	}{
+		{`(set: $vita to 30)(if: $vita > 50)[(set: $stato to "ok")](else:)[(set: $stato to "ferito")]`,
+			map[string]interface{}{"stato": "ferito"}, "If/else after set"},
+		{`(if: $vita > 10)[(set: $a to 1)](else-if: $vita > 0)[(set: $a to 2)](else:)[(set: $a to 3)]`,
+			map[string]interface{}{"a": 2.0}, "Else-if chain"},
+		{`(unless: $vita > 10)[(set: $b to true)]`,
+			map[string]interface{}{"b": true}, "Unless"},
+		{`(if: true)[(set: $n to 1)(if: $n is 1)[(set: $n to it + 1)](else:)[(set: $n to 0)]]`,
+			map[string]interface{}{"n": 2.0}, "Nested hooks"},
+		{`(if: true)(unless: $vita is 5)[(set: $c to 1)]`,
+			map[string]interface{}{"c": nil}, "Multiple changers"},
+		{`(link: "Apri")[(set: $d to 1)]`,
+			map[string]interface{}{"d": nil}, "Deferred hook"},
+	}
+
+	for _, test := range tests {
+		eval := NewHarloweEvaluator(map[string]interface{}{"vita": 5.0})
+		if err := h.ProcessPassageContent(test.content, eval); err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+
+		state := eval.GetState()
+		for name, expected := range test.expected {
+			if state[name] != expected {
+				t.Errorf("[%s] Expected $%s = %v, got %v", test.name, name, expected, state[name])
+			}
+		}
+	}
+
+	// (else:) senza un hook precedente è un errore e il suo hook non viene eseguito
+	eval := NewHarloweEvaluator(nil)
+	if err := h.ProcessPassageContent(`(else:)[(set: $e to 1)]`, eval); err == nil {
+		t.Error("Expected error for (else:) without a preceding hook")
+	}
+	if _, exists := eval.GetState()["e"]; exists {
+		t.Error("Expected hook of an invalid (else:) to be hidden")
+	}
+
+	t.Log("✅ Only code in active hooks is executed")
+}
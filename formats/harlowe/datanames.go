@@ -0,0 +1,300 @@
+package harlowe
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ============================================
+// DATA NAME: posizioni, intervalli, length e nomi
+// ============================================
+
+// ordinalRegex riconosce le posizioni: 1st, 22nd, 2ndlast, last
+var ordinalRegex = regexp.MustCompile(`^(?:(\d+)(?:st|nd|rd|th))?(last)?$`)
+
+// ordinalIndex converte un nome di posizione nella posizione (da 1; negative dalla fine, -1 è l'ultimo)
+// "3rd" è 3, "last" è -1, "2ndlast" è -2
+func ordinalIndex(name string) (int, bool) {
+	match := ordinalRegex.FindStringSubmatch(strings.ToLower(name))
+	if match == nil || (match[1] == "" && match[2] == "") {
+		return 0, false
+	}
+	if match[1] == "" {
+		return -1, true
+	}
+
+	position, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	if match[2] != "" {
+		return -position, true
+	}
+	return position, true
+}
+
+// rangeIndex converte un intervallo di posizioni come "1stto3rd" o "2ndlasttolast"
+func rangeIndex(name string) (from, to int, ok bool) {
+	parts := strings.SplitN(strings.ToLower(name), "to", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	from, fromOk := ordinalIndex(parts[0])
+	to, toOk := ordinalIndex(parts[1])
+	return from, to, fromOk && toOk
+}
+
+// positionName scrive una posizione come nome: 3 è "3rd", -1 è "last", -2 è "2ndlast"
+func positionName(position int) string {
+	switch {
+	case position == -1:
+		return "last"
+	case position < 0:
+		return ordinalName(-position) + "last"
+	}
+	return ordinalName(position)
+}
+
+// keyName scrive una chiave di proprietà per i messaggi d'errore
+func keyName(key interface{}) string {
+	if positions, ok := key.([]interface{}); ok {
+		return printValue(positions)
+	}
+	return ConvertToString(key)
+}
+
+// ============================================
+// LETTURA
+// ============================================
+
+// propertyOf legge una proprietà da un valore
+// key è un nome (string), una posizione calcolata (float64) o un array di posizioni
+func (e *HarloweEvaluator) propertyOf(value interface{}, key interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := key.([]interface{}); ok {
+			return nil, fmt.Errorf("a datamap's data names must be strings or numbers, not an array")
+		}
+		return e.datamapProperty(v, ConvertToString(key))
+
+	case []interface{}:
+		if key == "length" {
+			return float64(len(v)), nil
+		}
+		indices, single, err := e.sequenceIndices("array", len(v), key)
+		if err != nil {
+			return nil, err
+		}
+		if single {
+			return v[indices[0]], nil
+		}
+		result := make([]interface{}, len(indices))
+		for i, index := range indices {
+			result[i] = v[index]
+		}
+		return result, nil
+
+	case string:
+		runes := []rune(v)
+		if key == "length" {
+			return float64(len(runes)), nil
+		}
+		indices, _, err := e.sequenceIndices("string", len(runes), key)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for _, index := range indices {
+			sb.WriteRune(runes[index])
+		}
+		return sb.String(), nil
+
+	case map[string]bool:
+		if key == "length" {
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("datasets only have a 'length': their values aren't in any order")
+	}
+
+	return nil, fmt.Errorf("cannot access property '%s' on %s", keyName(key), e.objectName(value))
+}
+
+// datamapProperty legge una chiave di un datamap
+func (e *HarloweEvaluator) datamapProperty(datamap map[string]interface{}, name string) (interface{}, error) {
+	value, exists := datamap[name]
+	if !exists {
+		return nil, fmt.Errorf("property '%s' does not exist in path", name)
+	}
+	return value, nil
+}
+
+// sequenceIndices converte una chiave negli indici (da 0) di un array o di una stringa lunga length
+// single è true se la chiave indica una sola posizione e non un intervallo
+func (e *HarloweEvaluator) sequenceIndices(kind string, length int, key interface{}) (indices []int, single bool, err error) {
+	switch k := key.(type) {
+	case float64:
+		index, err := e.sequenceIndex(kind, length, k)
+		return []int{index}, true, err
+
+	case []interface{}:
+		indices := make([]int, len(k))
+		for i, item := range k {
+			position, ok := numberValue(item)
+			if !ok {
+				return nil, false, fmt.Errorf("an array of positions can only contain numbers, not %s", e.objectName(item))
+			}
+			if indices[i], err = e.sequenceIndex(kind, length, position); err != nil {
+				return nil, false, err
+			}
+		}
+		return indices, false, nil
+
+	case string:
+		if position, ok := ordinalIndex(k); ok {
+			index, err := e.sequenceIndex(kind, length, float64(position))
+			return []int{index}, true, err
+		}
+		if from, to, ok := rangeIndex(k); ok {
+			if from == 0 || to == 0 {
+				return nil, false, fmt.Errorf("'%s' can't use 0 as a position: positions start at 1st", k)
+			}
+			start, end, _ := sliceBounds(k, length, from, to)
+			indices := make([]int, 0, end-start)
+			for i := start; i < end; i++ {
+				indices = append(indices, i)
+			}
+			return indices, false, nil
+		}
+		return nil, false, fmt.Errorf("%s doesn't have a property named '%s'", withArticle(kind), k)
+	}
+
+	return nil, false, fmt.Errorf("cannot access %s of %s", keyName(key), withArticle(kind))
+}
+
+// sequenceIndex converte una posizione (da 1; negative dalla fine) nell'indice da 0
+func (e *HarloweEvaluator) sequenceIndex(kind string, length int, position float64) (int, error) {
+	if position != math.Trunc(position) {
+		return 0, fmt.Errorf("%s is not a whole number, so it can't be a position in %s", ConvertToString(position), withArticle(kind))
+	}
+	if position == 0 {
+		return 0, fmt.Errorf("there is no 0th position: positions start at 1st, or at last from the end")
+	}
+
+	index := int(position) - 1
+	if position < 0 {
+		index = length + int(position)
+	}
+	if index < 0 || index >= length {
+		element := "value"
+		if kind == "string" {
+			element = "character"
+		}
+		return 0, fmt.Errorf("this %s has only %d %s%s, so it doesn't have a %s %s",
+			kind, length, element, plural(length), positionName(int(position)), element)
+	}
+	return index, nil
+}
+
+// ============================================
+// SCRITTURA
+// ============================================
+
+// setIn imposta il valore al percorso keys dentro container e restituisce il contenitore aggiornato
+// I datamap intermedi mancanti vengono creati; ogni contenitore sul percorso viene copiato
+func (e *HarloweEvaluator) setIn(container interface{}, keys []interface{}, value interface{}) (interface{}, error) {
+	if len(keys) == 1 {
+		return e.withProperty(container, keys[0], value)
+	}
+
+	var child interface{}
+	if datamap, ok := container.(map[string]interface{}); ok && datamap[keyName(keys[0])] == nil {
+		// Crea automaticamente nested datamap solo per path intermedi
+		child = make(map[string]interface{})
+	} else {
+		var err error
+		if child, err = e.propertyOf(container, keys[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	child, err := e.setIn(child, keys[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return e.withProperty(container, keys[0], child)
+}
+
+// withProperty imposta una proprietà di un valore e restituisce una copia aggiornata
+// Come in Harlowe i valori si copiano all'assegnazione: modificare la copia di $b non cambia $a
+func (e *HarloweEvaluator) withProperty(container interface{}, key interface{}, value interface{}) (interface{}, error) {
+	if key == "length" {
+		if _, isMap := container.(map[string]interface{}); !isMap {
+			return nil, fmt.Errorf("I can't change the length of %s", e.objectName(container))
+		}
+	}
+
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if _, ok := key.([]interface{}); ok {
+			return nil, fmt.Errorf("a datamap's data names must be strings or numbers, not an array")
+		}
+		result := make(map[string]interface{}, len(c)+1)
+		for name, item := range c {
+			result[name] = item
+		}
+		result[ConvertToString(key)] = value
+		return result, nil
+
+	case []interface{}:
+		indices, single, err := e.sequenceIndices("array", len(c), key)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]interface{}, len(c))
+		copy(result, c)
+		if single {
+			result[indices[0]] = value
+			return result, nil
+		}
+
+		values, ok := value.([]interface{})
+		if !ok || len(values) != len(indices) {
+			return nil, fmt.Errorf("I can't set the %d values at '%s' to %s: it should be an array of %d values",
+				len(indices), keyName(key), e.objectName(value), len(indices))
+		}
+		for i, index := range indices {
+			result[index] = values[i]
+		}
+		return result, nil
+
+	case string:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("I can't put %s into a string's characters, only other strings", e.objectName(value))
+		}
+		runes := []rune(c)
+		indices, single, err := e.sequenceIndices("string", len(runes), key)
+		if err != nil {
+			return nil, err
+		}
+		if single {
+			return string(runes[:indices[0]]) + str + string(runes[indices[0]+1:]), nil
+		}
+
+		replacement := []rune(str)
+		if len(replacement) != len(indices) {
+			return nil, fmt.Errorf("I can't set the %d characters at '%s' to a string of %d characters",
+				len(indices), keyName(key), len(replacement))
+		}
+		result := append([]rune{}, runes...)
+		for i, index := range indices {
+			result[index] = replacement[i]
+		}
+		return string(result), nil
+	}
+
+	return nil, fmt.Errorf("cannot set property '%s' on %s", keyName(key), e.objectName(container))
+}
@@ -0,0 +1,159 @@
+package harlowe
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ============================================
+// Test: Lettura di posizioni, intervalli e proprietà
+// ============================================
+
+func TestDataNameReads(t *testing.T) {
+	eval := NewHarloweEvaluator(map[string]interface{}{
+		"lettere": []interface{}{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"},
+		"nome":    "Ginevra",
+		"mappa":   map[string]interface{}{"chiave": "oro", "oro": 5.0, "1": "uno"},
+		"chiave":  "chiave",
+	})
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`$lettere's 11th`, "k"},
+		{`$lettere's 2ndlast`, "k"},
+		{`$lettere's (12)`, "l"},
+		{`$lettere's (-3)`, "j"},
+		{`$lettere's 1stto3rd is (a: "a", "b", "c")`, true},
+		{`$lettere's 3rdlasttolast is (a: "j", "k", "l")`, true},
+		{`$lettere's (a: 1, 12) is (a: "a", "l")`, true},
+		{`2ndlast of $lettere`, "k"},
+		{`length of $lettere`, 12.0},
+		{`(a: 1, 2)'s (2)`, 2.0},
+		{`$nome's 1st`, "G"},
+		{`$nome's last`, "a"},
+		{`$nome's 2ndto4th`, "ine"},
+		{`$nome's length`, 7.0},
+		{`"città"'s last`, "à"},
+		{`$mappa's ($chiave)`, "oro"},
+		{`$mappa's ($mappa's chiave)`, 5.0},
+		{`$mappa's (1)`, "uno"},
+		{`(ds: 1, 2)'s length`, 2.0},
+		{`(find: _l where its length is 1, ...$nome's 1stto2nd) is (a: "G", "i")`, true},
+	}
+
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+
+	errors := []struct {
+		expr     string
+		expected string
+	}{
+		{`$lettere's 13th`, "this array has only 12 values, so it doesn't have a 13th value"},
+		{`$nome's 3rdlast's 2nd`, "this string has only 1 character, so it doesn't have a 2nd character"},
+		{`$lettere's (0)`, "there is no 0th position: positions start at 1st, or at last from the end"},
+		{`$lettere's (1.5)`, "1.5 is not a whole number, so it can't be a position in an array"},
+		{`$lettere's nome`, "an array doesn't have a property named 'nome'"},
+		{`(5)'s 1st`, "cannot access property '1st' on the number 5"},
+	}
+
+	for _, test := range errors {
+		_, err := eval.EvaluateExpression(test.expr)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("[%s] Expected error %q, got %v", test.expr, test.expected, err)
+		}
+	}
+
+	t.Log("✅ Data names read arrays, strings and datamaps like Harlowe")
+}
+
+// ============================================
+// Test: Scrittura con SetProperty e (set:)
+// ============================================
+
+func TestDataNameWrites(t *testing.T) {
+	eval := NewHarloweEvaluator(map[string]interface{}{
+		"zaino": []interface{}{"spada", "arco", "scudo"},
+		"nome":  "ginevra",
+		"mago":  map[string]interface{}{"incantesimi": []interface{}{"fuoco", "gelo"}},
+		"slot":  "vita",
+	})
+
+	if err := eval.SetProperty(`$zaino's last`, "lancia"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := eval.SetProperty(`$nome's 1st`, "G"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := eval.SetProperty(`$mago's incantesimi's 2ndlast`, "tuono"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err := eval.SetProperty(`$mago's ($slot)`, 10.0); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	h := NewHarloweFormat()
+	if err := h.ProcessPassageContent(`(set: $zaino's 1stto2nd to (a: "ascia", "fionda"))`, eval); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	state := eval.GetState()
+	expectedZaino := []interface{}{"ascia", "fionda", "lancia"}
+	if !reflect.DeepEqual(state["zaino"], expectedZaino) {
+		t.Errorf("Expected zaino %v, got %v", expectedZaino, state["zaino"])
+	}
+	if state["nome"] != "Ginevra" {
+		t.Errorf("Expected nome Ginevra, got %v", state["nome"])
+	}
+	mago := state["mago"].(map[string]interface{})
+	if !reflect.DeepEqual(mago["incantesimi"], []interface{}{"tuono", "gelo"}) {
+		t.Errorf("Expected incantesimi [tuono gelo], got %v", mago["incantesimi"])
+	}
+	if mago["vita"] != 10.0 {
+		t.Errorf("Expected vita 10, got %v", mago["vita"])
+	}
+
+	for _, path := range []string{`$zaino's length`, `$zaino's 4th`, `$slot's 1st`} {
+		if err := eval.SetProperty(path, 1.0); err == nil {
+			t.Errorf("[%s] Expected error", path)
+		}
+	}
+
+	t.Log("✅ Data names write arrays, strings and datamaps like Harlowe")
+}
+
+func TestDataNameWritesDontAlias(t *testing.T) {
+	eval := NewHarloweEvaluator(nil)
+
+	h := NewHarloweFormat()
+	source := `(set: $a to (dm: "x", 1))(set: $b to $a)(set: $b's x to 2)` +
+		`(set: $c to (a: (dm: "k", 1)))(set: $d to $c)(set: $d's 1st's k to 5)`
+	if err := h.ProcessPassageContent(source, eval); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	state := eval.GetState()
+	if x := state["a"].(map[string]interface{})["x"]; x != 1.0 {
+		t.Errorf("Expected $a's x to stay 1, got %v", x)
+	}
+	if x := state["b"].(map[string]interface{})["x"]; x != 2.0 {
+		t.Errorf("Expected $b's x to be 2, got %v", x)
+	}
+	if k := state["c"].([]interface{})[0].(map[string]interface{})["k"]; k != 1.0 {
+		t.Errorf("Expected $c's 1st's k to stay 1, got %v", k)
+	}
+	if k := state["d"].([]interface{})[0].(map[string]interface{})["k"]; k != 5.0 {
+		t.Errorf("Expected $d's 1st's k to be 5, got %v", k)
+	}
+
+	t.Log("✅ Writes through one variable don't change its copies")
+}
@@ -2,9 +2,12 @@ package harlowe
 
 import (
 	"fmt"
+	"math"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
+	"tweego-editor/formats"
 )
 
 // HarloweEvaluator gestisce l'evaluation di espressioni Harlowe
@@ -14,6 +17,11 @@ type HarloweEvaluator struct {
 	visitedPassages map[string]int         // Passato dal PathSimulator
 	history         []string               // Passato dal PathSimulator
 	currentPassage  string                 // Passato dal PathSimulator
+	passages        map[string]string      // Passato dal PathSimulator: contenuto per titolo
+	random          formats.Random         // Passato dal PathSimulator: scelte di (either:), (random:), ...
+	temps           []map[string]interface{} // Scope delle variabili temporanee: passaggio, hook, lambda
+	it              interface{}              // Valore di "it" durante un'assegnazione
+	hasIt           bool
 }
 
 // NewHarloweEvaluator crea un nuovo evaluator
@@ -26,6 +34,8 @@ func NewHarloweEvaluator(state map[string]interface{}) *HarloweEvaluator {
 		visitedPassages: make(map[string]int),
 		history:         []string{},
 		currentPassage:  "",
+		temps:           []map[string]interface{}{{}},
+		random:          formats.NewSeededRandom(time.Now().UnixNano()),
 	}
 }
 
@@ -38,6 +48,12 @@ func (e *HarloweEvaluator) GetState() map[string]interface{} {
 	return e.state
 }
 
+// GetTempState restituisce le variabili temporanee del passaggio (_nome)
+// Quelle create dentro un hook esistono solo nell'hook e non compaiono
+func (e *HarloweEvaluator) GetTempState() map[string]interface{} {
+	return e.temps[0]
+}
+
 // SetState imposta lo stato delle variabili
 func (e *HarloweEvaluator) SetState(state map[string]interface{}) {
 	if state == nil {
@@ -67,30 +83,87 @@ func (e *HarloweEvaluator) SetCurrentPassage(passageName string) {
 	e.currentPassage = passageName
 }
 
+// SetPassages imposta il contenuto dei passaggi per (display:) (passato dal PathSimulator)
+func (e *HarloweEvaluator) SetPassages(passages map[string]string) {
+	e.passages = passages
+}
+
+// SetRandom imposta il generatore delle scelte casuali (passato dal PathSimulator)
+func (e *HarloweEvaluator) SetRandom(random formats.Random) {
+	e.random = random
+}
+
 // EvaluateCondition valuta una condizione e ritorna true/false
 func (e *HarloweEvaluator) EvaluateCondition(condition string) (bool, error) {
 	result, err := e.EvaluateExpression(condition)
 	if err != nil {
 		return false, err
 	}
+	return isTruthy(result), nil
+}
 
-	// Converti il risultato in booleano secondo le regole Harlowe
-	switch v := result.(type) {
-	case bool:
-		return v, nil
-	case float64:
-		return v != 0, nil // Harlowe: numero != 0 è true
-	case int:
-		return v != 0, nil
-	case string:
-		return v != "", nil // Stringa non vuota è true
-	case []interface{}:
-		return len(v) > 0, nil // Array non vuoto è true
-	case map[string]interface{}:
-		return len(v) > 0, nil // Datamap non vuoto è true
-	default:
-		return result != nil, nil
+// EvaluateExpression valuta un'espressione
+// L'espressione viene prima trasformata in AST da ParseExpression
+func (e *HarloweEvaluator) EvaluateExpression(expression string) (interface{}, error) {
+	expr, err := ParseExpression(strings.TrimSpace(expression))
+	if err != nil {
+		return nil, err
 	}
+	return e.evaluate(expr)
+}
+
+// ============================================
+// VARIABILI TEMPORANEE
+// ============================================
+
+// pushScope apre lo scope di un hook o di una lambda con le variabili indicate
+// Le variabili degli scope esterni restano visibili finché non vengono oscurate
+func (e *HarloweEvaluator) pushScope(vars map[string]interface{}) {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	e.temps = append(e.temps, vars)
+}
+
+// popScope chiude lo scope aperto per ultimo; le sue variabili spariscono
+func (e *HarloweEvaluator) popScope() {
+	if len(e.temps) > 1 {
+		e.temps = e.temps[:len(e.temps)-1]
+	}
+}
+
+// tempVariable cerca una variabile temporanea dallo scope più interno
+func (e *HarloweEvaluator) tempVariable(name string) (interface{}, bool) {
+	for i := len(e.temps) - 1; i >= 0; i-- {
+		if value, exists := e.temps[i][name]; exists {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// setTempVariable imposta una variabile temporanea nello scope più interno
+// Come in Harlowe, (set:) dentro un hook non modifica la variabile omonima esterna
+func (e *HarloweEvaluator) setTempVariable(name string, value interface{}) {
+	e.temps[len(e.temps)-1][name] = value
+}
+
+// variable legge una variabile di storia o temporanea
+func (e *HarloweEvaluator) variable(variable *VariableExpr) (interface{}, bool) {
+	if variable.Temp {
+		return e.tempVariable(variable.Name)
+	}
+	value, exists := e.state[variable.Name]
+	return value, exists
+}
+
+// setVariable imposta una variabile di storia o temporanea
+func (e *HarloweEvaluator) setVariable(variable *VariableExpr, value interface{}) {
+	if variable.Temp {
+		e.setTempVariable(variable.Name, value)
+		return
+	}
+	e.state[variable.Name] = value
 }
 
 // ============================================
@@ -111,138 +184,422 @@ func (e *HarloweEvaluator) visits() float64 {
 }
 
 // ============================================
-// 2.1 Property Access: $var's property
+// VISITA DELL'AST
 // ============================================
 
-// EvaluatePropertyAccess valuta espressioni come $Mago's vita
-func (e *HarloweEvaluator) EvaluatePropertyAccess(expression string) (interface{}, error) {
-	parts := e.parsePropertyPath(expression)
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid property access: %s", expression)
+// evaluate calcola il valore di un nodo dell'AST
+func (e *HarloweEvaluator) evaluate(expr Expr) (interface{}, error) {
+	switch n := expr.(type) {
+	case *NumberExpr:
+		return n.Value, nil
+
+	case *StringExpr:
+		return n.Value, nil
+
+	case *BoolExpr:
+		return n.Value, nil
+
+	case *VariableExpr:
+		value, exists := e.variable(n)
+		if !exists && n.Temp {
+			return nil, fmt.Errorf("there isn't a temp variable named _%s in this place", n.Name)
+		}
+		if !exists {
+			return float64(0), nil // Come in Harlowe: le variabili di storia mai impostate valgono 0
+		}
+		return value, nil
+
+	case *IdentExpr:
+		return e.evaluateIdent(n)
+
+	case *HookRefExpr:
+		return nil, fmt.Errorf("hook reference ?%s cannot be used as a value", n.Name)
+
+	case *MacroExpr:
+		return e.evaluateMacro(n)
+
+	case *PropertyExpr:
+		return e.evaluateProperty(n)
+
+	case *UnaryExpr:
+		return e.evaluateUnary(n)
+
+	case *BinaryExpr:
+		return e.evaluateBinary(n)
+
+	case *SpreadExpr:
+		return nil, fmt.Errorf("'...' can only be used in front of a macro argument")
+
+	case *LambdaExpr:
+		return &Lambda{Param: n.Param, Making: n.Making, Where: n.Where, Via: n.Via, Each: n.Each}, nil
 	}
 
-	varName := parts[0]
-	properties := parts[1:]
+	return nil, fmt.Errorf("cannot evaluate expression of type %T", expr)
+}
 
-	currentValue, exists := e.state[varName]
+// evaluateIdent valuta le parole chiave: it, visits, nomi di datatype
+func (e *HarloweEvaluator) evaluateIdent(ident *IdentExpr) (interface{}, error) {
+	name := strings.ToLower(ident.Name)
+
+	switch name {
+	case "it":
+		if !e.hasIt {
+			return nil, fmt.Errorf("'it' can only be used inside an assignment such as (set: $x to it + 1)")
+		}
+		return e.it, nil
+	case "visits", "visit":
+		return e.visits(), nil
+	}
+
+	if datatype, exists := datatypeNames[name]; exists {
+		return Datatype{Name: datatype}, nil
+	}
+
+	return nil, fmt.Errorf("there isn't a variable or datatype named '%s' (did you forget the $?)", ident.Name)
+}
+
+// evaluateMacro valuta le macro che producono un valore (vedi valueMacros)
+func (e *HarloweEvaluator) evaluateMacro(macro *MacroExpr) (interface{}, error) {
+	fn, exists := valueMacros[macroKey(macro.Name)]
 	if !exists {
-		return nil, fmt.Errorf("variable $%s does not exist", varName)
+		return nil, fmt.Errorf("cannot evaluate macro (%s:)", macro.Name)
 	}
 
-	for _, propertyName := range properties {
-		// CASO SPECIALE: "length" su array
-		if propertyName == "length" {
-			return e.getArrayLength(currentValue)
+	args, err := e.evaluateArgs(macro.Args)
+	if err != nil {
+		return nil, err
+	}
+	return fn(e, macro.Name, args)
+}
+
+// evaluateArgs valuta gli argomenti di una macro espandendo gli spread
+func (e *HarloweEvaluator) evaluateArgs(exprs []Expr) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(exprs))
+	for _, arg := range exprs {
+		spread, isSpread := arg.(*SpreadExpr)
+		if isSpread {
+			arg = spread.Operand
+		}
+
+		value, err := e.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !isSpread {
+			args = append(args, value)
+			continue
+		}
+
+		items, err := e.spread(value)
+		if err != nil {
+			return nil, err
 		}
+		args = append(args, items...)
+	}
+	return args, nil
+}
 
-		// CASO SPECIALE: ordinali su array (1st, 2nd, last, etc.)
-		if e.isOrdinal(propertyName) {
-			return e.getArrayElement(currentValue, propertyName)
+// spread restituisce gli elementi di un array, i caratteri di una stringa o i valori di un dataset
+func (e *HarloweEvaluator) spread(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case string:
+		items := []interface{}{}
+		for _, char := range v {
+			items = append(items, string(char))
+		}
+		return items, nil
+	case map[string]bool:
+		items := []interface{}{}
+		for _, key := range sortedKeys(v) {
+			items = append(items, key)
 		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("I can't spread out %s, because it isn't a string, dataset or array", e.GetTypeName(value))
+}
 
-		// CASO NORMALE: property access su datamap
-		datamap, ok := currentValue.(map[string]interface{})
+// evaluateUnary valuta not e il meno unario
+func (e *HarloweEvaluator) evaluateUnary(unary *UnaryExpr) (interface{}, error) {
+	value, err := e.evaluate(unary.Operand)
+	if err != nil {
+		return nil, err
+	}
+
+	switch unary.Op {
+	case "not":
+		return !isTruthy(value), nil
+	case "-":
+		num, ok := numberValue(value)
 		if !ok {
-			return nil, fmt.Errorf("cannot access property '%s' on non-datamap value", propertyName)
+			return nil, fmt.Errorf("I can only negate numbers, not %s", e.objectName(value))
 		}
+		return -num, nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", unary.Op)
+}
 
-		value, exists := datamap[propertyName]
-		if !exists {
-			return nil, fmt.Errorf("property '%s' does not exist in path", propertyName)
+// evaluateBinary valuta gli operatori binari
+func (e *HarloweEvaluator) evaluateBinary(binary *BinaryExpr) (interface{}, error) {
+	switch binary.Op {
+	case "to", "into":
+		return nil, fmt.Errorf("'%s' can only be used in (set:), (put:) or (move:)", binary.Op)
+
+	// and/or valutano il secondo operando solo se serve
+	case "and", "or":
+		left, err := e.evaluate(binary.Left)
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(left) == (binary.Op == "or") {
+			return binary.Op == "or", nil
 		}
+		right, err := e.evaluate(binary.Right)
+		if err != nil {
+			return nil, err
+		}
+		return isTruthy(right), nil
+	}
 
-		currentValue = value
+	left, err := e.evaluate(binary.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.evaluate(binary.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch binary.Op {
+	case "is":
+		return e.areEqual(left, right), nil
+	case "is not":
+		return !e.areEqual(left, right), nil
+
+	case "contains":
+		return e.contains(left, right)
+	case "does not contain":
+		result, err := e.contains(left, right)
+		return !result, err
+	case "is in":
+		return e.contains(right, left)
+	case "is not in":
+		result, err := e.contains(right, left)
+		return !result, err
+
+	case "is a":
+		return e.isA(left, right)
+	case "is not a":
+		result, err := e.isA(left, right)
+		return !result, err
+
+	case "matches":
+		return e.matches(left, right), nil
+	case "does not match":
+		return !e.matches(left, right), nil
+
+	case "<", ">", "<=", ">=":
+		return e.compare(binary.Op, left, right)
+
+	case "+":
+		return e.add(left, right)
+	case "-":
+		return e.subtract(left, right)
+	case "*", "/", "%":
+		return e.arithmetic(binary.Op, left, right)
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s", binary.Op)
+}
+
+// compare valuta gli operatori di confronto (>, <, >=, <=)
+func (e *HarloweEvaluator) compare(op string, left, right interface{}) (bool, error) {
+	leftNum, rightNum, err := e.numberOperands("use "+op+" to compare", left, right)
+	if err != nil {
+		return false, err
 	}
 
-	return currentValue, nil
+	switch op {
+	case ">":
+		return leftNum > rightNum, nil
+	case "<":
+		return leftNum < rightNum, nil
+	case ">=":
+		return leftNum >= rightNum, nil
+	default:
+		return leftNum <= rightNum, nil
+	}
 }
 
-// isOrdinal verifica se una stringa è un ordinale
-func (e *HarloweEvaluator) isOrdinal(s string) bool {
-	ordinals := []string{"1st", "2nd", "3rd", "4th", "5th", "6th", "7th", "8th", "9th", "10th", "last"}
-	for _, ord := range ordinals {
-		if s == ord {
-			return true
+// isA valuta "X is a datatype"
+func (e *HarloweEvaluator) isA(value, datatype interface{}) (bool, error) {
+	var name string
+	switch d := datatype.(type) {
+	case Datatype:
+		name = d.Name
+	case string:
+		name = d
+		if canonical, exists := datatypeNames[d]; exists {
+			name = canonical
 		}
+	default:
+		return false, fmt.Errorf("'is a' must be followed by a datatype, not %s", e.GetTypeName(datatype))
 	}
-	return false
+
+	return name == "any" || e.GetTypeName(value) == name, nil
+}
+
+// matches valuta "X matches Y": con un datatype controlla il tipo, altrimenti l'uguaglianza
+func (e *HarloweEvaluator) matches(left, right interface{}) bool {
+	if datatype, ok := right.(Datatype); ok {
+		result, _ := e.isA(left, datatype)
+		return result
+	}
+	if datatype, ok := left.(Datatype); ok {
+		result, _ := e.isA(right, datatype)
+		return result
+	}
+	return e.areEqual(left, right)
 }
 
 // ============================================
-// 2.2 Property Set: (set: $var's prop to value)
+// 2.1 Property Access: $var's property
 // ============================================
 
-// SetProperty imposta il valore di una property
-func (e *HarloweEvaluator) SetProperty(varPath string, value interface{}) error {
-	parts := e.parsePropertyPath(varPath)
-	if len(parts) < 2 {
-		return fmt.Errorf("invalid property path: %s", varPath)
+// EvaluatePropertyAccess valuta espressioni come $Mago's vita
+func (e *HarloweEvaluator) EvaluatePropertyAccess(expression string) (interface{}, error) {
+	expr, err := ParseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := expr.(*PropertyExpr); !ok {
+		return nil, fmt.Errorf("invalid property access: %s", expression)
 	}
+	return e.evaluate(expr)
+}
 
-	varName := parts[0]
-	properties := parts[1:]
+// evaluateProperty valuta $var's proprietà e proprietà of $var
+func (e *HarloweEvaluator) evaluateProperty(property *PropertyExpr) (interface{}, error) {
+	// Accedere a una proprietà di una variabile mai impostata è un errore
+	if variable, ok := property.Target.(*VariableExpr); ok && !variable.Temp {
+		if _, exists := e.variable(variable); !exists {
+			return nil, fmt.Errorf("variable $%s does not exist", variable.Name)
+		}
+	}
 
-	baseValue, exists := e.state[varName]
-	if !exists {
-		return fmt.Errorf("cannot set property on non-existent variable $%s. Create it first with (set: $%s to (dm:))", 
-			varName, varName)
+	target, err := e.evaluate(property.Target)
+	if err != nil {
+		return nil, err
 	}
 
-	_, isDatamap := baseValue.(map[string]interface{})
-	if !isDatamap {
-		return fmt.Errorf("cannot set property '%s' on $%s: variable is %s, not a datamap. Use (set: $%s to (dm:)) first",
-			properties[0], varName, e.GetTypeName(baseValue), varName)
+	key, err := e.propertyKey(property)
+	if err != nil {
+		return nil, err
 	}
+	return e.propertyOf(target, key)
+}
 
-	// Naviga la struttura nested (ora sappiamo che esiste ed è un datamap)
-	current := baseValue
+// propertyKey restituisce il nome della proprietà (string), la posizione calcolata (float64)
+// o le posizioni calcolate ([]interface{}), come $arr's (a: 1, 3)
+func (e *HarloweEvaluator) propertyKey(property *PropertyExpr) (interface{}, error) {
+	if property.Key == nil {
+		return property.Name, nil
+	}
 
-	for i, prop := range properties {
-		datamap, ok := current.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("cannot set property on non-datamap value at path element '%s'", prop)
-		}
+	key, err := e.evaluate(property.Key)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case string, float64, []interface{}:
+		return key, nil
+	case int:
+		return float64(key.(int)), nil
+	}
+	return nil, fmt.Errorf("%s cannot be used as a property name", e.objectName(key))
+}
 
-		if i == len(properties)-1 {
-			datamap[prop] = value
-			return nil
-		}
+// ============================================
+// 2.2 Property Set: (set: $var's prop to value)
+// ============================================
 
-		// Crea automaticamente nested datamap solo per path intermedi
-		if _, exists := datamap[prop]; !exists {
-			datamap[prop] = make(map[string]interface{})
-		}
+// SetProperty imposta il valore di una property
+func (e *HarloweEvaluator) SetProperty(varPath string, value interface{}) error {
+	expr, err := ParseExpression(varPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := expr.(*PropertyExpr); !ok {
+		return fmt.Errorf("invalid property path: %s", varPath)
+	}
+	return e.setTarget(expr, value)
+}
 
-		current = datamap[prop]
-		
-		// Verifica che il nested value sia ancora un datamap
-		if _, ok := current.(map[string]interface{}); !ok {
-			return fmt.Errorf("cannot set nested property: '%s' is %s, not a datamap", 
-				prop, e.GetTypeName(current))
+// setTarget assegna un valore a una variabile o a una proprietà
+func (e *HarloweEvaluator) setTarget(target Expr, value interface{}) error {
+	switch t := target.(type) {
+	case *VariableExpr:
+		e.setVariable(t, value)
+		return nil
+
+	case *PropertyExpr:
+		variable, properties, err := e.propertyPath(t)
+		if err != nil {
+			return err
 		}
+		return e.setPropertyPath(variable, properties, value)
 	}
 
-	return nil
+	return fmt.Errorf("assignment target must be a variable")
 }
 
-// parsePropertyPath converte "$Mago's vita's max" in ["Mago", "vita", "max"]
-func (e *HarloweEvaluator) parsePropertyPath(path string) []string {
-	path = strings.TrimSpace(path)
-	if strings.HasPrefix(path, "$") {
-		path = path[1:]
+// propertyPath converte $Mago's vita's max in ($Mago, ["vita", "max"])
+// Le chiavi sono quelle di propertyKey: nomi, posizioni calcolate o array di posizioni
+func (e *HarloweEvaluator) propertyPath(property *PropertyExpr) (*VariableExpr, []interface{}, error) {
+	properties := []interface{}{}
+	var current Expr = property
+
+	for {
+		switch node := current.(type) {
+		case *PropertyExpr:
+			key, err := e.propertyKey(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			properties = append([]interface{}{key}, properties...)
+			current = node.Target
+		case *VariableExpr:
+			return node, properties, nil
+		default:
+			return nil, nil, fmt.Errorf("assignment target must be a variable")
+		}
 	}
+}
 
-	parts := strings.Split(path, "'s")
+// setPropertyPath imposta una proprietà annidata di un datamap, un array o una stringa
+func (e *HarloweEvaluator) setPropertyPath(variable *VariableExpr, properties []interface{}, value interface{}) error {
+	varName := variableName(variable)
+	baseValue, exists := e.variable(variable)
+	if !exists {
+		return fmt.Errorf("cannot set property on non-existent variable %s. Create it first with (set: %s to (dm:))",
+			varName, varName)
+	}
 
-	result := make([]string, 0, len(parts))
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			result = append(result, trimmed)
-		}
+	switch baseValue.(type) {
+	case map[string]interface{}, []interface{}, string:
+	default:
+		return fmt.Errorf("cannot set property '%s' on %s: variable is %s, not a datamap, array or string. Use (set: %s to (dm:)) first",
+			keyName(properties[0]), varName, e.GetTypeName(baseValue), varName)
 	}
 
-	return result
+	// I contenitori vengono copiati: il valore aggiornato torna nella variabile
+	updated, err := e.setIn(baseValue, properties, value)
+	if err != nil {
+		return err
+	}
+	e.setVariable(variable, updated)
+	return nil
 }
 
 // ============================================
@@ -282,19 +639,33 @@ func (e *HarloweEvaluator) valueToString(value interface{}) string {
 	}
 }
 
+// assign valuta valueExpr e lo assegna a target
+// Durante la valutazione "it" vale il valore corrente del target
+func (e *HarloweEvaluator) assign(target Expr, valueExpr Expr) error {
+	current, err := e.evaluate(target)
+
+	prevIt, prevHasIt := e.it, e.hasIt
+	e.it, e.hasIt = current, err == nil
+	value, err := e.evaluate(valueExpr)
+	e.it, e.hasIt = prevIt, prevHasIt
+
+	if err != nil {
+		return err
+	}
+	return e.setTarget(target, value)
+}
+
 // ============================================
 // 2.4 (put:) Macro
 // ============================================
 
 // Put implementa (put: value into $var)
 func (e *HarloweEvaluator) Put(value interface{}, target string) error {
-	if strings.Contains(target, "'s") {
-		return e.SetProperty(target, value)
+	expr, err := ParseExpression(target)
+	if err != nil {
+		return err
 	}
-
-	varName := strings.TrimPrefix(target, "$")
-	e.state[varName] = value
-	return nil
+	return e.setTarget(expr, value)
 }
 
 // ============================================
@@ -303,20 +674,99 @@ func (e *HarloweEvaluator) Put(value interface{}, target string) error {
 
 // Move implementa (move: $source into $dest)
 func (e *HarloweEvaluator) Move(source string, dest string) error {
-	sourceName := strings.TrimPrefix(source, "$")
-	value, exists := e.state[sourceName]
+	sourceExpr, err := ParseExpression(source)
+	if err != nil {
+		return err
+	}
+	destExpr, err := ParseExpression(dest)
+	if err != nil {
+		return err
+	}
+	return e.move(sourceExpr, destExpr)
+}
+
+// move sposta il valore di una variabile in un'altra destinazione
+func (e *HarloweEvaluator) move(source Expr, dest Expr) error {
+	variable, ok := source.(*VariableExpr)
+	if !ok {
+		return fmt.Errorf("(move:) source must be a variable")
+	}
+
+	value, exists := e.variable(variable)
 	if !exists {
-		return fmt.Errorf("source variable $%s does not exist", sourceName)
+		return fmt.Errorf("source variable %s does not exist", variableName(variable))
 	}
 
-	if err := e.Put(value, dest); err != nil {
+	if err := e.setTarget(dest, value); err != nil {
 		return err
 	}
 
-	e.state[sourceName] = 0
+	e.setVariable(variable, 0)
 	return nil
 }
 
+// variableName restituisce il nome di una variabile con il suo sigillo: $nome o _nome
+func variableName(variable *VariableExpr) string {
+	if variable.Temp {
+		return "_" + variable.Name
+	}
+	return "$" + variable.Name
+}
+
+// ============================================
+// MACRO DI COMANDO: (set:), (put:), (move:)
+// ============================================
+
+// runMacro esegue una macro del markup che modifica lo stato (o il generatore casuale)
+// Le macro che non modificano lo stato vengono ignorate
+func (e *HarloweEvaluator) runMacro(macro *MacroNode) error {
+	key := macroKey(macro.Name)
+	if key != "set" && key != "put" && key != "move" && key != "seed" {
+		return nil
+	}
+	if macro.Err != nil {
+		return macro.Err
+	}
+	if key == "seed" {
+		return e.seed(macro)
+	}
+
+	for _, arg := range macro.Args {
+		binary, ok := arg.(*BinaryExpr)
+
+		switch key {
+		case "set":
+			if !ok || binary.Op != "to" {
+				return fmt.Errorf("(set:) expects 'variable to value', got %s", macro.Raw)
+			}
+			if err := e.assign(binary.Left, binary.Right); err != nil {
+				return err
+			}
+		case "put":
+			if !ok || binary.Op != "into" {
+				return fmt.Errorf("(put:) expects 'value into variable', got %s", macro.Raw)
+			}
+			if err := e.assign(binary.Right, binary.Left); err != nil {
+				return err
+			}
+		case "move":
+			if !ok || binary.Op != "into" {
+				return fmt.Errorf("(move:) expects 'variable into variable', got %s", macro.Raw)
+			}
+			if err := e.move(binary.Left, binary.Right); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// macroKey normalizza il nome di una macro: Harlowe ignora maiuscole, - e _
+func macroKey(name string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+}
+
 // ============================================
 // FASE 3: Array/Collection Operations
 // ============================================
@@ -340,81 +790,38 @@ func (e *HarloweEvaluator) concatenateArrays(left, right interface{}) ([]interfa
 	return result, nil
 }
 
-// contains verifica se un array contiene un valore
-func (e *HarloweEvaluator) contains(array interface{}, value interface{}) (bool, error) {
-	arr, err := e.toArray(array)
-	if err != nil {
-		return false, fmt.Errorf("operand is not an array: %w", err)
-	}
-
-	for _, item := range arr {
-		if e.areEqual(item, value) {
-			return true, nil
+// contains implementa "X contains Y"
+// Array: un elemento uguale; stringa: una sottostringa; datamap: una chiave; dataset: un valore
+func (e *HarloweEvaluator) contains(container interface{}, value interface{}) (bool, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if e.areEqual(item, value) {
+				return true, nil
+			}
 		}
-	}
-
-	return false, nil
-}
-
-// getArrayLength restituisce la lunghezza di un array
-func (e *HarloweEvaluator) getArrayLength(array interface{}) (float64, error) {
-	arr, err := e.toArray(array)
-	if err != nil {
-		return 0, fmt.Errorf("operand is not an array: %w", err)
-	}
+		return false, nil
 
-	return float64(len(arr)), nil
-}
+	case string:
+		str, ok := value.(string)
+		if !ok {
+			return false, fmt.Errorf("a string can only contain strings, not %s", e.GetTypeName(value))
+		}
+		return strings.Contains(c, str), nil
 
-// getArrayElement ottiene un elemento da un array
-func (e *HarloweEvaluator) getArrayElement(array interface{}, position string) (interface{}, error) {
-	arr, err := e.toArray(array)
-	if err != nil {
-		return nil, fmt.Errorf("operand is not an array: %w", err)
-	}
-
-	if len(arr) == 0 {
-		return nil, fmt.Errorf("array is empty")
-	}
-
-	var index int
-
-	switch position {
-	case "1st":
-		index = 0
-	case "2nd":
-		index = 1
-	case "3rd":
-		index = 2
-	case "4th":
-		index = 3
-	case "5th":
-		index = 4
-	case "6th":
-		index = 5
-	case "7th":
-		index = 6
-	case "8th":
-		index = 7
-	case "9th":
-		index = 8
-	case "10th":
-		index = 9
-	case "last":
-		index = len(arr) - 1
-	default:
-		num, err := strconv.Atoi(position)
+	case map[string]interface{}:
+		key, err := datamapKey(value)
 		if err != nil {
-			return nil, fmt.Errorf("invalid position: %s", position)
+			return false, err
 		}
-		index = num - 1
-	}
+		_, exists := c[key]
+		return exists, nil
 
-	if index < 0 || index >= len(arr) {
-		return nil, fmt.Errorf("index %d out of bounds (array length: %d)", index+1, len(arr))
+	case map[string]bool:
+		return c[fmt.Sprintf("%v", value)], nil
 	}
 
-	return arr[index], nil
+	return false, fmt.Errorf("%s cannot contain values: only strings, arrays, datamaps and datasets can", e.GetTypeName(container))
 }
 
 // toArray converte un valore in array
@@ -427,274 +834,79 @@ func (e *HarloweEvaluator) toArray(value interface{}) ([]interface{}, error) {
 	}
 }
 
-// areEqual confronta due valori
+// areEqual confronta due valori come "is" di Harlowe
+// Valori di tipo diverso non sono mai uguali; array, datamap e dataset si confrontano per contenuto
 func (e *HarloweEvaluator) areEqual(left, right interface{}) bool {
-	leftNum, leftIsNum := toNumber(left)
-	rightNum, rightIsNum := toNumber(right)
-
-	if leftIsNum && rightIsNum {
-		return leftNum == rightNum
-	}
-
-	leftBool, leftIsBool := left.(bool)
-	rightBool, rightIsBool := right.(bool)
-	if leftIsBool && rightIsBool {
-		return leftBool == rightBool
+	leftNum, leftIsNum := numberValue(left)
+	rightNum, rightIsNum := numberValue(right)
+	if leftIsNum || rightIsNum {
+		return leftIsNum && rightIsNum && leftNum == rightNum
 	}
 
-	leftStr, leftIsStr := left.(string)
-	rightStr, rightIsStr := right.(string)
-	if leftIsStr && rightIsStr {
-		return leftStr == rightStr
-	}
-
-	return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
-}
-
-// ============================================
-// FIX 3: REVERSE LOOKUP con operatore "of"
-// ============================================
-
-// evaluateOf gestisce il reverse lookup: $key of $datamap
-// Es: "Slime" of (dm: "Slime", "Water") → "Water"
-// Equivalente a: $datamap's $key
-func (e *HarloweEvaluator) evaluateOf(expression string) (interface{}, error) {
-	// Split per " of "
-	parts := strings.Split(expression, " of ")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid 'of' expression: %s", expression)
-	}
-	
-	keyExpr := strings.TrimSpace(parts[0])
-	datamapExpr := strings.TrimSpace(parts[1])
-	
-	// Valuta la chiave (può essere variabile, stringa, etc.)
-	keyValue, err := e.EvaluateExpression(keyExpr)
-	if err != nil {
-		return nil, fmt.Errorf("error evaluating key in 'of' expression: %w", err)
-	}
-	
-	// La chiave deve essere convertibile in stringa
-	var keyString string
-	switch v := keyValue.(type) {
-	case string:
-		keyString = v
-	case float64:
-		// Converti numero in stringa (es: 1 → "1")
-		if v == float64(int(v)) {
-			keyString = fmt.Sprintf("%d", int(v))
-		} else {
-			keyString = fmt.Sprintf("%v", v)
+	switch l := left.(type) {
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
 		}
-	case int:
-		keyString = fmt.Sprintf("%d", v)
-	default:
-		keyString = fmt.Sprintf("%v", v)
-	}
-	
-	// Valuta il datamap
-	datamapValue, err := e.EvaluateExpression(datamapExpr)
-	if err != nil {
-		return nil, fmt.Errorf("error evaluating datamap in 'of' expression: %w", err)
-	}
-	
-	// Deve essere un datamap
-	datamap, ok := datamapValue.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("right operand of 'of' must be a datamap, got %s", e.GetTypeName(datamapValue))
-	}
-	
-	// Cerca la chiave nel datamap
-	value, exists := datamap[keyString]
-	if !exists {
-		// 🔥 Come dice la documentazione: se la chiave non esiste → ERRORE
-		availableKeys := make([]string, 0, len(datamap))
-		for k := range datamap {
-			availableKeys = append(availableKeys, k)
+		for i := range l {
+			if !e.areEqual(l[i], r[i]) {
+				return false
+			}
 		}
-		return nil, fmt.Errorf("key '%s' not found in datamap. Available keys: %v", keyString, availableKeys)
-	}
-	
-	return value, nil
-}
-
-
-// EvaluateExpression valuta un'espressione
-func (e *HarloweEvaluator) EvaluateExpression(expression string) (interface{}, error) {
-	expression = strings.TrimSpace(expression)
-
-	// Keyword "visits" - numero visite passaggio corrente
-	if expression == "visits" {
-		return e.visits(), nil
-	}
-
-	// Macro (visited: "passaggio")
-	if strings.HasPrefix(expression, "(visited:") {
-		return e.evaluateVisitedMacro(expression)
-	}
-
-	// Macro (history:)
-	if expression == "(history:)" {
-		return e.history, nil
-	}
+		return true
 
-	// 🔥 NUOVO FIX 3: Literals inline - PRIMA di "of" per permettere parsing corretto
-	// Array literal: (a: ...)
-	if strings.HasPrefix(expression, "(a:") || strings.HasPrefix(expression, "(array:") {
-		return ParseArrayLiteral(expression, e)
-	}
-	
-	// Datamap literal: (dm: ...)
-	if strings.HasPrefix(expression, "(dm:") || strings.HasPrefix(expression, "(datamap:") {
-		return ParseDatamapLiteral(expression, e)
-	}
-	
-	// Dataset literal: (ds: ...)
-	if strings.HasPrefix(expression, "(ds:") || strings.HasPrefix(expression, "(dataset:") {
-		return ParseDatasetLiteral(expression, e)
-	}
-
-	// Property access
-	if strings.Contains(expression, "'s") {
-		return e.EvaluatePropertyAccess(expression)
-	}
-
-	// Variabile semplice
-	if strings.HasPrefix(expression, "$") {
-		varName := strings.TrimPrefix(expression, "$")
-		value, exists := e.state[varName]
-		if !exists {
-			return 0, nil
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
 		}
-		return value, nil
-	}
-
-	// Numero
-	if num, err := strconv.ParseFloat(expression, 64); err == nil {
-		return num, nil
-	}
-
-	// Stringa con quotes
-	if strings.HasPrefix(expression, `"`) && strings.HasSuffix(expression, `"`) {
-		return strings.Trim(expression, `"`), nil
-	}
-
-	// Booleani
-	if expression == "true" {
-		return true, nil
-	}
-	if expression == "false" {
-		return false, nil
-	}
-
-	// Operatore "contains"
-	if strings.Contains(expression, " contains ") {
-		return e.evaluateContains(expression)
-	}
-
-	// 🔥 FIX 3: Operatore "of" (reverse lookup)
-	if strings.Contains(expression, " of ") {
-		return e.evaluateOf(expression)
-	}
-
-	// Operazioni con +
-	if strings.Contains(expression, "+") {
-		return e.evaluatePlus(expression)
-	}
-
-	// Operazioni con -
-	if strings.Contains(expression, "-") {
-		return e.evaluateMinus(expression)
-	}
-
-	// Comparazioni
-	if strings.Contains(expression, " > ") || strings.Contains(expression, " < ") ||
-		strings.Contains(expression, " >= ") || strings.Contains(expression, " <= ") ||
-		strings.Contains(expression, " is ") || strings.Contains(expression, " == ") {
-		return e.evaluateComparison(expression)
-	}
-
-	return nil, fmt.Errorf("cannot evaluate expression: %s", expression)
-}
-
-// evaluateVisitedMacro valuta (visited: "passaggio")
-func (e *HarloweEvaluator) evaluateVisitedMacro(expression string) (interface{}, error) {
-	visitedRegex := regexp.MustCompile(`\(visited:\s*"([^"]+)"\)`)
-	matches := visitedRegex.FindStringSubmatch(expression)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid visited macro: %s", expression)
-	}
-
-	passageName := matches[1]
-	count := e.visited(passageName)
-
-	// Ritorna il conteggio come numero (convertito in bool nelle condizioni)
-	return count, nil
-}
-
-// evaluateComparison valuta comparazioni
-func (e *HarloweEvaluator) evaluateComparison(expression string) (interface{}, error) {
-	patterns := []struct {
-		op      string
-		compare func(left, right float64) bool
-	}{
-		{" >= ", func(l, r float64) bool { return l >= r }},
-		{" <= ", func(l, r float64) bool { return l <= r }},
-		{" > ", func(l, r float64) bool { return l > r }},
-		{" < ", func(l, r float64) bool { return l < r }},
-		{" is ", func(l, r float64) bool { return l == r }},
-		{" == ", func(l, r float64) bool { return l == r }},
-	}
-
-	for _, p := range patterns {
-		if strings.Contains(expression, p.op) {
-			parts := strings.SplitN(expression, p.op, 2)
-			if len(parts) == 2 {
-				left, err1 := e.EvaluateExpression(strings.TrimSpace(parts[0]))
-				right, err2 := e.EvaluateExpression(strings.TrimSpace(parts[1]))
-
-				if err1 != nil || err2 != nil {
-					return nil, fmt.Errorf("error evaluating comparison operands")
-				}
-
-				leftNum, lok := toNumber(left)
-				rightNum, rok := toNumber(right)
-
-				if lok && rok {
-					return p.compare(leftNum, rightNum), nil
-				}
+		for key, value := range l {
+			other, exists := r[key]
+			if !exists || !e.areEqual(value, other) {
+				return false
+			}
+		}
+		return true
 
-				if p.op == " is " || p.op == " == " {
-					return e.areEqual(left, right), nil
-				}
+	case map[string]bool:
+		r, ok := right.(map[string]bool)
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key := range l {
+			if !r[key] {
+				return false
 			}
 		}
+		return true
 	}
 
-	return nil, fmt.Errorf("cannot evaluate comparison: %s", expression)
+	return left == right
 }
 
-// evaluateContains valuta "X contains Y"
-func (e *HarloweEvaluator) evaluateContains(expression string) (interface{}, error) {
-	parts := strings.Split(expression, " contains ")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid contains expression: %s", expression)
-	}
-
-	leftExpr := strings.TrimSpace(parts[0])
-	rightExpr := strings.TrimSpace(parts[1])
-
-	leftValue, err := e.EvaluateExpression(leftExpr)
-	if err != nil {
-		return nil, err
+// numberValue restituisce il valore di un numero (senza convertire le stringhe)
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
 	}
+	return 0, false
+}
 
-	rightValue, err := e.EvaluateExpression(rightExpr)
-	if err != nil {
-		return nil, err
+// datamapKey converte un valore in chiave di datamap (solo stringhe e numeri)
+func datamapKey(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, int:
+		return ConvertToString(v), nil
 	}
-
-	return e.contains(leftValue, rightValue)
+	return "", fmt.Errorf("datamap keys must be strings or numbers, not %T", value)
 }
 
 // ============================================
@@ -710,48 +922,31 @@ func (e *HarloweEvaluator) MergeDatamaps(left, right interface{}) (map[string]in
 	if !lok {
 		return nil, fmt.Errorf("left operand is not a datamap: %T", left)
 	}
-	
+
 	rightMap, rok := right.(map[string]interface{})
 	if !rok {
 		return nil, fmt.Errorf("right operand is not a datamap: %T", right)
 	}
-	
+
 	// Crea nuovo datamap con merge
 	result := make(map[string]interface{})
-	
+
 	// Copia tutte le chiavi da left
 	for key, value := range leftMap {
 		result[key] = value
 	}
-	
+
 	// Sovrascrivi/aggiungi chiavi da right
 	for key, value := range rightMap {
 		result[key] = value
 	}
-	
+
 	return result, nil
 }
 
-// ============================================
-// AGGIORNA evaluatePlus() per supportare datamap merge
-// ============================================
-
-// Sostituisci il metodo evaluatePlus() esistente con questo:
-
-func (e *HarloweEvaluator) evaluatePlus(expression string) (interface{}, error) {
-	parts := strings.Split(expression, "+")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid plus expression: %s", expression)
-	}
-
-	left, err1 := e.EvaluateExpression(strings.TrimSpace(parts[0]))
-	right, err2 := e.EvaluateExpression(strings.TrimSpace(parts[1]))
-
-	if err1 != nil || err2 != nil {
-		return nil, fmt.Errorf("error evaluating operands")
-	}
-
-	// 🔥 FIX 2: Prova merge datamap PRIMA degli array
+// add implementa +: merge di datamap, concatenazione di array e stringhe, somma
+func (e *HarloweEvaluator) add(left, right interface{}) (interface{}, error) {
+	// Merge datamap PRIMA degli array
 	if _, ok := left.(map[string]interface{}); ok {
 		return e.MergeDatamaps(left, right)
 	}
@@ -761,59 +956,149 @@ func (e *HarloweEvaluator) evaluatePlus(expression string) (interface{}, error)
 		return e.concatenateArrays(left, right)
 	}
 
-	// Somma numerica
-	leftNum, lok := toNumber(left)
-	rightNum, rok := toNumber(right)
-	if lok && rok {
-		return leftNum + rightNum, nil
+	// Unione dataset
+	if leftSet, ok := left.(map[string]bool); ok {
+		rightSet, ok := right.(map[string]bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot add %s to a dataset", e.GetTypeName(right))
+		}
+		result := make(map[string]bool, len(leftSet)+len(rightSet))
+		for key := range leftSet {
+			result[key] = true
+		}
+		for key := range rightSet {
+			result[key] = true
+		}
+		return result, nil
 	}
 
 	// String concatenation
-	leftStr, lok := left.(string)
-	rightStr, rok := right.(string)
-	if lok && rok {
+	if leftStr, ok := left.(string); ok {
+		rightStr, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("I can only use + to join strings, not %s", e.objectName(right))
+		}
 		return leftStr + rightStr, nil
 	}
 
-	return nil, fmt.Errorf("cannot add %T and %T", left, right)
+	// Somma numerica
+	leftNum, rightNum, err := e.numberOperands("add", left, right)
+	if err != nil {
+		return nil, err
+	}
+	return leftNum + rightNum, nil
 }
 
-// evaluateMinus valuta operazioni con -
-func (e *HarloweEvaluator) evaluateMinus(expression string) (interface{}, error) {
-	parts := strings.Split(expression, "-")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid minus expression: %s", expression)
+// subtract implementa -: differenza tra numeri, array e dataset
+// Da un array vengono rimosse tutte le occorrenze dei valori dell'array di destra
+func (e *HarloweEvaluator) subtract(left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot subtract %s from an array", e.GetTypeName(right))
+		}
+		result := []interface{}{}
+		for _, item := range l {
+			if removed, _ := e.contains(r, item); !removed {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+
+	case map[string]bool:
+		r, ok := right.(map[string]bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot subtract %s from a dataset", e.GetTypeName(right))
+		}
+		result := make(map[string]bool)
+		for key := range l {
+			if !r[key] {
+				result[key] = true
+			}
+		}
+		return result, nil
+	}
+
+	leftNum, rightNum, err := e.numberOperands("subtract", left, right)
+	if err != nil {
+		return nil, err
 	}
+	return leftNum - rightNum, nil
+}
 
-	left, err1 := e.EvaluateExpression(strings.TrimSpace(parts[0]))
-	right, err2 := e.EvaluateExpression(strings.TrimSpace(parts[1]))
+// arithmetic implementa *, / e % tra numeri
+func (e *HarloweEvaluator) arithmetic(op string, left, right interface{}) (interface{}, error) {
+	leftNum, rightNum, err := e.numberOperands(arithmeticVerbs[op], left, right)
+	if err != nil {
+		return nil, err
+	}
 
-	if err1 != nil || err2 != nil {
-		return nil, fmt.Errorf("error evaluating operands")
+	switch op {
+	case "*":
+		return leftNum * rightNum, nil
+	case "/":
+		if rightNum == 0 {
+			return nil, fmt.Errorf("I can't divide %s by zero", ConvertToString(leftNum))
+		}
+		return leftNum / rightNum, nil
+	default:
+		if rightNum == 0 {
+			return nil, fmt.Errorf("I can't modulo %s by zero", ConvertToString(leftNum))
+		}
+		return math.Mod(leftNum, rightNum), nil
 	}
+}
 
-	leftNum, lok := toNumber(left)
-	rightNum, rok := toNumber(right)
-	if lok && rok {
-		return leftNum - rightNum, nil
+// sortedKeys restituisce i valori di un dataset in ordine alfabetico
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
+
+// arithmeticVerbs descrive gli operatori aritmetici nei messaggi d'errore
+var arithmeticVerbs = map[string]string{
+	"*": "multiply",
+	"/": "divide",
+	"%": "modulo",
+}
 
-	return nil, fmt.Errorf("cannot subtract %T and %T", left, right)
+// numberOperands restituisce gli operandi di un operatore numerico
+// Come in Harlowe le stringhe non vengono convertite: "3" * 2 è un errore, serve (num: "3") * 2
+func (e *HarloweEvaluator) numberOperands(verb string, left, right interface{}) (float64, float64, error) {
+	leftNum, ok := numberValue(left)
+	if !ok {
+		return 0, 0, fmt.Errorf("I can only %s numbers, not %s", verb, e.objectName(left))
+	}
+	rightNum, ok := numberValue(right)
+	if !ok {
+		return 0, 0, fmt.Errorf("I can only %s numbers, not %s", verb, e.objectName(right))
+	}
+	return leftNum, rightNum, nil
 }
 
-// toNumber converte un valore in float64
-func toNumber(value interface{}) (float64, bool) {
+// isTruthy converte un valore in booleano secondo le regole Harlowe
+func isTruthy(value interface{}) bool {
 	switch v := value.(type) {
-	case int:
-		return float64(v), true
+	case bool:
+		return v
 	case float64:
-		return v, true
+		return v != 0 // Harlowe: numero != 0 è true
+	case int:
+		return v != 0
 	case string:
-		if num, err := strconv.ParseFloat(v, 64); err == nil {
-			return num, true
-		}
+		return v != "" // Stringa non vuota è true
+	case []interface{}:
+		return len(v) > 0 // Array non vuoto è true
+	case map[string]interface{}:
+		return len(v) > 0 // Datamap non vuoto è true
+	default:
+		return value != nil
 	}
-	return 0, false
 }
 
 // ============================================
@@ -839,7 +1124,11 @@ func (e *HarloweEvaluator) GetTypeName(value interface{}) string {
 		return "datamap"
 	case map[string]bool:
 		return "dataset"
+	case Datatype:
+		return "datatype"
+	case *Lambda:
+		return "lambda"
 	default:
 		return "unknown"
 	}
-}
\ No newline at end of file
+}
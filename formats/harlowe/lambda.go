@@ -0,0 +1,126 @@
+package harlowe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================
+// LAMBDA
+// ============================================
+
+// Lambda è il valore di una lambda: le clausole vengono valutate a ogni chiamata
+type Lambda struct {
+	Param  string
+	Making string
+	Where  Expr
+	Via    Expr
+	Each   bool
+}
+
+// clauses restituisce i nomi delle clausole presenti, nell'ordine making, where, via
+func (l *Lambda) clauses() []string {
+	clauses := []string{}
+	if l.Making != "" {
+		clauses = append(clauses, "making")
+	}
+	if l.Where != nil {
+		clauses = append(clauses, "where")
+	}
+	if l.Via != nil {
+		clauses = append(clauses, "via")
+	}
+	return clauses
+}
+
+// describe descrive la lambda come nei messaggi di Harlowe: "a 'where' lambda"
+func (l *Lambda) describe() string {
+	clauses := l.clauses()
+	if len(clauses) == 0 {
+		return "an 'each' lambda"
+	}
+	return describeLambda(clauses)
+}
+
+// describeLambda descrive una lambda con le clausole indicate
+func describeLambda(clauses []string) string {
+	return fmt.Sprintf("a '%s' lambda", strings.Join(clauses, " ... "))
+}
+
+// lambdaArg verifica che un argomento sia una lambda con esattamente le clausole required
+// più, eventualmente, quelle optional
+func (e *HarloweEvaluator) lambdaArg(macroName string, position int, arg interface{}, required []string, optional ...string) (*Lambda, error) {
+	lambda, ok := arg.(*Lambda)
+	if !ok {
+		return nil, fmt.Errorf("(%s:)'s %s value is %s, but should be %s",
+			macroName, ordinalName(position), e.objectName(arg), describeLambda(required))
+	}
+
+	allowed := map[string]bool{}
+	for _, clause := range append(required, optional...) {
+		allowed[clause] = true
+	}
+	present := map[string]bool{}
+	for _, clause := range lambda.clauses() {
+		present[clause] = true
+		if !allowed[clause] {
+			return nil, fmt.Errorf("(%s:)'s %s value is %s, but should be %s",
+				macroName, ordinalName(position), lambda.describe(), describeLambda(required))
+		}
+	}
+	for _, clause := range required {
+		if !present[clause] {
+			return nil, fmt.Errorf("(%s:)'s %s value is %s, but should be %s",
+				macroName, ordinalName(position), lambda.describe(), describeLambda(required))
+		}
+	}
+	return lambda, nil
+}
+
+// withLambdaScope valuta fn nello scope della lambda: il parametro e "it" valgono item,
+// l'eventuale variabile di making vale total
+func (e *HarloweEvaluator) withLambdaScope(lambda *Lambda, item, total interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	vars := map[string]interface{}{}
+	if lambda.Param != "" {
+		vars[lambda.Param] = item
+	}
+	if lambda.Making != "" {
+		vars[lambda.Making] = total
+	}
+
+	e.pushScope(vars)
+	prevIt, prevHasIt := e.it, e.hasIt
+	e.it, e.hasIt = item, true
+
+	result, err := fn()
+
+	e.it, e.hasIt = prevIt, prevHasIt
+	e.popScope()
+	return result, err
+}
+
+// lambdaPasses valuta la clausola where per un elemento; senza where ogni elemento passa
+func (e *HarloweEvaluator) lambdaPasses(lambda *Lambda, item, total interface{}) (bool, error) {
+	if lambda.Where == nil {
+		return true, nil
+	}
+
+	result, err := e.withLambdaScope(lambda, item, total, func() (interface{}, error) {
+		return e.evaluate(lambda.Where)
+	})
+	if err != nil {
+		return false, err
+	}
+	passed, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("this lambda's 'where' clause must evaluate to true or false, not %s", e.objectName(result))
+	}
+	return passed, nil
+}
+
+// lambdaVia valuta la clausola via per un elemento
+func (e *HarloweEvaluator) lambdaVia(lambda *Lambda, item, total interface{}) (interface{}, error) {
+	return e.withLambdaScope(lambda, item, total, func() (interface{}, error) {
+		return e.evaluate(lambda.Via)
+	})
+}
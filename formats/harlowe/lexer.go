@@ -0,0 +1,403 @@
+package harlowe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================
+// TOKEN DEL CODICE (argomenti delle macro)
+// ============================================
+
+// TokenKind è il tipo di un token del codice Harlowe
+type TokenKind int
+
+const (
+	TokenEOF          TokenKind = iota
+	TokenNumber                 // 42, 3.5
+	TokenString                 // "testo" o 'testo'
+	TokenVariable               // $nome
+	TokenTempVariable           // _nome
+	TokenHookRef                // ?nome
+	TokenIdent                  // parole: is, not, to, it, 1st, last, number, ...
+	TokenPossessive             // 's
+	TokenMacroOpen              // (nome:
+	TokenLParen                 // (
+	TokenRParen                 // )
+	TokenComma                  // ,
+	TokenOperator               // + - * / % < > <= >= = == ...
+)
+
+// Token è un elemento lessicale del codice
+// Offset è la posizione nel contenuto originale del passaggio
+type Token struct {
+	Kind   TokenKind
+	Text   string  // Testo sorgente del token
+	Value  string  // Nome (variabili, macro), testo decodificato (stringhe), operatore
+	Number float64 // Valore dei TokenNumber
+	Offset int
+}
+
+// endsValue verifica se il token chiude un valore (dopo di lui ' è un possessivo)
+func (t Token) endsValue() bool {
+	switch t.Kind {
+	case TokenNumber, TokenString, TokenVariable, TokenTempVariable, TokenHookRef, TokenIdent, TokenRParen:
+		return true
+	}
+	return false
+}
+
+// codeOperators sono gli operatori simbolici, i più lunghi per primi
+var codeOperators = []string{"...", "<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "%", "="}
+
+// lexCode divide il codice a partire da pos in token
+// Con inMacro il lexing si ferma alla ")" che chiude la macro e restituisce la posizione
+// successiva; altrimenti prosegue fino alla fine di src
+func lexCode(src string, pos int, inMacro bool) ([]Token, int, error) {
+	tokens := []Token{}
+	depth := 0
+
+	emit := func(kind TokenKind, start, end int, value string) {
+		tokens = append(tokens, Token{Kind: kind, Text: src[start:end], Value: value, Offset: start})
+	}
+	prevEndsValue := func() bool {
+		return len(tokens) > 0 && tokens[len(tokens)-1].endsValue()
+	}
+
+	for pos < len(src) {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case r == ')':
+			if depth == 0 {
+				if inMacro {
+					return tokens, pos + 1, nil
+				}
+				return nil, pos, fmt.Errorf("unexpected ')' at offset %d", pos)
+			}
+			depth--
+			emit(TokenRParen, pos, pos+1, ")")
+			pos++
+
+		case r == '(':
+			if name, end := macroOpenAt(src, pos); end != -1 {
+				emit(TokenMacroOpen, pos, end, name)
+				pos = end
+			} else {
+				emit(TokenLParen, pos, pos+1, "(")
+				pos++
+			}
+			depth++
+
+		case r == ',':
+			emit(TokenComma, pos, pos+1, ",")
+			pos++
+
+		case r == '\'' && prevEndsValue() && isPossessiveAt(src, pos):
+			emit(TokenPossessive, pos, pos+2, "'s")
+			pos += 2
+
+		case r == '"' || r == '\'':
+			value, end, err := lexString(src, pos)
+			if err != nil {
+				return nil, pos, err
+			}
+			emit(TokenString, pos, end, value)
+			pos = end
+
+		case r == '$' || r == '_' || r == '?':
+			end := scanWord(src, pos+1)
+			if end == pos+1 {
+				return nil, pos, fmt.Errorf("unexpected '%c' at offset %d", r, pos)
+			}
+			kind := map[rune]TokenKind{'$': TokenVariable, '_': TokenTempVariable, '?': TokenHookRef}[r]
+			emit(kind, pos, end, src[pos+1:end])
+			pos = end
+
+		case unicode.IsDigit(r):
+			end := pos
+			for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+				end++
+			}
+			if end+1 < len(src) && src[end] == '.' && src[end+1] >= '0' && src[end+1] <= '9' {
+				end++
+				for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+					end++
+				}
+			}
+
+			// Un numero seguito da lettere è una parola: 1st, 2ndlast, 1stto3rd
+			if word := scanWord(src, end); word > end {
+				emit(TokenIdent, pos, word, strings.ToLower(src[pos:word]))
+				pos = word
+				continue
+			}
+
+			num, err := strconv.ParseFloat(src[pos:end], 64)
+			if err != nil {
+				return nil, pos, fmt.Errorf("invalid number '%s'", src[pos:end])
+			}
+			emit(TokenNumber, pos, end, src[pos:end])
+			tokens[len(tokens)-1].Number = num
+			pos = end
+
+		case isWordRune(r):
+			end := scanWord(src, pos)
+			emit(TokenIdent, pos, end, src[pos:end])
+			pos = end
+
+		default:
+			op := ""
+			for _, candidate := range codeOperators {
+				if strings.HasPrefix(src[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, pos, fmt.Errorf("unexpected '%c' at offset %d", r, pos)
+			}
+			emit(TokenOperator, pos, pos+len(op), op)
+			pos += len(op)
+		}
+	}
+
+	if inMacro {
+		return nil, pos, fmt.Errorf("macro is missing its closing ')'")
+	}
+	if depth > 0 {
+		return nil, pos, fmt.Errorf("missing closing ')'")
+	}
+	return tokens, pos, nil
+}
+
+// lexString legge una stringa tra virgolette; "\" rende letterale il carattere successivo
+func lexString(src string, pos int) (string, int, error) {
+	quote := src[pos]
+	var sb strings.Builder
+
+	for i := pos + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 < len(src) {
+				i++
+				sb.WriteByte(src[i])
+			}
+		case quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+
+	return "", pos, fmt.Errorf("string starting at offset %d is missing its closing %c", pos, quote)
+}
+
+// macroOpenAt riconosce "(nome:" a partire da pos
+// Restituisce il nome in minuscolo e la posizione dopo i due punti, o -1
+func macroOpenAt(src string, pos int) (string, int) {
+	if pos >= len(src) || src[pos] != '(' {
+		return "", -1
+	}
+
+	i := pos + 1
+	r, size := utf8.DecodeRuneInString(src[i:])
+	if !unicode.IsLetter(r) {
+		return "", -1
+	}
+	i += size
+
+	for i < len(src) {
+		r, size = utf8.DecodeRuneInString(src[i:])
+		if !isWordRune(r) && r != '-' {
+			break
+		}
+		i += size
+	}
+
+	if i >= len(src) || src[i] != ':' {
+		return "", -1
+	}
+	return strings.ToLower(src[pos+1 : i]), i + 1
+}
+
+// isPossessiveAt verifica se in pos inizia il possessivo 's (non seguito da lettere)
+func isPossessiveAt(src string, pos int) bool {
+	if !strings.HasPrefix(src[pos:], "'s") {
+		return false
+	}
+	return scanWord(src, pos+2) == pos+2
+}
+
+// scanWord restituisce la fine della parola (lettere, cifre, _) che inizia in pos
+func scanWord(src string, pos int) int {
+	for pos < len(src) {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		if !isWordRune(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// isWordRune verifica se il carattere può far parte di un nome
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ============================================
+// TOKEN DEL MARKUP (testo del passaggio)
+// ============================================
+
+// markupKind è il tipo di un token del markup
+type markupKind int
+
+const (
+	markupText markupKind = iota
+	markupLink
+	markupMacro
+	markupVariable
+	markupHookOpen
+	markupHookClose
+)
+
+// markupToken è un elemento del markup; le macro portano i token del loro codice
+type markupToken struct {
+	kind   markupKind
+	offset int
+	end    int
+	name   string  // Nome di macro, variabile o hook con nome
+	temp   bool    // Variabile temporanea (_nome)
+	code   []Token // Token degli argomenti della macro
+}
+
+// lexMarkup divide il contenuto di un passaggio in token di markup
+// Il codice delle macro viene diviso da lexCode, così stringhe e possessivi
+// al loro interno non vengono confusi con parentesi o hook
+func lexMarkup(content string) []markupToken {
+	tokens := []markupToken{}
+	textStart := 0
+
+	flush := func(pos int) {
+		if pos > textStart {
+			tokens = append(tokens, markupToken{kind: markupText, offset: textStart, end: pos})
+		}
+	}
+	emit := func(token markupToken) {
+		flush(token.offset)
+		tokens = append(tokens, token)
+		textStart = token.end
+	}
+
+	pos := 0
+	for pos < len(content) {
+		rest := content[pos:]
+
+		switch {
+		// Verbatim: `testo` con lo stesso numero di backtick in apertura e chiusura
+		case rest[0] == '`':
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if close := strings.Index(rest[n:], strings.Repeat("`", n)); close != -1 {
+				pos += n + close + n
+				continue
+			}
+			pos += n
+
+		// Commenti HTML: restano testo
+		case strings.HasPrefix(rest, "<!--"):
+			if close := strings.Index(rest, "-->"); close != -1 {
+				pos += close + 3
+				continue
+			}
+			pos += 4
+
+		// Link [[...]]: hanno la precedenza sugli hook
+		case strings.HasPrefix(rest, "[[") && !strings.HasPrefix(rest, "[[["):
+			if close := strings.Index(rest[2:], "]]"); close != -1 {
+				emit(markupToken{kind: markupLink, offset: pos, end: pos + 2 + close + 2})
+				pos += 2 + close + 2
+				continue
+			}
+			pos += 2
+
+		// Hook con nome in apertura: |nome>[
+		case rest[0] == '|':
+			end := scanWord(content, pos+1)
+			if end > pos+1 && strings.HasPrefix(content[end:], ">[") {
+				emit(markupToken{kind: markupHookOpen, offset: pos, end: end + 2, name: content[pos+1 : end]})
+				pos = end + 2
+				continue
+			}
+			pos++
+
+		case rest[0] == '[':
+			emit(markupToken{kind: markupHookOpen, offset: pos, end: pos + 1})
+			pos++
+
+		// Chiusura di hook, eventualmente con nome: ]<nome|
+		case rest[0] == ']':
+			token := markupToken{kind: markupHookClose, offset: pos, end: pos + 1}
+			if strings.HasPrefix(rest, "]<") {
+				if end := scanWord(content, pos+2); end > pos+2 && end < len(content) && content[end] == '|' {
+					token.end = end + 1
+					token.name = content[pos+2 : end]
+				}
+			}
+			emit(token)
+			pos = token.end
+
+		case rest[0] == '(':
+			name, bodyStart := macroOpenAt(content, pos)
+			if bodyStart == -1 {
+				pos++
+				continue
+			}
+			code, end, err := lexCode(content, bodyStart, true)
+			if err != nil {
+				// Codice non valido: la parentesi resta testo
+				pos++
+				continue
+			}
+			emit(markupToken{kind: markupMacro, offset: pos, end: end, name: name, code: code})
+			pos = end
+
+		case rest[0] == '$' || (rest[0] == '_' && !precededByWord(content, pos)):
+			end := scanWord(content, pos+1)
+			if end == pos+1 || (rest[0] == '_' && !startsWithLetter(content[pos+1:end])) {
+				pos++
+				continue
+			}
+			emit(markupToken{kind: markupVariable, offset: pos, end: end, name: content[pos+1 : end], temp: rest[0] == '_'})
+			pos = end
+
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			pos += size
+		}
+	}
+
+	flush(len(content))
+	return tokens
+}
+
+// precededByWord verifica se il carattere prima di pos fa parte di una parola
+func precededByWord(content string, pos int) bool {
+	if pos == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(content[:pos])
+	return isWordRune(r)
+}
+
+// startsWithLetter verifica se il nome inizia con una lettera
+func startsWithLetter(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsLetter(r)
+}
@@ -13,54 +13,7 @@ import (
 // ParseValue è il punto di ingresso principale per parsare qualsiasi valore Harlowe
 // Gestisce ricorsivamente: literals, variabili, property access, operazioni
 func ParseValue(expression string, eval *HarloweEvaluator) (interface{}, error) {
-	expr := strings.TrimSpace(expression)
-	
-	// 1. Array Literal: (a: ...)
-	if strings.HasPrefix(expr, "(a:") || strings.HasPrefix(expr, "(array:") {
-		return ParseArrayLiteral(expr, eval)
-	}
-	
-	// 2. Datamap Literal: (dm: ...)
-	if strings.HasPrefix(expr, "(dm:") || strings.HasPrefix(expr, "(datamap:") {
-		return ParseDatamapLiteral(expr, eval)
-	}
-	
-	// 3. Dataset Literal: (ds: ...)
-	if strings.HasPrefix(expr, "(ds:") || strings.HasPrefix(expr, "(dataset:") {
-		return ParseDatasetLiteral(expr, eval)
-	}
-	
-	// 4. String Literal con quotes
-	if strings.HasPrefix(expr, `"`) && strings.HasSuffix(expr, `"`) {
-		return strings.Trim(expr, `"`), nil
-	}
-	
-	// 5. Number Literal
-	if num, err := strconv.ParseFloat(expr, 64); err == nil {
-		return num, nil
-	}
-	
-	// 6. Boolean Literal
-	if expr == "true" {
-		return true, nil
-	}
-	if expr == "false" {
-		return false, nil
-	}
-	
-	// 7. Variable or Property Access: $var o $var's prop
-	if strings.HasPrefix(expr, "$") {
-		if strings.Contains(expr, "'s") {
-			// Property access - usa evaluator
-			return eval.EvaluatePropertyAccess(expr)
-		}
-		// Variabile semplice
-		return eval.EvaluateExpression(expr)
-	}
-	
-	// 8. Operazioni aritmetiche o espressioni complesse
-	// Delega all'evaluator
-	return eval.EvaluateExpression(expr)
+	return eval.EvaluateExpression(expression)
 }
 
 // ============================================
@@ -68,34 +21,11 @@ func ParseValue(expression string, eval *HarloweEvaluator) (interface{}, error)
 // ============================================
 
 func ParseArrayLiteral(expression string, eval *HarloweEvaluator) ([]interface{}, error) {
-	// Estrai contenuto tra parentesi
-	content := extractMacroContent(expression)
-	if content == "" {
-		return []interface{}{}, nil // Array vuoto
-	}
-	
-	// Split per virgole (gestendo virgole dentro stringhe/nested)
-	elements := smartSplitComma(content)
-	
-	// Parse ricorsivo di ogni elemento
-	result := []interface{}{}
-	for _, elem := range elements {
-		elem = strings.TrimSpace(elem)
-		if elem == "" {
-			continue
-		}
-		
-		// RICORSIONE: ParseValue gestisce anche nested structures
-		value, err := ParseValue(elem, eval)
-		if err != nil {
-			// Se il parsing fallisce, salva come stringa
-			value = elem
-		}
-		
-		result = append(result, value)
+	value, err := parseLiteral(expression, eval, "a", "array")
+	if err != nil {
+		return nil, err
 	}
-	
-	return result, nil
+	return value.([]interface{}), nil
 }
 
 // ============================================
@@ -103,38 +33,11 @@ func ParseArrayLiteral(expression string, eval *HarloweEvaluator) ([]interface{}
 // ============================================
 
 func ParseDatamapLiteral(expression string, eval *HarloweEvaluator) (map[string]interface{}, error) {
-	content := extractMacroContent(expression)
-	if content == "" {
-		return make(map[string]interface{}), nil // Datamap vuoto
-	}
-	
-	elements := smartSplitComma(content)
-	result := make(map[string]interface{})
-	
-	// Processa a coppie: key, value, key, value...
-	for i := 0; i < len(elements); i += 2 {
-		if i+1 >= len(elements) {
-			// Numero dispari di elementi - errore
-			return nil, fmt.Errorf("datamap has odd number of elements")
-		}
-		
-		keyExpr := strings.TrimSpace(elements[i])
-		valueExpr := strings.TrimSpace(elements[i+1])
-		
-		// Parse key (deve essere stringa)
-		key := strings.Trim(keyExpr, `"`)
-		
-		// RICORSIONE: Parse value (può essere nested datamap!)
-		value, err := ParseValue(valueExpr, eval)
-		if err != nil {
-			// Fallback a stringa
-			value = valueExpr
-		}
-		
-		result[key] = value
+	value, err := parseLiteral(expression, eval, "dm", "datamap")
+	if err != nil {
+		return nil, err
 	}
-	
-	return result, nil
+	return value.(map[string]interface{}), nil
 }
 
 // ============================================
@@ -142,129 +45,41 @@ func ParseDatamapLiteral(expression string, eval *HarloweEvaluator) (map[string]
 // ============================================
 
 func ParseDatasetLiteral(expression string, eval *HarloweEvaluator) (map[string]bool, error) {
-	content := extractMacroContent(expression)
-	if content == "" {
-		return make(map[string]bool), nil // Dataset vuoto
-	}
-	
-	elements := smartSplitComma(content)
-	result := make(map[string]bool)
-	
-	// Dataset = set di valori unici
-	for _, elem := range elements {
-		elem = strings.TrimSpace(elem)
-		if elem == "" {
-			continue
-		}
-		
-		// Parse value
-		value, err := ParseValue(elem, eval)
-		if err != nil {
-			value = elem
-		}
-		
-		// Converti in stringa per usare come chiave
-		key := fmt.Sprintf("%v", value)
-		result[key] = true
+	value, err := parseLiteral(expression, eval, "ds", "dataset")
+	if err != nil {
+		return nil, err
 	}
-	
-	return result, nil
+	return value.(map[string]bool), nil
 }
 
 // ============================================
 // UTILITY FUNCTIONS
 // ============================================
 
-// extractMacroContent estrae il contenuto tra parentesi di una macro
-// Input:  "(a: 1, 2, 3)"
-// Output: "1, 2, 3"
-func extractMacroContent(macro string) string {
-	// Trova la prima parentesi aperta
-	start := strings.Index(macro, ":")
-	if start == -1 {
-		return ""
+// parseLiteral valuta un'espressione che deve essere una delle macro indicate
+// Input:  "(a: 1, 2, 3)" con names "a", "array"
+func parseLiteral(expression string, eval *HarloweEvaluator, names ...string) (interface{}, error) {
+	expr, err := ParseExpression(strings.TrimSpace(expression))
+	if err != nil {
+		return nil, err
 	}
-	start++ // Salta i due punti
-	
-	// Trova l'ultima parentesi chiusa
-	end := strings.LastIndex(macro, ")")
-	if end == -1 || end <= start {
-		return ""
+
+	macro, ok := expr.(*MacroExpr)
+	if !ok || !isMacroNamed(macro.Name, names...) {
+		return nil, fmt.Errorf("not a (%s:) literal: %s", names[0], expression)
 	}
-	
-	content := macro[start:end]
-	return strings.TrimSpace(content)
+	return eval.evaluate(macro)
 }
 
-// smartSplitComma split per virgole ma rispetta stringhe e nested structures
-func smartSplitComma(content string) []string {
-	result := []string{}
-	current := ""
-	depth := 0
-	inString := false
-	escapeNext := false
-	
-	for i := 0; i < len(content); i++ {
-		char := content[i]
-		
-		// Gestione escape
-		if escapeNext {
-			current += string(char)
-			escapeNext = false
-			continue
-		}
-		
-		if char == '\\' {
-			escapeNext = true
-			current += string(char)
-			continue
-		}
-		
-		// Gestione stringhe
-		if char == '"' {
-			inString = !inString
-			current += string(char)
-			continue
-		}
-		
-		if inString {
-			current += string(char)
-			continue
-		}
-		
-		// Gestione parentesi (per nested structures)
-		if char == '(' {
-			depth++
-			current += string(char)
-			continue
+// isMacroNamed verifica se il nome di una macro è uno di quelli indicati
+func isMacroNamed(name string, names ...string) bool {
+	key := macroKey(name)
+	for _, candidate := range names {
+		if key == macroKey(candidate) {
+			return true
 		}
-		
-		if char == ')' {
-			depth--
-			current += string(char)
-			continue
-		}
-		
-		// Split per virgola solo a depth 0
-		if char == ',' && depth == 0 {
-			trimmed := strings.TrimSpace(current)
-			if trimmed != "" {
-				result = append(result, trimmed)
-			}
-			current = ""
-			continue
-		}
-		
-		current += string(char)
-	}
-	
-	// Aggiungi ultimo elemento se non vuoto
-	trimmed := strings.TrimSpace(current)
-	if trimmed != "" {
-		result = append(result, trimmed)
 	}
-	
-	return result
+	return false
 }
 
 // ============================================
@@ -272,56 +87,26 @@ func smartSplitComma(content string) []string {
 // ============================================
 
 // ParseAssignment parsa un'intera assegnazione: "$var to value" o "$var's prop to value"
+// "it" nel valore si riferisce al valore corrente della destinazione
 func ParseAssignment(assignment string, eval *HarloweEvaluator) error {
-	// Split per " to "
-	parts := strings.Split(assignment, " to ")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid assignment syntax: %s", assignment)
-	}
-	
-	varPath := strings.TrimSpace(parts[0])
-	valueExpr := strings.TrimSpace(parts[1])
-	
-	// Verifica che sia una variabile
-	if !strings.HasPrefix(varPath, "$") {
-		return fmt.Errorf("assignment target must be a variable: %s", varPath)
-	}
-	
-	// Gestisci operatore "it"
-	if strings.Contains(valueExpr, "it") {
-		varName := extractVarName(varPath)
-		if replaced, err := eval.ReplaceItKeyword(valueExpr, varName); err == nil {
-			valueExpr = replaced
-		}
-	}
-	
-	// Parse value (RICORSIVO!)
-	value, err := ParseValue(valueExpr, eval)
+	expr, err := ParseExpression(assignment)
 	if err != nil {
-		return fmt.Errorf("error parsing value: %w", err)
+		return fmt.Errorf("invalid assignment syntax: %w", err)
 	}
-	
-	// Set value
-	if strings.Contains(varPath, "'s") {
-		// Property assignment
-		return eval.SetProperty(varPath, value)
-	} else {
-		// Simple variable
-		varName := strings.TrimPrefix(varPath, "$")
-		eval.state[varName] = value
-		return nil
+
+	binary, ok := expr.(*BinaryExpr)
+	if !ok || binary.Op != "to" {
+		return fmt.Errorf("invalid assignment syntax: %s", assignment)
 	}
-}
 
-// extractVarName estrae il nome base della variabile
-// "$Mago's vita" -> "Mago"
-// "$hero" -> "hero"
-func extractVarName(varPath string) string {
-	varPath = strings.TrimPrefix(varPath, "$")
-	if idx := strings.Index(varPath, "'s"); idx != -1 {
-		return varPath[:idx]
+	// Verifica che sia una variabile
+	switch binary.Left.(type) {
+	case *VariableExpr, *PropertyExpr:
+	default:
+		return fmt.Errorf("assignment target must be a variable: %s", assignment)
 	}
-	return varPath
+
+	return eval.assign(binary.Left, binary.Right)
 }
 
 // ============================================
@@ -0,0 +1,608 @@
+package harlowe
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ============================================
+// TABELLA DELLE MACRO CHE PRODUCONO UN VALORE
+// ============================================
+
+// macroFunc calcola il valore di una macro dagli argomenti già valutati
+// name è il nome della macro come è scritto, per i messaggi d'errore
+type macroFunc func(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error)
+
+// valueMacros associa il nome normalizzato (vedi macroKey) all'implementazione
+// Viene riempita in init perché le macro con lambda richiamano evaluate
+var valueMacros map[string]macroFunc
+
+func init() {
+	valueMacros = map[string]macroFunc{}
+	register := func(fn macroFunc, names ...string) {
+		for _, name := range names {
+			valueMacros[macroKey(name)] = fn
+		}
+	}
+
+	// Literal
+	register(macroArray, "a", "array")
+	register(macroDatamap, "dm", "datamap")
+	register(macroDataset, "ds", "dataset")
+
+	// Storia della partita
+	register(macroVisited, "visited")
+	register(macroHistory, "history")
+
+	// Casualità
+	register(macroEither, "either")
+	register(macroRandom, "random")
+	register(macroShuffled, "shuffled")
+
+	// Array e datamap
+	register(macroSorted, "sorted")
+	register(macroReversed, "reversed")
+	register(macroRange, "range")
+	register(macroSubarray, "subarray")
+	register(macroRotated, "rotated")
+	register(macroDatamapNames, "dm-names", "data-names")
+	register(macroDatamapValues, "dm-values", "data-values")
+	register(macroCount, "count")
+
+	// Stringhe
+	register(macroStr, "str", "string", "text")
+	register(macroNum, "num", "number")
+	register(macroUppercase, "uppercase")
+	register(macroLowercase, "lowercase")
+	register(macroUpperfirst, "upperfirst")
+	register(macroLowerfirst, "lowerfirst")
+	register(macroSubstring, "substring")
+	register(macroStrRepeated, "str-repeated", "string-repeated")
+
+	// Numeri
+	register(macroMin, "min")
+	register(macroMax, "max")
+	register(mathMacro(round), "round")
+	register(mathMacro(math.Floor), "floor")
+	register(mathMacro(math.Ceil), "ceil")
+	register(mathMacro(math.Abs), "abs")
+	register(mathMacro(math.Sqrt), "sqrt")
+	register(macroPow, "pow")
+
+	// Lambda
+	register(macroFind, "find")
+	register(macroAltered, "altered")
+	register(macroFolded, "folded")
+	register(macroAllPass, "all-pass", "pass")
+	register(macroSomePass, "some-pass")
+	register(macroNonePass, "none-pass")
+}
+
+// ============================================
+// FIRME DELLE MACRO
+// ============================================
+
+// expectArgs verifica numero e tipo degli argomenti di una macro
+// types sono i tipi degli argomenti obbligatori ("number", "string|array", "any", ...);
+// rest, se non è "", è il tipo degli argomenti successivi, che possono anche mancare
+func (e *HarloweEvaluator) expectArgs(name string, args []interface{}, rest string, types ...string) error {
+	if len(args) < len(types) {
+		missing := len(types) - len(args)
+		return fmt.Errorf("the (%s:) macro needs %d more value%s", name, missing, plural(missing))
+	}
+	if rest == "" && len(args) > len(types) {
+		return fmt.Errorf("the (%s:) macro was given %d value%s, but needs only %d", name, len(args), plural(len(args)), len(types))
+	}
+
+	for i, arg := range args {
+		expected := rest
+		if i < len(types) {
+			expected = types[i]
+		}
+		if !e.matchesType(arg, expected) {
+			return fmt.Errorf("(%s:)'s %s value is %s, but should be %s", name, ordinalName(i+1), e.objectName(arg), describeType(expected))
+		}
+	}
+	return nil
+}
+
+// matchesType verifica se un valore è di uno dei tipi separati da "|"
+func (e *HarloweEvaluator) matchesType(value interface{}, expected string) bool {
+	for _, name := range strings.Split(expected, "|") {
+		if name == "any" || e.GetTypeName(value) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// describeType descrive un tipo della firma: "string|array" diventa "a string or an array"
+func describeType(expected string) string {
+	names := strings.Split(expected, "|")
+	for i, name := range names {
+		if name == "any" {
+			names[i] = "any value"
+		} else {
+			names[i] = withArticle(name)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// objectName descrive un valore come nei messaggi di Harlowe:
+// numeri, stringhe e booleani con il loro valore ("the string \"3\""), gli altri col tipo ("an array")
+func (e *HarloweEvaluator) objectName(value interface{}) string {
+	switch v := value.(type) {
+	case *Lambda:
+		return v.describe()
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case bool:
+		return fmt.Sprintf("the boolean value '%t'", v)
+	case Datatype:
+		return fmt.Sprintf("the %s datatype", v.Name)
+	}
+	if num, ok := numberValue(value); ok {
+		return "the number " + ConvertToString(num)
+	}
+	return withArticle(e.GetTypeName(value))
+}
+
+// withArticle antepone l'articolo indeterminativo inglese
+func withArticle(word string) string {
+	if word != "" && strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an " + word
+	}
+	return "a " + word
+}
+
+// plural restituisce "s" se n è diverso da 1
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// ordinalName scrive una posizione come ordinale inglese: 1st, 2nd, 3rd, 11th, 22nd
+func ordinalName(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// wholeNumber verifica che l'argomento in position sia un numero intero che sta in un int
+func (e *HarloweEvaluator) wholeNumber(name string, position int, value interface{}) (int, error) {
+	num, _ := numberValue(value)
+	if num != math.Trunc(num) {
+		return 0, fmt.Errorf("(%s:)'s %s value is %s, but should be a whole number", name, ordinalName(position), ConvertToString(num))
+	}
+	if math.Abs(num) > maxSafeInteger || num > math.MaxInt || num < math.MinInt {
+		return 0, fmt.Errorf("(%s:)'s %s value is %s, which is too far from 0 to be a safe whole number", name, ordinalName(position), ConvertToString(num))
+	}
+	return int(num), nil
+}
+
+// sliceBounds converte le posizioni from e to (da 1, negative dalla fine, incluse)
+// negli indici [start, end) di una sequenza lunga length. Se from segue to vengono scambiate
+func sliceBounds(name string, length, from, to int) (int, int, error) {
+	if from == 0 || to == 0 {
+		return 0, 0, fmt.Errorf("(%s:) can't use 0 as a position: positions start at 1, or at -1 from the end", name)
+	}
+	if from < 0 {
+		from = length + from + 1
+	}
+	if to < 0 {
+		to = length + to + 1
+	}
+	if from > to {
+		from, to = to, from
+	}
+
+	start := int(math.Max(float64(from-1), 0))
+	end := int(math.Min(float64(to), float64(length)))
+	if start > end {
+		start = end
+	}
+	return start, end, nil
+}
+
+// ============================================
+// LITERAL E STORIA
+// ============================================
+
+// macroArray implementa (a: ...valori)
+func macroArray(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	return args, nil
+}
+
+// macroDatamap implementa (dm: nome, valore, ...)
+func macroDatamap(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("datamap has odd number of elements")
+	}
+	result := make(map[string]interface{})
+	for i := 0; i < len(args); i += 2 {
+		key, err := datamapKey(args[i])
+		if err != nil {
+			return nil, err
+		}
+		result[key] = args[i+1]
+	}
+	return result, nil
+}
+
+// macroDataset implementa (ds: ...valori)
+func macroDataset(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	result := make(map[string]bool)
+	for _, value := range args {
+		result[fmt.Sprintf("%v", value)] = true
+	}
+	return result, nil
+}
+
+// macroVisited implementa (visited: "passaggio")
+func macroVisited(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("(visited:) needs exactly one passage name")
+	}
+	passage, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("(visited:) needs a string, not %s", e.GetTypeName(args[0]))
+	}
+	return e.visited(passage), nil
+}
+
+// macroHistory implementa (history:): i passaggi visitati in ordine
+func macroHistory(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	history := make([]interface{}, len(e.history))
+	for i, title := range e.history {
+		history[i] = title
+	}
+	return history, nil
+}
+
+// ============================================
+// ARRAY E DATAMAP
+// ============================================
+
+// macroSorted implementa (sorted: ...valori) e (sorted: via lambda, ...valori)
+// Numeri prima delle stringhe; con la lambda si ordina per il suo risultato
+func macroSorted(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	values := args
+	var lambda *Lambda
+	if len(args) > 0 {
+		if _, isLambda := args[0].(*Lambda); isLambda {
+			var err error
+			if lambda, err = e.lambdaArg(name, 1, args[0], []string{"via"}); err != nil {
+				return nil, err
+			}
+			values = args[1:]
+		}
+	}
+
+	keys := make([]interface{}, len(values))
+	for i, value := range values {
+		key := value
+		if lambda != nil {
+			var err error
+			if key, err = e.lambdaVia(lambda, value, nil); err != nil {
+				return nil, err
+			}
+		}
+		if !e.matchesType(key, "number|string") {
+			return nil, fmt.Errorf("(%s:) can only sort numbers and strings, not %s", name, e.objectName(key))
+		}
+		keys[i] = key
+	}
+
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sortsBefore(keys[order[a]], keys[order[b]])
+	})
+
+	result := make([]interface{}, len(values))
+	for i, index := range order {
+		result[i] = values[index]
+	}
+	return result, nil
+}
+
+// sortsBefore confronta due chiavi di (sorted:): i numeri vengono prima delle stringhe
+func sortsBefore(left, right interface{}) bool {
+	leftNum, leftIsNum := numberValue(left)
+	rightNum, rightIsNum := numberValue(right)
+	switch {
+	case leftIsNum && rightIsNum:
+		return leftNum < rightNum
+	case leftIsNum != rightIsNum:
+		return leftIsNum
+	}
+	return left.(string) < right.(string)
+}
+
+// macroReversed implementa (reversed: ...valori)
+func macroReversed(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	result := make([]interface{}, len(args))
+	for i, value := range args {
+		result[len(args)-1-i] = value
+	}
+	return result, nil
+}
+
+// maxRangeLength limita gli array di (range:), che altrimenti potrebbero esaurire la memoria
+const maxRangeLength = 100000
+
+// macroRange implementa (range: a, b): gli interi da a a b inclusi, in ordine crescente
+func macroRange(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "number", "number"); err != nil {
+		return nil, err
+	}
+	from, err := e.wholeNumber(name, 1, args[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := e.wholeNumber(name, 2, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		from, to = to, from
+	}
+	if to-from >= maxRangeLength {
+		return nil, fmt.Errorf("(range:) can't make an array of %d values: the most it can make is %d", to-from+1, maxRangeLength)
+	}
+
+	result := make([]interface{}, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		result = append(result, float64(i))
+	}
+	return result, nil
+}
+
+// macroSubarray implementa (subarray: array, da, a) con posizioni da 1, negative dalla fine
+func macroSubarray(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "array", "number", "number"); err != nil {
+		return nil, err
+	}
+	from, err := e.wholeNumber(name, 2, args[1])
+	if err != nil {
+		return nil, err
+	}
+	to, err := e.wholeNumber(name, 3, args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	array := args[0].([]interface{})
+	start, end, err := sliceBounds(name, len(array), from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, end-start)
+	copy(result, array[start:end])
+	return result, nil
+}
+
+// macroRotated implementa (rotated: n, ...valori): sposta i valori di n posizioni a destra
+func macroRotated(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "any", "number", "any"); err != nil {
+		return nil, err
+	}
+	shift, err := e.wholeNumber(name, 1, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := args[1:]
+	if shift > len(values) || -shift > len(values) {
+		return nil, fmt.Errorf("I can't rotate these %d values by %d positions", len(values), shift)
+	}
+
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[((i+shift)%len(values)+len(values))%len(values)] = value
+	}
+	return result, nil
+}
+
+// macroDatamapNames implementa (dm-names: datamap): i nomi in ordine alfabetico
+func macroDatamapNames(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "datamap"); err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, key := range datamapNames(args[0].(map[string]interface{})) {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// macroDatamapValues implementa (dm-values: datamap): i valori nell'ordine dei nomi
+func macroDatamapValues(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "datamap"); err != nil {
+		return nil, err
+	}
+	datamap := args[0].(map[string]interface{})
+	result := []interface{}{}
+	for _, key := range datamapNames(datamap) {
+		result = append(result, datamap[key])
+	}
+	return result, nil
+}
+
+// datamapNames restituisce i nomi di un datamap in ordine alfabetico
+func datamapNames(datamap map[string]interface{}) []string {
+	keys := make([]string, 0, len(datamap))
+	for key := range datamap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// macroCount implementa (count: array o stringa, ...valori): quante volte compaiono i valori
+// In una stringa si contano le sottostringhe non sovrapposte
+func macroCount(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "any", "array|string", "any"); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for i, value := range args[1:] {
+		switch container := args[0].(type) {
+		case string:
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("(%s:)'s %s value is %s, but should be a string, because the 1st value is a string",
+					name, ordinalName(i+2), e.objectName(value))
+			}
+			if str != "" {
+				total += strings.Count(container, str)
+			}
+		case []interface{}:
+			for _, item := range container {
+				if e.areEqual(item, value) {
+					total++
+				}
+			}
+		}
+	}
+	return float64(total), nil
+}
+
+// ============================================
+// MACRO CON LAMBDA
+// ============================================
+
+// macroFind implementa (find: _x where ..., ...valori): i valori che passano la lambda
+func macroFind(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "any", "lambda"); err != nil {
+		return nil, err
+	}
+	lambda, err := e.lambdaArg(name, 1, args[0], []string{"where"})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []interface{}{}
+	for _, value := range args[1:] {
+		passed, err := e.lambdaPasses(lambda, value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if passed {
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+// macroAltered implementa (altered: _x via ..., ...valori): i valori trasformati dalla lambda
+// Con una clausola where vengono trasformati solo i valori che la passano
+func macroAltered(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "any", "lambda"); err != nil {
+		return nil, err
+	}
+	lambda, err := e.lambdaArg(name, 1, args[0], []string{"via"}, "where")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, 0, len(args)-1)
+	for _, value := range args[1:] {
+		passed, err := e.lambdaPasses(lambda, value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if passed {
+			if value, err = e.lambdaVia(lambda, value, nil); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// macroFolded implementa (folded: _x making _tot via ..., iniziale, ...valori)
+// Il primo valore è il totale iniziale; la lambda lo aggiorna con ogni valore successivo
+func macroFolded(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "any", "lambda", "any"); err != nil {
+		return nil, err
+	}
+	lambda, err := e.lambdaArg(name, 1, args[0], []string{"making", "via"}, "where")
+	if err != nil {
+		return nil, err
+	}
+
+	total := args[1]
+	for _, value := range args[2:] {
+		passed, err := e.lambdaPasses(lambda, value, total)
+		if err != nil {
+			return nil, err
+		}
+		if !passed {
+			continue
+		}
+		if total, err = e.lambdaVia(lambda, value, total); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+// macroAllPass implementa (all-pass: _x where ..., ...valori)
+func macroAllPass(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	passed, total, err := countPassing(e, name, args)
+	return err == nil && passed == total, err
+}
+
+// macroSomePass implementa (some-pass: _x where ..., ...valori)
+func macroSomePass(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	passed, _, err := countPassing(e, name, args)
+	return err == nil && passed > 0, err
+}
+
+// macroNonePass implementa (none-pass: _x where ..., ...valori)
+func macroNonePass(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	passed, _, err := countPassing(e, name, args)
+	return err == nil && passed == 0, err
+}
+
+// countPassing conta i valori che passano la lambda where del primo argomento
+func countPassing(e *HarloweEvaluator, name string, args []interface{}) (int, int, error) {
+	if err := e.expectArgs(name, args, "any", "lambda"); err != nil {
+		return 0, 0, err
+	}
+	lambda, err := e.lambdaArg(name, 1, args[0], []string{"where"})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	passed := 0
+	for _, value := range args[1:] {
+		ok, err := e.lambdaPasses(lambda, value, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ok {
+			passed++
+		}
+	}
+	return passed, len(args) - 1, nil
+}
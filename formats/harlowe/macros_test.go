@@ -0,0 +1,159 @@
+package harlowe
+
+import (
+	"testing"
+)
+
+// ============================================
+// Test: Macro su array e datamap, lambda
+// ============================================
+
+func TestDataStructureMacros(t *testing.T) {
+	eval := NewHarloweEvaluator(map[string]interface{}{
+		"numeri": []interface{}{3.0, 1.0, 4.0, 1.0, 5.0},
+		"zaino":  map[string]interface{}{"spada": 1.0, "arco": 2.0},
+	})
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`(sorted: "b", 10, "a", 2) is (a: 2, 10, "a", "b")`, true},
+		{`(sorted: via -it, ...$numeri) is (a: 5, 4, 3, 1, 1)`, true},
+		{`(reversed: 1, 2, 3) is (a: 3, 2, 1)`, true},
+		{`(range: 5, 2) is (a: 2, 3, 4, 5)`, true},
+		{`(subarray: (a: 1, 2, 3, 4, 5), 2, 3) is (a: 2, 3)`, true},
+		{`(subarray: (a: 1, 2, 3, 4, 5), -2, -1) is (a: 4, 5)`, true},
+		{`(rotated: 1, 1, 2, 3) is (a: 3, 1, 2)`, true},
+		{`(rotated: -1, 1, 2, 3) is (a: 2, 3, 1)`, true},
+		{`(dm-names: $zaino) is (a: "arco", "spada")`, true},
+		{`(data-values: $zaino) is (a: 2, 1)`, true},
+		{`(count: $numeri, 1, 5)`, 3.0},
+		{`(count: "banana", "an")`, 2.0},
+		{`(find: _n where _n > 2, ...$numeri) is (a: 3, 4, 5)`, true},
+		{`(find: where it is 1, ...$numeri) is (a: 1, 1)`, true},
+		{`(altered: _n via _n * 2, 1, 2) is (a: 2, 4)`, true},
+		{`(altered: _n via _n * 10 where _n > 1, 1, 2) is (a: 1, 20)`, true},
+		{`(folded: _n making _tot via _tot + _n, 0, ...$numeri)`, 14.0},
+		{`(folded: _n making _tot via _tot + _n where _n > 1, 0, ...$numeri)`, 12.0},
+		{`(all-pass: _n where _n > 0, ...$numeri)`, true},
+		{`(some-pass: _n where _n > 4, ...$numeri)`, true},
+		{`(none-pass: _n where _n > 5, ...$numeri)`, true},
+	}
+
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+
+	errors := []struct {
+		expr     string
+		expected string
+	}{
+		{`(range: 1)`, "the (range:) macro needs 1 more value"},
+		{`(range: 1, "2")`, "(range:)'s 2nd value is the string \"2\", but should be a number"},
+		{`(range: 1, 99999999999999999999)`, "(range:)'s 2nd value is 1e+20, which is too far from 0 to be a safe whole number"},
+		{`(range: 1, 1000000)`, "(range:) can't make an array of 1000000 values: the most it can make is 100000"},
+		{`(subarray: (a: 1, 2, 3), 1, 99999999999999999999)`, "(subarray:)'s 3rd value is 1e+20, which is too far from 0 to be a safe whole number"},
+		{`(subarray: "abc", 1, 2)`, "(subarray:)'s 1st value is the string \"abc\", but should be an array"},
+		{`(dm-names: $zaino, 1)`, "the (dm-names:) macro was given 2 values, but needs only 1"},
+		{`(rotated: 4, 1, 2, 3)`, "I can't rotate these 3 values by 4 positions"},
+		{`(find: _n via _n, 1)`, "(find:)'s 1st value is a 'via' lambda, but should be a 'where' lambda"},
+		{`(folded: _n via _n, 0, 1)`, "(folded:)'s 1st value is a 'via' lambda, but should be a 'making ... via' lambda"},
+		{`(find: _n where _n + 1, 1)`, "this lambda's 'where' clause must evaluate to true or false, not the number 2"},
+		{`(find: _n where _m > 1, 1)`, "there isn't a temp variable named _m in this place"},
+	}
+
+	for _, test := range errors {
+		_, err := eval.EvaluateExpression(test.expr)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("[%s] Expected error %q, got %v", test.expr, test.expected, err)
+		}
+	}
+
+	if len(eval.temps) != 1 {
+		t.Errorf("Expected lambda scopes to be popped, got %d scopes", len(eval.temps))
+	}
+
+	t.Log("✅ Data structure macros and lambdas evaluate like Harlowe")
+}
+
+// ============================================
+// Test: Macro su stringhe e numeri, niente conversioni implicite
+// ============================================
+
+func TestStringAndNumberMacros(t *testing.T) {
+	eval := NewHarloweEvaluator(map[string]interface{}{
+		"oro": 12.0,
+	})
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`(str: $oro)`, "12"},
+		{`(str: "Oro: ", $oro, " ", true)`, "Oro: 12 true"},
+		{`(text: (a: 1, "b"))`, "1,b"},
+		{`(num: "3.5") + 1`, 4.5},
+		{`(number: " 7 ")`, 7.0},
+		{`(upperfirst: " élan")`, " Élan"},
+		{`(lowerfirst: "Oro")`, "oro"},
+		{`(uppercase: "oro")`, "ORO"},
+		{`(lowercase: "ORO")`, "oro"},
+		{`(substring: "spadaccino", 1, 5)`, "spada"},
+		{`(substring: "città", -2, -1)`, "tà"},
+		{`(str-repeated: 3, "ab")`, "ababab"},
+		{`(min: 4, -2, 7)`, -2.0},
+		{`(max: 4, -2, 7)`, 7.0},
+		{`(round: 2.5)`, 3.0},
+		{`(round: -2.5)`, -2.0},
+		{`(floor: 2.7)`, 2.0},
+		{`(ceil: 2.1)`, 3.0},
+		{`(abs: -3)`, 3.0},
+		{`(sqrt: 16)`, 4.0},
+		{`(pow: 2, 10)`, 1024.0},
+	}
+
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+
+	errors := []struct {
+		expr     string
+		expected string
+	}{
+		{`(num: "tre")`, `I couldn't convert the string "tre" to a number`},
+		{`(str: (dm: "a", 1))`, "(str:)'s 1st value is a datamap, but should be a number or a string or a boolean or an array"},
+		{`(min:)`, "the (min:) macro needs 1 more value"},
+		{`(max: 1, "2")`, `(max:)'s 2nd value is the string "2", but should be a number`},
+		{`(round: 1, 2)`, "the (round:) macro was given 2 values, but needs only 1"},
+		{`(sqrt: -1)`, "this mathematical expression doesn't compute"},
+		{`(str-repeated: -1, "a")`, "I can't repeat a string a negative number of times"},
+		{`"3" * 2`, `I can only multiply numbers, not the string "3"`},
+		{`$oro + "1"`, `I can only add numbers, not the string "1"`},
+		{`"1" + $oro`, "I can only use + to join strings, not the number 12"},
+		{`"5" > 3`, `I can only use > to compare numbers, not the string "5"`},
+	}
+
+	for _, test := range errors {
+		_, err := eval.EvaluateExpression(test.expr)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("[%s] Expected error %q, got %v", test.expr, test.expected, err)
+		}
+	}
+
+	t.Log("✅ String and number macros use Harlowe coercion rules")
+}
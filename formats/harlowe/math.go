@@ -0,0 +1,67 @@
+package harlowe
+
+import (
+	"fmt"
+	"math"
+)
+
+// ============================================
+// MACRO NUMERICHE: (min:), (max:), (round:), (pow:), ...
+// ============================================
+
+// macroMin implementa (min: ...numeri)
+func macroMin(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	return extremum(e, name, args, math.Min)
+}
+
+// macroMax implementa (max: ...numeri)
+func macroMax(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	return extremum(e, name, args, math.Max)
+}
+
+// extremum riduce i numeri con pick (math.Min o math.Max)
+func extremum(e *HarloweEvaluator, name string, args []interface{}, pick func(float64, float64) float64) (interface{}, error) {
+	if err := e.expectArgs(name, args, "number", "number"); err != nil {
+		return nil, err
+	}
+	result, _ := numberValue(args[0])
+	for _, arg := range args[1:] {
+		num, _ := numberValue(arg)
+		result = pick(result, num)
+	}
+	return result, nil
+}
+
+// mathMacro crea una macro con un solo numero, come (abs:) e (sqrt:)
+func mathMacro(fn func(float64) float64) macroFunc {
+	return func(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+		if err := e.expectArgs(name, args, "", "number"); err != nil {
+			return nil, err
+		}
+		num, _ := numberValue(args[0])
+		return computed(fn(num))
+	}
+}
+
+// round arrotonda come Math.round di JavaScript: le metà verso l'alto, anche per i negativi
+func round(num float64) float64 {
+	return math.Floor(num + 0.5)
+}
+
+// macroPow implementa (pow: base, esponente)
+func macroPow(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "number", "number"); err != nil {
+		return nil, err
+	}
+	base, _ := numberValue(args[0])
+	exponent, _ := numberValue(args[1])
+	return computed(math.Pow(base, exponent))
+}
+
+// computed rifiuta i risultati che non sono numeri, come (sqrt: -1)
+func computed(num float64) (interface{}, error) {
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return nil, fmt.Errorf("this mathematical expression doesn't compute")
+	}
+	return num, nil
+}
@@ -0,0 +1,181 @@
+package harlowe
+
+import (
+	"fmt"
+	"tweego-editor/formats"
+)
+
+// ============================================
+// MACRO DI NAVIGAZIONE E TRANSCLUSIONE
+// ============================================
+
+// navigationMacros associa le macro che collegano altri passaggi al tipo di collegamento
+var navigationMacros = map[string]formats.LinkKind{
+	"linkgoto":       formats.LinkKindLink,
+	"clickgoto":      formats.LinkKindLink,
+	"linkrevealgoto": formats.LinkKindLink,
+	"goto":           formats.LinkKindGoto,
+	"display":        formats.LinkKindDisplay,
+}
+
+// maxDisplayDepth limita le (display:) annidate, come un passaggio che mostra se stesso
+const maxDisplayDepth = 50
+
+// navigationLink valuta gli argomenti di una macro di navigazione nello stato corrente
+// isNavigation è false se la macro non collega un altro passaggio
+// (link-goto: "Testo", "Target") e (link-goto: "Target"); (click-goto: ?hook, "Target");
+// (goto: "Target") e (display: "Target") hanno un solo argomento
+func (e *HarloweEvaluator) navigationLink(macro *MacroNode) (link formats.LinkRef, isNavigation bool, err error) {
+	kind, isNavigation := navigationMacros[macroKey(macro.Name)]
+	if !isNavigation {
+		return link, false, nil
+	}
+
+	link = formats.LinkRef{Kind: kind, Offset: macro.Offset, Length: macro.Length}
+	if macro.Err != nil {
+		return link, true, macro.Err
+	}
+
+	args := macro.Args
+	if kind == formats.LinkKindLink && (len(args) < 1 || len(args) > 2) {
+		return link, true, fmt.Errorf("(%s:) needs a link text and a passage name", macro.Name)
+	}
+	if kind != formats.LinkKindLink && len(args) != 1 {
+		return link, true, fmt.Errorf("(%s:) needs exactly one passage name", macro.Name)
+	}
+
+	if link.Target, err = e.stringArg(macro.Name, args[len(args)-1]); err != nil {
+		return link, true, err
+	}
+	link.Text = link.Target
+
+	if len(args) == 2 {
+		if ref, ok := args[0].(*HookRefExpr); ok {
+			link.Text = "?" + ref.Name
+		} else if link.Text, err = e.stringArg(macro.Name, args[0]); err != nil {
+			return link, true, err
+		}
+	}
+	return link, true, nil
+}
+
+// stringArg valuta un argomento di macro che deve essere una stringa
+func (e *HarloweEvaluator) stringArg(macroName string, arg Expr) (string, error) {
+	value, err := e.evaluate(arg)
+	if err != nil {
+		return "", err
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("(%s:) needs a string, not %s", macroName, e.GetTypeName(value))
+	}
+	return str, nil
+}
+
+// ============================================
+// ESECUZIONE DI UN PASSAGGIO
+// ============================================
+
+// passageRun esegue il codice di un passaggio raccogliendo i collegamenti
+// (display:) esegue sul posto il passaggio mostrato; (goto:) interrompe l'esecuzione
+type passageRun struct {
+	eval    *HarloweEvaluator
+	links   []formats.ResolvedLink
+	errs    []error
+	depth   int  // (display:) annidate in corso
+	stopped bool // Un (goto:) ha lasciato il passaggio
+}
+
+// run esegue il contenuto in ordine di documento, solo negli hook mostrati
+func (r *passageRun) run(content string) {
+	walkErrs := NewConditionalHandler(r.eval).WalkHooks(ParseMarkup(content), func(node Node, hiddenBy *MacroNode) bool {
+		switch n := node.(type) {
+		case *MacroNode:
+			return r.runMacro(n, hiddenBy)
+		case *LinkNode:
+			r.addLink(formats.LinkRef{
+				Kind:   formats.LinkKindLink,
+				Text:   n.Text,
+				Target: n.Target,
+				Offset: n.Offset,
+				Length: n.Length,
+			}, hiddenBy)
+		}
+		return true
+	})
+	r.errs = append(r.errs, walkErrs...)
+}
+
+// runMacro esegue una macro; restituisce false se l'esecuzione del passaggio si ferma
+func (r *passageRun) runMacro(macro *MacroNode, hiddenBy *MacroNode) bool {
+	link, isNavigation, err := r.eval.navigationLink(macro)
+	if !isNavigation {
+		if hiddenBy == nil {
+			if err := r.eval.runMacro(macro); err != nil {
+				r.errs = append(r.errs, err)
+			}
+		}
+		return true
+	}
+
+	// Negli hook nascosti il target può dipendere da uno stato mai raggiunto: niente errori
+	if err != nil {
+		if hiddenBy == nil {
+			r.errs = append(r.errs, err)
+		}
+		return true
+	}
+
+	r.addLink(link, hiddenBy)
+	if hiddenBy != nil {
+		return true
+	}
+
+	switch link.Kind {
+	case formats.LinkKindGoto:
+		r.stopped = true
+		return false
+	case formats.LinkKindDisplay:
+		r.display(link.Target)
+		return !r.stopped
+	}
+	return true
+}
+
+// display esegue sul posto il contenuto del passaggio indicato
+func (r *passageRun) display(title string) {
+	content, exists := r.eval.passages[title]
+	if !exists {
+		r.errs = append(r.errs, fmt.Errorf("there's no passage named '%s' in this story", title))
+		return
+	}
+	if r.depth >= maxDisplayDepth {
+		r.errs = append(r.errs, fmt.Errorf("(display:) of '%s' is nested more than %d times", title, maxDisplayDepth))
+		return
+	}
+
+	// Il passaggio mostrato ha il suo scope di variabili temporanee, come un hook
+	r.depth++
+	r.eval.pushScope(nil)
+	r.run(content)
+	r.eval.popScope()
+	r.depth--
+}
+
+// addLink aggiunge un collegamento, non disponibile se è in un hook nascosto da una condizione
+// Gli hook di (link:), (click:), ... li mostra il giocatore, quindi i loro link restano disponibili;
+// un (goto:) lì dentro parte solo dopo l'azione del giocatore e vale come un link
+func (r *passageRun) addLink(ref formats.LinkRef, hiddenBy *MacroNode) {
+	if hiddenBy != nil && deferredChangers[macroKey(hiddenBy.Name)] {
+		if ref.Kind == formats.LinkKindGoto {
+			ref.Kind = formats.LinkKindLink
+		}
+		hiddenBy = nil
+	}
+
+	link := formats.ResolvedLink{LinkRef: ref, Available: hiddenBy == nil}
+	if hiddenBy != nil {
+		link.HiddenBy = hiddenBy.Raw
+	}
+	r.links = append(r.links, link)
+}
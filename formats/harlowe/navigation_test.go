@@ -0,0 +1,86 @@
+package harlowe
+
+import (
+	"testing"
+
+	"tweego-editor/formats"
+)
+
+// ============================================
+// Test: Macro di navigazione e transclusione
+// ============================================
+
+func TestFindLinksNavigationMacros(t *testing.T) {
+	h := NewHarloweFormat()
+	content := `[[Nord]] (link-goto: "Vai a sud", "Sud") (link-goto: "Est") (click-goto: ?porta, "Ovest")
+(display: "Mappa") (goto: "Fine") (goto: $destinazione)`
+
+	expected := []formats.LinkRef{
+		{Kind: formats.LinkKindLink, Text: "Nord", Target: "Nord"},
+		{Kind: formats.LinkKindLink, Text: "Vai a sud", Target: "Sud"},
+		{Kind: formats.LinkKindLink, Text: "Est", Target: "Est"},
+		{Kind: formats.LinkKindLink, Text: "?porta", Target: "Ovest"},
+		{Kind: formats.LinkKindDisplay, Text: "Mappa", Target: "Mappa"},
+		{Kind: formats.LinkKindGoto, Text: "Fine", Target: "Fine"},
+	}
+
+	links := h.FindLinks(content)
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d: %v", len(expected), len(links), links)
+	}
+	for i, link := range links {
+		if link.Kind != expected[i].Kind || link.Text != expected[i].Text || link.Target != expected[i].Target {
+			t.Errorf("Link %d: expected %+v, got %+v", i, expected[i], link)
+		}
+		if content[link.Offset:link.Offset+link.Length] == "" {
+			t.Errorf("Link %d: empty span", i)
+		}
+	}
+
+	targets := h.ParseLinks(content)
+	if len(targets) != 5 || targets[4] != "Fine" {
+		t.Errorf("Expected navigable targets without (display:), got %v", targets)
+	}
+
+	t.Log("✅ Navigation macros extracted as typed links")
+}
+
+func TestProcessPassageLinksDisplayAndGoto(t *testing.T) {
+	h := NewHarloweFormat()
+	eval := NewHarloweEvaluator(map[string]interface{}{"oro": 5.0})
+	eval.SetPassages(map[string]string{
+		"Bottega": `(set: $oro to it - 1)(if: $oro > 0)[[[Compra]]]`,
+	})
+
+	links, err := h.ProcessPassageLinks(`(display: "Bottega")(set: $dest to "Uscita")(goto: $dest)(set: $dopo to true)[[Mai]]`, eval)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	state := eval.GetState()
+	if state["oro"] != 4.0 {
+		t.Errorf("Expected (display:) to run the passage code in place, got oro=%v", state["oro"])
+	}
+	if _, exists := state["dopo"]; exists {
+		t.Error("Expected code after (goto:) not to run")
+	}
+
+	kinds := []formats.LinkKind{}
+	for _, link := range links {
+		kinds = append(kinds, link.Kind)
+	}
+	if len(links) != 3 || links[1].Target != "Compra" || links[2].Kind != formats.LinkKindGoto || links[2].Target != "Uscita" {
+		t.Errorf("Expected display, displayed link and goto, got %v %v", kinds, links)
+	}
+
+	if _, err := h.ProcessPassageLinks(`(display: "Nessuno")`, eval); err == nil {
+		t.Error("Expected error for (display:) of a missing passage")
+	}
+
+	eval.SetPassages(map[string]string{"Loop": `(display: "Loop")`})
+	if _, err := h.ProcessPassageLinks(`(display: "Loop")`, eval); err == nil {
+		t.Error("Expected error for infinite (display:) recursion")
+	}
+
+	t.Log("✅ (display:) runs in place and (goto:) stops the passage")
+}
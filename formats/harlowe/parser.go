@@ -1,6 +1,7 @@
 package harlowe
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -26,169 +27,130 @@ func (h *HarloweFormat) CreateEvaluator(initialState map[string]interface{}) for
 	return NewHarloweEvaluator(initialState)
 }
 
-// ParseLinks estrae i link [[...]] dal contenuto
+// ParseLinks estrae i target dei link e dei (goto:) dal contenuto
+// Le transclusioni con (display:) non portano a un altro passaggio e vengono escluse
 func (h *HarloweFormat) ParseLinks(content string) []string {
-	linkRegex := regexp.MustCompile(`\[\[([^\]]+)\]\]`)
-	matches := linkRegex.FindAllStringSubmatch(content, -1)
-	
 	links := []string{}
-	for _, match := range matches {
-		if len(match) > 1 {
-			// Gestisce sia [[Link]] che [[Testo->Link]]
-			linkParts := strings.Split(match[1], "->")
-			if len(linkParts) == 1 {
-				// Prova anche con <-
-				linkParts = strings.Split(match[1], "<-")
-			}
-			linkTarget := strings.TrimSpace(linkParts[len(linkParts)-1])
-			links = append(links, linkTarget)
+	for _, link := range h.FindLinks(content) {
+		if link.Kind != formats.LinkKindDisplay {
+			links = append(links, link.Target)
 		}
 	}
-	
 	return links
 }
 
-// ParseVariables estrae variabili (set:, put:, move:) dal contenuto
-// USA ARCHITETTURA MODULARE: Parser → Literals → Evaluator
-func (h *HarloweFormat) ParseVariables(content string) map[string]interface{} {
-	// Crea evaluator con stato vuoto
+// FindLinks estrae i collegamenti con tipo, testo, target e offset nel contenuto
+// Gestisce [[Link]], [[Testo->Link]] e [[Link<-Testo]], le macro (link-goto:), (click-goto:),
+// (link-reveal-goto:), (goto:) e (display:). Le macro il cui target non si può calcolare
+// senza stato (es. (goto: $destinazione)) vengono saltate
+func (h *HarloweFormat) FindLinks(content string) []formats.LinkRef {
 	eval := NewHarloweEvaluator(nil)
-	
-	// 1. Parse (set:) macro
-	h.parseSetMacro(content, eval)
-	
-	// 2. Parse (put:) macro
-	h.parsePutMacro(content, eval)
-	
-	// 3. Parse (move:) macro
-	h.parseMoveMacro(content, eval)
-	
-	// Restituisci stato finale
-	return eval.GetState()
-}
-
-// parseSetMacro gestisce (set: $var to value, $var2 to value2, ...)
-func (h *HarloweFormat) parseSetMacro(content string, eval *HarloweEvaluator) {
-	setRegex := regexp.MustCompile(`\(set:\s*`)
-	indices := setRegex.FindAllStringIndex(content, -1)
-	
-	for _, idx := range indices {
-		start := idx[1]
-		end := h.findMatchingParen(content, idx[0])
-		if end == -1 {
-			continue
-		}
-		
-		assignmentContent := content[start:end]
-		assignments := smartSplitComma(assignmentContent)
-		
-		for _, assignment := range assignments {
-			if err := ParseAssignment(assignment, eval); err != nil {
-				_ = err
+	links := []formats.LinkRef{}
+	walkNodes(ParseMarkup(content), func(node Node) bool {
+		switch n := node.(type) {
+		case *LinkNode:
+			links = append(links, formats.LinkRef{
+				Kind:   formats.LinkKindLink,
+				Text:   n.Text,
+				Target: n.Target,
+				Offset: n.Offset,
+				Length: n.Length,
+			})
+		case *MacroNode:
+			if link, isNavigation, err := eval.navigationLink(n); isNavigation && err == nil {
+				links = append(links, link)
 			}
 		}
-	}
+		return true
+	})
+	return links
 }
 
-// findMatchingParen trova la parentesi chiusa corrispondente
-func (h *HarloweFormat) findMatchingParen(content string, openPos int) int {
-	if openPos >= len(content) || content[openPos] != '(' {
-		return -1
-	}
-	
-	depth := 1
-	inString := false
-	
-	for i := openPos + 1; i < len(content); i++ {
-		char := content[i]
-		
-		if char == '"' && (i == 0 || content[i-1] != '\\') {
-			inString = !inString
-			continue
-		}
-		
-		if inString {
-			continue
+// FindMacros estrae tutte le macro (nome: ...) con offset nel contenuto
+// Le macro annidate vengono restituite dopo la macro che le contiene
+func (h *HarloweFormat) FindMacros(content string) []formats.MacroRef {
+	macros := []formats.MacroRef{}
+	walkNodes(ParseMarkup(content), func(node Node) bool {
+		macro, ok := node.(*MacroNode)
+		if !ok {
+			return true
 		}
-		
-		if char == '(' {
-			depth++
-		} else if char == ')' {
-			depth--
-			if depth == 0 {
-				return i
-			}
+
+		macros = append(macros, formats.MacroRef{
+			Name:   macro.Name,
+			Raw:    macro.Raw,
+			Offset: macro.Offset,
+			Length: macro.Length,
+		})
+		for _, arg := range macro.Args {
+			walkExpr(arg, func(expr Expr) {
+				if inner, ok := expr.(*MacroExpr); ok {
+					macros = append(macros, formats.MacroRef{
+						Name:   inner.Name,
+						Raw:    inner.Raw,
+						Offset: inner.Offset,
+						Length: inner.Length,
+					})
+				}
+			})
 		}
-	}
-	
-	return -1
+		return true
+	})
+	return macros
 }
 
-// parsePutMacro gestisce (put: value into $var)
-func (h *HarloweFormat) parsePutMacro(content string, eval *HarloweEvaluator) {
-	putRegex := regexp.MustCompile(`\(put:\s*`)
-	indices := putRegex.FindAllStringIndex(content, -1)
-	
-	for _, idx := range indices {
-		start := idx[1]
-		end := h.findMatchingParen(content, start-1)
-		if end == -1 {
-			continue
-		}
-		
-		putContent := content[start:end]
-		parts := strings.Split(putContent, " into ")
-		if len(parts) != 2 {
-			continue
-		}
-		
-		valueExpr := strings.TrimSpace(parts[0])
-		target := strings.TrimSpace(parts[1])
-		
-		value, err := ParseValue(valueExpr, eval)
-		if err != nil {
-			value = valueExpr
-		}
-		
-		eval.Put(value, target)
+// ParseVariables estrae variabili (set:, put:, move:) dal contenuto
+// USA ARCHITETTURA MODULARE: Parser → AST → Evaluator
+// Le variabili temporanee compaiono con il loro sigillo: "_nome"
+func (h *HarloweFormat) ParseVariables(content string) map[string]interface{} {
+	// Crea evaluator con stato vuoto
+	eval := NewHarloweEvaluator(nil)
+
+	// Esegue (set:), (put:) e (move:) in ordine di documento; gli errori vengono ignorati
+	h.runMacros(ParseMarkup(content), eval)
+
+	// Restituisci stato finale
+	variables := eval.GetState()
+	for name, value := range eval.GetTempState() {
+		variables["_"+name] = value
 	}
+	return variables
 }
 
-// parseMoveMacro gestisce (move: $source into $dest)
-func (h *HarloweFormat) parseMoveMacro(content string, eval *HarloweEvaluator) {
-	moveRegex := regexp.MustCompile(`\(move:\s*`)
-	indices := moveRegex.FindAllStringIndex(content, -1)
-	
-	for _, idx := range indices {
-		start := idx[1]
-		end := h.findMatchingParen(content, start-1)
-		if end == -1 {
-			continue
-		}
-		
-		moveContent := content[start:end]
-		parts := strings.Split(moveContent, " into ")
-		if len(parts) != 2 {
-			continue
+// runMacros esegue le macro che modificano lo stato in ordine di documento
+// Restituisce gli errori incontrati senza interrompere l'esecuzione
+func (h *HarloweFormat) runMacros(nodes []Node, eval *HarloweEvaluator) []error {
+	errs := []error{}
+	walkNodes(nodes, func(node Node) bool {
+		if macro, ok := node.(*MacroNode); ok {
+			if err := eval.runMacro(macro); err != nil {
+				errs = append(errs, err)
+			}
 		}
-		
-		source := strings.TrimSpace(parts[0])
-		dest := strings.TrimSpace(parts[1])
-		
-		eval.Move(source, dest)
-	}
+		return true
+	})
+	return errs
 }
 
 // StripCode rimuove macro e codice Harlowe
 func (h *HarloweFormat) StripCode(content string) string {
-	macroRegex := regexp.MustCompile(`\([^)]+\)`)
-	cleaned := macroRegex.ReplaceAllString(content, "")
-	
+	var sb strings.Builder
+	walkNodes(ParseMarkup(content), func(node Node) bool {
+		switch n := node.(type) {
+		case *TextNode:
+			sb.WriteString(n.Text)
+		case *LinkNode, *VariableNode:
+			offset, length := n.Span()
+			sb.WriteString(content[offset : offset+length])
+		}
+		return true
+	})
+
 	htmlRegex := regexp.MustCompile(`<[^>]+>`)
-	cleaned = htmlRegex.ReplaceAllString(cleaned, "")
-	
+	cleaned := htmlRegex.ReplaceAllString(sb.String(), "")
+
 	cleaned = strings.Join(strings.Fields(cleaned), " ")
-	
+
 	return strings.TrimSpace(cleaned)
 }
 
@@ -234,13 +196,9 @@ func (h *HarloweFormat) ParseDatasetLiteral(content string) []interface{} {
 
 // FindAllArrayLiterals trova tutti gli array literals nel contenuto
 func (h *HarloweFormat) FindAllArrayLiterals(content string) [][]interface{} {
-	regex := regexp.MustCompile(`\(a:|array:\s*[^\)]+\)`)
-	matches := regex.FindAllString(content, -1)
-	
 	results := [][]interface{}{}
-	for _, match := range matches {
-		arr := h.ParseArrayLiteral(match)
-		if len(arr) > 0 {
+	for _, literal := range h.findLiterals(content, "a", "array") {
+		if arr, ok := literal.Parsed.([]interface{}); ok && len(arr) > 0 {
 			results = append(results, arr)
 		}
 	}
@@ -249,13 +207,9 @@ func (h *HarloweFormat) FindAllArrayLiterals(content string) [][]interface{} {
 
 // FindAllDatamapLiterals trova tutti i datamap literals nel contenuto
 func (h *HarloweFormat) FindAllDatamapLiterals(content string) []map[string]interface{} {
-	regex := regexp.MustCompile(`\(dm:|datamap:\s*[^\)]+\)`)
-	matches := regex.FindAllString(content, -1)
-	
 	results := []map[string]interface{}{}
-	for _, match := range matches {
-		dm := h.ParseDatamapLiteral(match)
-		if len(dm) > 0 {
+	for _, literal := range h.findLiterals(content, "dm", "datamap") {
+		if dm, ok := literal.Parsed.(map[string]interface{}); ok && len(dm) > 0 {
 			results = append(results, dm)
 		}
 	}
@@ -264,12 +218,9 @@ func (h *HarloweFormat) FindAllDatamapLiterals(content string) []map[string]inte
 
 // FindAllDatasetLiterals trova tutti i dataset literals nel contenuto
 func (h *HarloweFormat) FindAllDatasetLiterals(content string) [][]interface{} {
-	regex := regexp.MustCompile(`\(ds:|dataset:\s*[^\)]+\)`)
-	matches := regex.FindAllString(content, -1)
-	
 	results := [][]interface{}{}
-	for _, match := range matches {
-		ds := h.ParseDatasetLiteral(match)
+	for _, literal := range h.findLiterals(content, "ds", "dataset") {
+		ds := h.ParseDatasetLiteral(literal.Raw)
 		if len(ds) > 0 {
 			results = append(results, ds)
 		}
@@ -279,69 +230,56 @@ func (h *HarloweFormat) FindAllDatasetLiterals(content string) [][]interface{} {
 
 // ExtractAllLiterals estrae tutti i literals con raw + parsed
 func (h *HarloweFormat) ExtractAllLiterals(content string) *formats.LiteralsResult {
-	result := &formats.LiteralsResult{
-		Arrays:   []formats.LiteralInfo{},
-		Datamaps: []formats.LiteralInfo{},
-		Datasets: []formats.LiteralInfo{},
+	return &formats.LiteralsResult{
+		Arrays:   h.findLiterals(content, "a", "array"),
+		Datamaps: h.findLiterals(content, "dm", "datamap"),
+		Datasets: h.findLiterals(content, "ds", "dataset"),
 	}
-	
+}
+
+// findLiterals valuta le macro literal con uno dei nomi indicati, anche annidate
+// Le macro che non si possono valutare senza stato (es. con variabili) vengono saltate
+func (h *HarloweFormat) findLiterals(content string, names ...string) []formats.LiteralInfo {
 	eval := NewHarloweEvaluator(nil)
-	
-	// Arrays
-	arrayRegex := regexp.MustCompile(`\((a|array):[^\)]+\)`)
-	arrayMatches := arrayRegex.FindAllString(content, -1)
-	for _, raw := range arrayMatches {
-		parsed, err := ParseArrayLiteral(raw, eval)
-		if err == nil {
-			result.Arrays = append(result.Arrays, formats.LiteralInfo{
-				Raw:    raw,
-				Parsed: parsed,
-			})
-		}
-	}
-	
-	// Datamaps
-	datamapRegex := regexp.MustCompile(`\((dm|datamap):[^\)]+\)`)
-	datamapMatches := datamapRegex.FindAllString(content, -1)
-	for _, raw := range datamapMatches {
-		parsed, err := ParseDatamapLiteral(raw, eval)
-		if err == nil {
-			result.Datamaps = append(result.Datamaps, formats.LiteralInfo{
-				Raw:    raw,
-				Parsed: parsed,
-			})
+	literals := []formats.LiteralInfo{}
+
+	walkMacroExprs(ParseMarkup(content), func(macro *MacroExpr) {
+		if !isMacroNamed(macro.Name, names...) {
+			return
 		}
-	}
-	
-	// Datasets
-	datasetRegex := regexp.MustCompile(`\((ds|dataset):[^\)]+\)`)
-	datasetMatches := datasetRegex.FindAllString(content, -1)
-	for _, raw := range datasetMatches {
-		parsed, err := ParseDatasetLiteral(raw, eval)
+		parsed, err := eval.evaluate(macro)
 		if err == nil {
-			result.Datasets = append(result.Datasets, formats.LiteralInfo{
-				Raw:    raw,
+			literals = append(literals, formats.LiteralInfo{
+				Raw:    macro.Raw,
 				Parsed: parsed,
 			})
 		}
-	}
-	
-	return result
+	})
+	return literals
 }
 
 // ProcessPassageContent processa il contenuto di un passaggio
 // modificando lo stato dell'evaluator passato
 func (h *HarloweFormat) ProcessPassageContent(content string, eval formats.Evaluator) error {
+	_, err := h.ProcessPassageLinks(content, eval)
+	return err
+}
+
+// ProcessPassageLinks processa il contenuto di un passaggio e restituisce i suoi collegamenti
+// I collegamenti negli hook nascosti sono non disponibili e riportano la macro che li nasconde.
+// (display:) esegue sul posto il passaggio mostrato; dopo un (goto:) non viene eseguito altro
+func (h *HarloweFormat) ProcessPassageLinks(content string, eval formats.Evaluator) ([]formats.ResolvedLink, error) {
 	// Cast a HarloweEvaluator per accedere ai metodi specifici
 	harloweEval, ok := eval.(*HarloweEvaluator)
 	if !ok {
-		return fmt.Errorf("evaluator non è di tipo HarloweEvaluator")
+		return nil, fmt.Errorf("evaluator non è di tipo HarloweEvaluator")
 	}
 
-	// Parse le varie macro che modificano lo stato
-	h.parseSetMacro(content, harloweEval)
-	h.parsePutMacro(content, harloweEval)
-	h.parseMoveMacro(content, harloweEval)
+	run := &passageRun{eval: harloweEval, links: []formats.ResolvedLink{}}
+	run.run(content)
 
-	return nil
-}
\ No newline at end of file
+	if len(run.errs) > 0 {
+		return run.links, errors.Join(run.errs...)
+	}
+	return run.links, nil
+}
@@ -0,0 +1,77 @@
+package harlowe
+
+import (
+	"fmt"
+	"math"
+)
+
+// ============================================
+// MACRO CASUALI: (either:), (random:), (shuffled:), (seed:)
+// ============================================
+
+// maxSafeInteger è il più grande intero che JavaScript rappresenta esattamente (2^53)
+// Oltre questo valore Harlowe non garantisce numeri interi, e Intn andrebbe in overflow
+const maxSafeInteger = 1 << 53
+
+// macroEither implementa (either: ...valori): uno dei valori, scelto a caso
+func macroEither(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("(either:) needs at least one value")
+	}
+	return args[e.random.Intn(len(args))], nil
+}
+
+// macroRandom implementa (random: a, b): un intero tra a e b inclusi, in qualsiasi ordine
+func macroRandom(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("(random:) needs exactly 2 whole numbers")
+	}
+
+	bounds := [2]float64{}
+	for i, arg := range args {
+		num, ok := numberValue(arg)
+		if !ok {
+			return nil, fmt.Errorf("(random:) needs a number, not %s", e.GetTypeName(arg))
+		}
+		if num != math.Trunc(num) {
+			return nil, fmt.Errorf("(random:) needs whole numbers, not %s", ConvertToString(num))
+		}
+		if math.Abs(num) > maxSafeInteger {
+			return nil, fmt.Errorf("(random:) can't use %s: it is too far from 0 to be a safe whole number", ConvertToString(num))
+		}
+		bounds[i] = num
+	}
+
+	low, high := int64(math.Min(bounds[0], bounds[1])), int64(math.Max(bounds[0], bounds[1]))
+	n := int(high - low + 1)
+	if n <= 0 {
+		return nil, fmt.Errorf("(random:) can't choose between %d and %d: the range is too large", low, high)
+	}
+	return float64(low + int64(e.random.Intn(n))), nil
+}
+
+// macroShuffled implementa (shuffled: ...valori): un array con i valori in ordine casuale
+// Ogni scelta del generatore fissa l'elemento di una posizione, dall'ultima alla seconda
+func macroShuffled(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	result := make([]interface{}, len(args))
+	copy(result, args)
+
+	for i := len(result) - 1; i > 0; i-- {
+		j := e.random.Intn(i + 1)
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
+
+// seed implementa (seed: "stringa"): da qui in poi le scelte casuali dipendono solo dalla stringa
+func (e *HarloweEvaluator) seed(macro *MacroNode) error {
+	if len(macro.Args) != 1 {
+		return fmt.Errorf("(seed:) needs exactly one string")
+	}
+	seed, err := e.stringArg(macro.Name, macro.Args[0])
+	if err != nil {
+		return err
+	}
+	e.random.Seed(seed)
+	return nil
+}
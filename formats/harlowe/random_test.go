@@ -0,0 +1,64 @@
+package harlowe
+
+import (
+	"testing"
+)
+
+// fixedRandom restituisce sempre l'ultima alternativa e registra i seed
+type fixedRandom struct {
+	seeds []string
+}
+
+func (r *fixedRandom) Intn(n int) int   { return n - 1 }
+func (r *fixedRandom) Seed(seed string) { r.seeds = append(r.seeds, seed) }
+
+// ============================================
+// Test: Macro casuali con generatore iniettato
+// ============================================
+
+func TestRandomMacros(t *testing.T) {
+	random := &fixedRandom{}
+	eval := NewHarloweEvaluator(nil)
+	eval.SetRandom(random)
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`(either: "a", "b", "c")`, "c"},
+		{`(either: ...(a: 1, 2))`, 2.0},
+		{`(random: 10, 1)`, 10.0},
+		{`(random: -2, 2) + 0`, 2.0},
+		{`(shuffled: 1, 2, 3) is (a: 1, 2, 3)`, true},
+	}
+
+	for _, test := range tests {
+		result, err := eval.EvaluateExpression(test.expr)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("[%s] Expected %v, got %v", test.expr, test.expected, result)
+		}
+	}
+
+	for _, expr := range []string{`(either:)`, `(random: 1)`, `(random: 1, "2")`, `(random: 1, 2.5)`, `(random: 0, 99999999999999999999)`, `(random: -9007199254740994, 0)`} {
+		if _, err := eval.EvaluateExpression(expr); err == nil {
+			t.Errorf("[%s] Expected error", expr)
+		}
+	}
+
+	h := NewHarloweFormat()
+	if err := h.ProcessPassageContent(`(seed: "partita-1")(set: $d to (random: 1, 6))`, eval); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(random.seeds) != 1 || random.seeds[0] != "partita-1" {
+		t.Errorf("Expected (seed:) to reseed the generator, got %v", random.seeds)
+	}
+	if eval.GetState()["d"] != 6.0 {
+		t.Errorf("Expected d = 6, got %v", eval.GetState()["d"])
+	}
+
+	t.Log("✅ Random macros use the injected generator")
+}
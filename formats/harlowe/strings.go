@@ -0,0 +1,139 @@
+package harlowe
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ============================================
+// MACRO SULLE STRINGHE: (str:), (num:), (substring:), ...
+// ============================================
+
+// macroStr implementa (str: ...valori): unisce i valori in una stringa
+// Numeri e booleani vengono scritti come in Harlowe, gli array con i valori separati da virgole
+func macroStr(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "number|string|boolean|array"); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(printValue(arg))
+	}
+	return sb.String(), nil
+}
+
+// printValue scrive un valore come lo stampa Harlowe: stringhe senza virgolette, array separati da virgole
+func printValue(value interface{}) string {
+	if array, ok := value.([]interface{}); ok {
+		parts := make([]string, len(array))
+		for i, item := range array {
+			parts[i] = printValue(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return ConvertToString(value)
+}
+
+// macroNum implementa (num: stringa): il numero scritto nella stringa
+// A differenza degli operatori, è l'unico modo per usare una stringa come numero
+func macroNum(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string|number"); err != nil {
+		return nil, err
+	}
+	if num, ok := numberValue(args[0]); ok {
+		return num, nil
+	}
+
+	str := args[0].(string)
+	num, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || math.IsInf(num, 0) || math.IsNaN(num) {
+		return nil, fmt.Errorf("I couldn't convert %s to a number", e.objectName(str))
+	}
+	return num, nil
+}
+
+// macroUppercase implementa (uppercase: stringa)
+func macroUppercase(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string"); err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(args[0].(string)), nil
+}
+
+// macroLowercase implementa (lowercase: stringa)
+func macroLowercase(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string"); err != nil {
+		return nil, err
+	}
+	return strings.ToLower(args[0].(string)), nil
+}
+
+// macroUpperfirst implementa (upperfirst: stringa): la prima lettera maiuscola
+func macroUpperfirst(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string"); err != nil {
+		return nil, err
+	}
+	return mapFirstLetter(args[0].(string), unicode.ToUpper), nil
+}
+
+// macroLowerfirst implementa (lowerfirst: stringa): la prima lettera minuscola
+func macroLowerfirst(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string"); err != nil {
+		return nil, err
+	}
+	return mapFirstLetter(args[0].(string), unicode.ToLower), nil
+}
+
+// mapFirstLetter applica fn alla prima lettera, saltando spazi e punteggiatura iniziali
+func mapFirstLetter(str string, fn func(rune) rune) string {
+	runes := []rune(str)
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			runes[i] = fn(r)
+			break
+		}
+	}
+	return string(runes)
+}
+
+// macroSubstring implementa (substring: stringa, da, a) con posizioni come (subarray:)
+// Le posizioni contano i caratteri, non i byte
+func macroSubstring(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "string", "number", "number"); err != nil {
+		return nil, err
+	}
+	from, err := e.wholeNumber(name, 2, args[1])
+	if err != nil {
+		return nil, err
+	}
+	to, err := e.wholeNumber(name, 3, args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(args[0].(string))
+	start, end, err := sliceBounds(name, len(runes), from, to)
+	if err != nil {
+		return nil, err
+	}
+	return string(runes[start:end]), nil
+}
+
+// macroStrRepeated implementa (str-repeated: volte, stringa)
+func macroStrRepeated(e *HarloweEvaluator, name string, args []interface{}) (interface{}, error) {
+	if err := e.expectArgs(name, args, "", "number", "string"); err != nil {
+		return nil, err
+	}
+	count, err := e.wholeNumber(name, 1, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("I can't repeat a string a negative number of times")
+	}
+	return strings.Repeat(args[1].(string), count), nil
+}
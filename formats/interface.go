@@ -14,6 +14,42 @@ type LiteralsResult struct {
 	Datasets []LiteralInfo `json:"datasets"`
 }
 
+// LinkKind è il tipo di collegamento tra due passaggi
+type LinkKind string
+
+const (
+	LinkKindLink    LinkKind = "link"    // Link cliccato dal giocatore: [[...]], (link-goto:), ...
+	LinkKindGoto    LinkKind = "goto"    // Navigazione forzata appena il codice viene eseguito
+	LinkKindDisplay LinkKind = "display" // Transclusione: il passaggio viene mostrato in quello corrente
+)
+
+// LinkRef rappresenta un link trovato nel contenuto di un passaggio
+// Offset e Length sono in byte, relativi al contenuto del passaggio
+type LinkRef struct {
+	Kind   LinkKind `json:"kind"`
+	Text   string   `json:"text"`
+	Target string   `json:"target"`
+	Offset int      `json:"offset"`
+	Length int      `json:"length"`
+}
+
+// ResolvedLink è un link valutato rispetto allo stato della simulazione
+// HiddenBy è il codice che nasconde il link, es. "(if: $chiave)", vuoto se il link è cliccabile
+type ResolvedLink struct {
+	LinkRef
+	Available bool   `json:"available"`
+	HiddenBy  string `json:"hidden_by,omitempty"`
+}
+
+// MacroRef rappresenta una macro trovata nel contenuto di un passaggio
+// Offset e Length sono in byte, relativi al contenuto del passaggio
+type MacroRef struct {
+	Name   string `json:"name"`
+	Raw    string `json:"raw"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
 // ============================================
 // EVALUATOR INTERFACE (NUOVO!)
 // ============================================
@@ -24,6 +60,7 @@ type Evaluator interface {
 	// Gestione stato variabili
 	GetState() map[string]interface{}
 	SetState(state map[string]interface{})
+	GetTempState() map[string]interface{} // Variabili temporanee del passaggio, separate dallo stato
 
 	// Valutazione espressioni
 	EvaluateExpression(expression string) (interface{}, error)
@@ -33,6 +70,8 @@ type Evaluator interface {
 	SetVisitedPassages(visited map[string]int)
 	SetHistory(history []string)
 	SetCurrentPassage(passageName string)
+	SetPassages(passages map[string]string) // Contenuto dei passaggi per titolo, per le transclusioni
+	SetRandom(random Random)                // Generatore delle scelte casuali
 }
 
 // ============================================
@@ -54,10 +93,22 @@ type StoryFormat interface {
 	// i passaggi senza duplicare la logica di parsing
 	ProcessPassageContent(content string, eval Evaluator) error
 
+	// ProcessPassageLinks processa il contenuto come ProcessPassageContent
+	// e restituisce i link con la loro disponibilità nello stato del momento
+	// in cui vengono mostrati. Le transclusioni vengono eseguite sul posto;
+	// una navigazione forzata disponibile interrompe il processing
+	ProcessPassageLinks(content string, eval Evaluator) ([]ResolvedLink, error)
 
-	// ParseLinks estrae i collegamenti dal contenuto
+	// ParseLinks estrae i target dei collegamenti verso cui si può navigare
+	// (link e navigazioni forzate, non le transclusioni)
 	ParseLinks(content string) []string
 
+	// FindLinks estrae tutti i collegamenti con tipo, testo e posizione nel contenuto
+	FindLinks(content string) []LinkRef
+
+	// FindMacros estrae tutte le macro con la loro posizione nel contenuto
+	FindMacros(content string) []MacroRef
+
 	// ParseVariables estrae le variabili dal contenuto
 	ParseVariables(content string) map[string]interface{}
 
@@ -0,0 +1,38 @@
+package formats
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Random fornisce le scelte casuali agli evaluator
+// Iniettarlo permette di riprodurre una simulazione o di enumerarne tutti gli esiti
+type Random interface {
+	// Intn restituisce un intero in [0, n)
+	Intn(n int) int
+
+	// Seed reimposta il generatore a partire da una stringa, come (seed:) di Harlowe
+	Seed(seed string)
+}
+
+// SeededRandom è un Random deterministico: lo stesso seed produce le stesse scelte
+type SeededRandom struct {
+	rng *rand.Rand
+}
+
+// NewSeededRandom crea un generatore con il seed indicato
+func NewSeededRandom(seed int64) *SeededRandom {
+	return &SeededRandom{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Intn restituisce un intero in [0, n)
+func (r *SeededRandom) Intn(n int) int {
+	return r.rng.Intn(n)
+}
+
+// Seed reimposta il generatore con l'hash della stringa
+func (r *SeededRandom) Seed(seed string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	r.rng = rand.New(rand.NewSource(int64(hash.Sum64())))
+}
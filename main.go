@@ -18,8 +18,13 @@ import (
 )
 
 func main() {
+	// Sottocomandi non interattivi (es. merge driver per git)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	fmt.Println("Tweego Editor Backend v0.2.0")
-	fmt.Println("================================\n")
+	fmt.Print("================================\n\n")
 	
 	// Mostra menu
 	fmt.Println("Scegli una modalità:")
@@ -57,6 +62,172 @@ func main() {
 	}
 }
 
+// runCommand esegue un sottocomando e restituisce il codice di uscita
+func runCommand(command string, args []string) int {
+	switch command {
+	case "merge":
+		return runMerge(args)
+	case "diff":
+		return runDiff(args)
+	case "split":
+		return runSplit(args)
+	case "join":
+		return runJoin(args)
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Comando sconosciuto: %s (disponibili: merge, diff, split, join)\n", command)
+		return 2
+	}
+}
+
+// runMerge esegue il merge a tre vie di un file .twee e scrive il risultato su ours
+// Pensato come merge driver per git:
+//
+//	.gitattributes:  *.twee merge=twee
+//	.git/config:     [merge "twee"]
+//	                     driver = tweego-editor merge %O %A %B %P
+//
+// Codici di uscita: 0 merge pulito, 1 conflitti, 2 errore
+func runMerge(args []string) int {
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "Uso: tweego-editor merge <base> <ours> <theirs> [path]")
+		return 2
+	}
+
+	files := make([][]byte, 3)
+	for i, path := range args[:3] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Impossibile leggere %s: %v\n", path, err)
+			return 2
+		}
+		files[i] = data
+	}
+
+	name := args[1]
+	if len(args) > 3 {
+		name = args[3]
+	}
+
+	result := parser.NewTweeMerger(name).Merge(files[0], files[1], files[2])
+	if err := os.WriteFile(args[1], result.Content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Impossibile scrivere %s: %v\n", args[1], err)
+		return 2
+	}
+
+	if !result.Clean() {
+		for _, conflict := range result.Conflicts {
+			fmt.Fprintf(os.Stderr, "⚠️  %s: conflitto in '%s': %s\n", name, conflict.Title, conflict.Reason)
+		}
+		return 1
+	}
+	return 0
+}
+
+// runDiff mostra le differenze tra due versioni di una storia passaggio per passaggio
+func runDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Uso: tweego-editor diff <vecchio> <nuovo>")
+		return 2
+	}
+
+	stories := make([]*parser.Story, 2)
+	for i, path := range args {
+		story, err := parser.NewTweeParser(path).Parse()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 2
+		}
+		stories[i] = story
+	}
+
+	diff := parser.DiffStories(stories[0], stories[1])
+	for _, field := range diff.Story {
+		fmt.Printf("~ storia: %s\n", field)
+	}
+	for _, passage := range diff.Passages {
+		changes := []string{}
+		if passage.Content {
+			changes = append(changes, "contenuto")
+		}
+		if passage.Tags {
+			changes = append(changes, "tag")
+		}
+		if passage.Metadata {
+			changes = append(changes, "metadata")
+		}
+
+		switch passage.Kind {
+		case parser.ChangeAdded:
+			fmt.Printf("+ %s\n", passage.Title)
+		case parser.ChangeRemoved:
+			fmt.Printf("- %s\n", passage.Title)
+		case parser.ChangeRenamed:
+			fmt.Printf("→ %s → %s %v\n", passage.OldTitle, passage.Title, changes)
+		default:
+			fmt.Printf("~ %s %v\n", passage.Title, changes)
+		}
+	}
+	return 0
+}
+
+// runSplit divide una storia in un file .twee per passaggio
+// La regola opzionale sceglie le cartelle: "tag" (primo tag) o "prefix=<separatore>"
+func runSplit(args []string) int {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, "Uso: tweego-editor split <file.twee> <directory> [tag|prefix=<separatore>]")
+		return 2
+	}
+
+	story, err := parser.NewTweeParser(args[0]).Parse()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+
+	splitter := parser.NewStorySplitter(args[1])
+	if len(args) == 3 {
+		switch rule := args[2]; {
+		case rule == "tag":
+			splitter.SetFolderRule(parser.FolderByTag)
+		case strings.HasPrefix(rule, "prefix="):
+			splitter.SetFolderRule(parser.FolderByPrefix(strings.TrimPrefix(rule, "prefix=")))
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Regola cartelle non valida: %s\n", rule)
+			return 2
+		}
+	}
+
+	files, err := splitter.Split(story)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("✓ %d file scritti in %s\n", len(files), args[1])
+	return 0
+}
+
+// runJoin ricompone una storia divisa con split in un unico file .twee
+func runJoin(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Uso: tweego-editor join <directory> <file.twee>")
+		return 2
+	}
+
+	data, err := parser.NewStoryJoiner(args[0]).Join()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Impossibile scrivere %s: %v\n", args[1], err)
+		return 2
+	}
+
+	fmt.Printf("✓ Storia ricomposta in %s\n", args[1])
+	return 0
+}
+
 func testParser() {
 	// Crea un parser per il file di test
 	tweeParser := parser.NewTweeParser("test_story.twee")
@@ -73,9 +244,9 @@ func testParser() {
 	// Inizializza il formato Harlowe
 	harlowe := harlowe.NewHarloweFormat()
 	
-	// Analizza ogni passaggio
-	for title, passage := range story.Passages {
-		fmt.Printf("=== Passaggio: %s ===\n", title)
+	// Analizza ogni passaggio in ordine di sorgente
+	for _, passage := range story.OrderedPassages() {
+		fmt.Printf("=== Passaggio: %s ===\n", passage.Title)
 		fmt.Printf("Tag: %v\n", passage.Tags)
 		
 		// Estrai link
@@ -206,7 +377,7 @@ func testWatcher() {
 	
 	fmt.Println("\n✨ Watch mode attivo!")
 	fmt.Println("💡 Modifica test_story.twee per vedere la ricompilazione automatica")
-	fmt.Println("🛑 Premi CTRL+C per uscire\n")
+	fmt.Print("🛑 Premi CTRL+C per uscire\n\n")
 	
 	// Ascolta eventi
 	for event := range fw.Events() {
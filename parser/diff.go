@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PassageChangeKind indica il tipo di differenza di un passaggio
+type PassageChangeKind string
+
+const (
+	ChangeAdded    PassageChangeKind = "added"
+	ChangeRemoved  PassageChangeKind = "removed"
+	ChangeRenamed  PassageChangeKind = "renamed"
+	ChangeModified PassageChangeKind = "modified"
+)
+
+// renameThreshold è la somiglianza minima del contenuto per riconoscere una rinomina
+const renameThreshold = 0.6
+
+// PassageDiff descrive la differenza di un singolo passaggio tra due versioni
+type PassageDiff struct {
+	Kind     PassageChangeKind `json:"kind"`
+	Title    string            `json:"title"`               // Titolo nella nuova versione (nella vecchia se rimosso)
+	OldTitle string            `json:"old_title,omitempty"` // Titolo precedente (solo rinomine)
+	Content  bool              `json:"content,omitempty"`   // Contenuto cambiato
+	Tags     bool              `json:"tags,omitempty"`      // Tag cambiati
+	Metadata bool              `json:"metadata,omitempty"`  // Position, size o metadata cambiati
+}
+
+// StoryDiff elenca le differenze tra due versioni di una storia
+type StoryDiff struct {
+	Passages []PassageDiff `json:"passages"`
+	Story    []string      `json:"story"` // Campi della storia cambiati (title, ifid, format, start, ...)
+}
+
+// IsEmpty verifica se le due versioni sono equivalenti
+func (d *StoryDiff) IsEmpty() bool {
+	return len(d.Passages) == 0 && len(d.Story) == 0
+}
+
+// DiffStories confronta due versioni di una storia passaggio per passaggio
+// Un passaggio sparito e uno nuovo con contenuto simile sono riportati come rinomina
+func DiffStories(old, updated *Story) *StoryDiff {
+	diff := &StoryDiff{
+		Passages: []PassageDiff{},
+		Story:    diffStoryFields(old, updated),
+	}
+
+	matches := matchPassages(old, updated)
+	renamed := make(map[string]string, len(matches)) // Nuovo titolo → vecchio titolo
+	for oldTitle, newTitle := range matches {
+		renamed[newTitle] = oldTitle
+	}
+
+	for _, passage := range old.OrderedPassages() {
+		if _, exists := matches[passage.Title]; !exists {
+			diff.Passages = append(diff.Passages, PassageDiff{Kind: ChangeRemoved, Title: passage.Title})
+		}
+	}
+
+	for _, passage := range updated.OrderedPassages() {
+		oldTitle, exists := renamed[passage.Title]
+		if !exists {
+			diff.Passages = append(diff.Passages, PassageDiff{Kind: ChangeAdded, Title: passage.Title})
+			continue
+		}
+
+		entry := comparePassages(old.Passages[oldTitle], passage)
+		if oldTitle != passage.Title {
+			entry.Kind = ChangeRenamed
+			entry.OldTitle = oldTitle
+		} else if !entry.Content && !entry.Tags && !entry.Metadata {
+			continue
+		}
+		diff.Passages = append(diff.Passages, entry)
+	}
+
+	return diff
+}
+
+// comparePassages confronta contenuto, tag e metadata di due passaggi
+func comparePassages(a, b *Passage) PassageDiff {
+	return PassageDiff{
+		Kind:     ChangeModified,
+		Title:    b.Title,
+		Content:  a.Content != b.Content,
+		Tags:     !reflect.DeepEqual(a.Tags, b.Tags),
+		Metadata: a.Position != b.Position || a.Size != b.Size || !reflect.DeepEqual(a.Metadata, b.Metadata),
+	}
+}
+
+// diffStoryFields elenca i campi della storia (titolo e StoryData) cambiati
+func diffStoryFields(old, updated *Story) []string {
+	fields := []string{}
+	if old.Title != updated.Title {
+		fields = append(fields, "title")
+	}
+	if old.IFID != updated.IFID {
+		fields = append(fields, "ifid")
+	}
+	if old.Format != updated.Format {
+		fields = append(fields, "format")
+	}
+	if old.FormatVersion != updated.FormatVersion {
+		fields = append(fields, "format-version")
+	}
+	if old.Start != updated.Start {
+		fields = append(fields, "start")
+	}
+	if !reflect.DeepEqual(old.TagColors, updated.TagColors) {
+		fields = append(fields, "tag-colors")
+	}
+	if old.Zoom != updated.Zoom {
+		fields = append(fields, "zoom")
+	}
+	return fields
+}
+
+// matchPassages associa i passaggi della vecchia versione a quelli della nuova (vecchio → nuovo titolo)
+// Prima per titolo, poi tra i passaggi rimasti per somiglianza del contenuto
+func matchPassages(old, updated *Story) map[string]string {
+	matches := make(map[string]string, len(old.Passages))
+	removed := []*Passage{}
+	for _, passage := range old.OrderedPassages() {
+		if _, exists := updated.Passages[passage.Title]; exists {
+			matches[passage.Title] = passage.Title
+		} else {
+			removed = append(removed, passage)
+		}
+	}
+
+	added := []*Passage{}
+	for _, passage := range updated.OrderedPassages() {
+		if _, exists := old.Passages[passage.Title]; !exists {
+			added = append(added, passage)
+		}
+	}
+
+	// Coppie candidate ordinate per somiglianza decrescente, a parità in ordine di sorgente
+	type candidate struct {
+		from, to int
+		score    float64
+	}
+	candidates := []candidate{}
+	for i, a := range removed {
+		for j, b := range added {
+			if a.Content == "" || b.Content == "" {
+				continue
+			}
+			if score := similarity(a.Content, b.Content); score >= renameThreshold {
+				candidates = append(candidates, candidate{from: i, to: j, score: score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	usedFrom := make(map[int]bool)
+	usedTo := make(map[int]bool)
+	for _, c := range candidates {
+		if usedFrom[c.from] || usedTo[c.to] {
+			continue
+		}
+		usedFrom[c.from] = true
+		usedTo[c.to] = true
+		matches[removed[c.from].Title] = added[c.to].Title
+	}
+
+	return matches
+}
+
+// similarity restituisce la frazione di righe in comune tra due testi (da 0 a 1)
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	linesA := strings.Split(a, "\n")
+	linesB := strings.Split(b, "\n")
+	common := 0
+	for _, j := range lcsLines(linesA, linesB) {
+		if j >= 0 {
+			common++
+		}
+	}
+	return float64(2*common) / float64(len(linesA)+len(linesB))
+}
+
+// lcsLines calcola la sottosequenza comune più lunga tra due liste di righe
+// Restituisce per ogni riga di a l'indice della riga corrispondente in b, o -1
+func lcsLines(a, b []string) []int {
+	// lengths[i][j] = LCS di a[i:] e b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			matches[i] = -1
+			i++
+		default:
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		matches[i] = -1
+	}
+	return matches
+}
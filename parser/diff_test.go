@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+const diffBase = `:: StoryData
+{"ifid":"D674C58C-DEFA-4F70-B7A2-27742230C0FC","format":"Harlowe","start":"Inizio"}
+
+:: Inizio [uno]
+Benvenuto nella storia.
+Prosegui verso il corridoio.
+[[Corridoio]]
+
+:: Corridoio
+Un corridoio lungo e buio.
+In fondo si vede una porta.
+[[Fine]]
+
+:: Fine {"position":"100,100"}
+Fine.
+
+:: Note
+Appunti.
+`
+
+const diffUpdated = `:: StoryData
+{"ifid":"D674C58C-DEFA-4F70-B7A2-27742230C0FC","format":"Harlowe","start":"Ingresso"}
+
+:: Ingresso [uno]
+Benvenuto nella storia.
+Prosegui verso il corridoio.
+[[Corridoio]]
+
+:: Corridoio [buio]
+Un corridoio lungo e buio.
+In fondo si vede una porta.
+[[Fine]]
+
+:: Fine {"position":"300,100"}
+Fine davvero.
+
+:: Epilogo
+Titoli di coda.
+`
+
+// ============================================
+// Test: Diff tra storie
+// ============================================
+
+func TestDiffStories(t *testing.T) {
+	old, err := ParseString(diffBase, "old.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+	updated, err := ParseString(diffUpdated, "new.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	diff := DiffStories(old, updated)
+
+	expected := []PassageDiff{
+		{Kind: ChangeRemoved, Title: "Note"},
+		{Kind: ChangeModified, Title: "StoryData", Content: true},
+		{Kind: ChangeRenamed, Title: "Ingresso", OldTitle: "Inizio"},
+		{Kind: ChangeModified, Title: "Corridoio", Tags: true},
+		{Kind: ChangeModified, Title: "Fine", Content: true, Metadata: true},
+		{Kind: ChangeAdded, Title: "Epilogo"},
+	}
+	if !reflect.DeepEqual(diff.Passages, expected) {
+		t.Errorf("Unexpected passage diff:\n  got:      %+v\n  expected: %+v", diff.Passages, expected)
+	}
+	if !reflect.DeepEqual(diff.Story, []string{"start"}) {
+		t.Errorf("Expected story diff [start], got %v", diff.Story)
+	}
+
+	if !DiffStories(old, old).IsEmpty() {
+		t.Error("Expected empty diff for identical stories")
+	}
+
+	t.Log("✅ Story diff reports added, removed, renamed and modified passages")
+}
+
+func TestDiffRenameRequiresSimilarContent(t *testing.T) {
+	old, _ := ParseString(":: A\nuno\ndue\ntre\n", "old.twee")
+	updated, _ := ParseString(":: B\nquattro\ncinque\nsei\n", "new.twee")
+
+	diff := DiffStories(old, updated)
+	if len(diff.Passages) != 2 || diff.Passages[0].Kind != ChangeRemoved || diff.Passages[1].Kind != ChangeAdded {
+		t.Errorf("Expected removal and addition, got %+v", diff.Passages)
+	}
+
+	t.Log("✅ Unrelated passages are not reported as renames")
+}
@@ -0,0 +1,390 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// Edit descrive una modifica al sorgente: i byte [Start, End) vengono sostituiti da Text
+type Edit struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// ChangeSet elenca i passaggi cambiati rispetto alla versione precedente della storia
+// I titoli sono in ordine di sorgente; un passaggio rinominato risulta rimosso e aggiunto
+type ChangeSet struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// newChangeSet crea un change set vuoto
+func newChangeSet() *ChangeSet {
+	return &ChangeSet{
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []string{},
+	}
+}
+
+// IsEmpty verifica se il change set non contiene modifiche
+func (cs *ChangeSet) IsEmpty() bool {
+	return len(cs.Added) == 0 && len(cs.Removed) == 0 && len(cs.Modified) == 0
+}
+
+// Merge accoda un change set successivo, come se le due modifiche fossero una sola
+// Un passaggio aggiunto e poi rimosso scompare; rimosso e poi aggiunto risulta modificato
+func (cs *ChangeSet) Merge(next *ChangeSet) {
+	for _, title := range next.Added {
+		if removeTitle(&cs.Removed, title) {
+			cs.Modified = append(cs.Modified, title)
+		} else {
+			cs.Added = append(cs.Added, title)
+		}
+	}
+	for _, title := range next.Removed {
+		if removeTitle(&cs.Added, title) {
+			continue
+		}
+		removeTitle(&cs.Modified, title)
+		cs.Removed = append(cs.Removed, title)
+	}
+	for _, title := range next.Modified {
+		if !containsTitle(cs.Added, title) && !containsTitle(cs.Modified, title) {
+			cs.Modified = append(cs.Modified, title)
+		}
+	}
+}
+
+// containsTitle verifica se il titolo è nella lista
+func containsTitle(titles []string, title string) bool {
+	for _, t := range titles {
+		if t == title {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTitle rimuove il titolo dalla lista, restituendo true se era presente
+func removeTitle(titles *[]string, title string) bool {
+	for i, t := range *titles {
+		if t == title {
+			*titles = append((*titles)[:i], (*titles)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IncrementalParser mantiene sorgente e storia di un file Twee
+// e dopo ogni modifica riparsa solo i passaggi toccati
+// Non è sicuro per l'uso concorrente
+type IncrementalParser struct {
+	filepath string
+	dialect  Dialect // Dialetto forzato, DialectAuto per rilevarlo al primo parsing
+	source   []byte
+	story    *Story // Ultima versione valida della storia
+	stale    bool   // Il sorgente corrente non è valido: serve un parsing completo
+	crlf     bool
+}
+
+// NewIncrementalParser crea un parser incrementale per il file indicato
+func NewIncrementalParser(filepath string) *IncrementalParser {
+	return &IncrementalParser{filepath: filepath}
+}
+
+// SetDialect forza il dialetto del sorgente invece di rilevarlo automaticamente
+func (ip *IncrementalParser) SetDialect(dialect Dialect) {
+	ip.dialect = dialect
+}
+
+// Story restituisce l'ultima versione valida della storia, o nil
+func (ip *IncrementalParser) Story() *Story {
+	return ip.story
+}
+
+// Load legge il file e lo parsa per intero
+func (ip *IncrementalParser) Load() (*Story, error) {
+	result := newValidationResult()
+	data, ok := NewTweeParser(ip.filepath).readFile(result)
+	if !ok {
+		return nil, validationError(result)
+	}
+
+	ip.source = data
+	story, _, err := ip.reparse()
+	return story, err
+}
+
+// Reload rilegge il file e applica le differenze rispetto alla versione precedente
+func (ip *IncrementalParser) Reload() (*Story, *ChangeSet, error) {
+	result := newValidationResult()
+	data, ok := NewTweeParser(ip.filepath).readFile(result)
+	if !ok {
+		return nil, nil, validationError(result)
+	}
+
+	return ip.Update(data)
+}
+
+// Update sostituisce il sorgente con una nuova versione del file
+// La modifica viene ricavata da prefisso e suffisso comuni, poi applicata con ApplyEdit
+func (ip *IncrementalParser) Update(data []byte) (*Story, *ChangeSet, error) {
+	if ip.story == nil || ip.stale {
+		ip.source = append([]byte{}, data...)
+		return ip.reparse()
+	}
+
+	old := ip.source
+	prefix := 0
+	for prefix < len(old) && prefix < len(data) && old[prefix] == data[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(data)-prefix && old[len(old)-1-suffix] == data[len(data)-1-suffix] {
+		suffix++
+	}
+
+	if prefix == len(old) && prefix == len(data) {
+		return ip.story, newChangeSet(), nil
+	}
+
+	return ip.ApplyEdit(Edit{
+		Start: prefix,
+		End:   len(old) - suffix,
+		Text:  string(data[prefix : len(data)-suffix]),
+	})
+}
+
+// ApplyEdit applica una modifica al sorgente e riparsa i passaggi che la contengono
+// I passaggi successivi vengono solo spostati; se la modifica rende il sorgente non valido
+// viene restituito l'errore e la modifica successiva esegue un parsing completo
+func (ip *IncrementalParser) ApplyEdit(edit Edit) (*Story, *ChangeSet, error) {
+	if edit.Start < 0 || edit.End < edit.Start || edit.End > len(ip.source) {
+		return nil, nil, fmt.Errorf("modifica fuori dal sorgente (%d-%d, lunghezza %d)", edit.Start, edit.End, len(ip.source))
+	}
+
+	old := ip.source
+	source := make([]byte, 0, len(old)-(edit.End-edit.Start)+len(edit.Text))
+	source = append(source, old[:edit.Start]...)
+	source = append(source, edit.Text...)
+	source = append(source, old[edit.End:]...)
+	ip.source = source
+
+	if ip.story == nil || ip.stale {
+		return ip.reparse()
+	}
+
+	// 1. Passaggi interessati: [lo, hi) in ordine di sorgente
+	// Una modifica sul confine tra due passaggi li riparsa entrambi
+	passages := ip.story.OrderedPassages()
+	spanEnd := func(i int) int {
+		if i+1 < len(passages) {
+			return passages[i+1].Header.Offset
+		}
+		return len(old)
+	}
+
+	lo := 0
+	for lo < len(passages) && spanEnd(lo) < edit.Start {
+		lo++
+	}
+	hi := lo
+	for hi < len(passages) && passages[hi].Header.Offset <= edit.End {
+		hi++
+	}
+
+	// 2. Regione da riparsare; prima del primo passaggio include il testo iniziale
+	regionStart, lineBase := 0, 1
+	if lo > 0 {
+		regionStart = passages[lo].Header.Offset
+		lineBase = passages[lo].Header.Line
+	}
+	regionEnd := len(old)
+	if hi < len(passages) {
+		regionEnd = passages[hi].Header.Offset
+	}
+	offsetDelta := len(edit.Text) - (edit.End - edit.Start)
+	region := source[regionStart : regionEnd+offsetDelta]
+	lineDelta := bytes.Count(region, []byte("\n")) - bytes.Count(old[regionStart:regionEnd], []byte("\n"))
+
+	tp := &TweeParser{filepath: ip.filepath, dialect: ip.story.Dialect}
+	result := newValidationResult()
+	regionStory := &Story{Passages: make(map[string]*Passage), Dialect: ip.story.Dialect}
+	tp.scanSource(region, result, regionStory)
+
+	// Le posizioni della regione partono da riga 1, offset 0
+	for i := range result.Errors {
+		shiftIssue(&result.Errors[i], lineBase-1)
+	}
+	for i := range result.Warnings {
+		shiftIssue(&result.Warnings[i], lineBase-1)
+	}
+
+	// 3. Nuova storia: passaggi precedenti invariati, regione riparsata, successivi spostati
+	story := &Story{
+		Passages: make(map[string]*Passage, len(passages)),
+		Dialect:  ip.story.Dialect,
+	}
+	for _, passage := range passages[:lo] {
+		story.AddPassage(passage)
+	}
+
+	addChecked := func(passage *Passage) {
+		if first, exists := story.Passages[passage.Title]; exists {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Type:    "error",
+				Message: fmt.Sprintf("Passaggio '%s' definito due volte (righe %d e %d)", passage.Title, first.Header.Line, passage.Header.Line),
+				File:    ip.filepath,
+				Line:    passage.Header.Line,
+				Column:  1,
+			})
+			return
+		}
+		story.AddPassage(passage)
+	}
+
+	for _, title := range regionStory.Order {
+		passage := regionStory.Passages[title]
+		passage.shift(regionStart, lineBase-1)
+		passage.crlf = passage.crlf || ip.crlf
+		addChecked(passage)
+	}
+	for _, passage := range passages[hi:] {
+		shifted := *passage
+		shifted.shift(offsetDelta, lineDelta)
+		addChecked(&shifted)
+	}
+
+	for _, passage := range story.OrderedPassages() {
+		tp.applySpecialPassage(story, passage)
+	}
+	checkStory(storyScan(ip.filepath, story), result)
+
+	if !result.Valid {
+		ip.stale = true
+		return nil, nil, validationError(result)
+	}
+
+	// 4. Change set limitato alla regione riparsata
+	changes := newChangeSet()
+	for _, passage := range passages[lo:hi] {
+		if updated, exists := regionStory.Passages[passage.Title]; !exists {
+			changes.Removed = append(changes.Removed, passage.Title)
+		} else if !samePassage(passage, updated) {
+			changes.Modified = append(changes.Modified, passage.Title)
+		}
+	}
+	previous := make(map[string]bool, hi-lo)
+	for _, passage := range passages[lo:hi] {
+		previous[passage.Title] = true
+	}
+	for _, title := range regionStory.Order {
+		if !previous[title] {
+			changes.Added = append(changes.Added, title)
+		}
+	}
+
+	ip.story = story
+	return story, changes, nil
+}
+
+// reparse parsa per intero il sorgente corrente e lo confronta con l'ultima versione valida
+func (ip *IncrementalParser) reparse() (*Story, *ChangeSet, error) {
+	tp := &TweeParser{filepath: ip.filepath, dialect: ip.dialect}
+	story, err := tp.parseData(ip.source, newValidationResult())
+	if err != nil {
+		ip.stale = true
+		return nil, nil, err
+	}
+
+	changes := diffPassages(ip.story, story)
+	ip.story = story
+	ip.stale = false
+	ip.crlf = bytes.Contains(ip.source, []byte("\r\n"))
+	return story, changes, nil
+}
+
+// diffPassages confronta i passaggi di due versioni della storia (old può essere nil)
+func diffPassages(old, updated *Story) *ChangeSet {
+	changes := newChangeSet()
+	if old == nil {
+		changes.Added = append(changes.Added, updated.Order...)
+		return changes
+	}
+
+	for _, passage := range old.OrderedPassages() {
+		if _, exists := updated.Passages[passage.Title]; !exists {
+			changes.Removed = append(changes.Removed, passage.Title)
+		}
+	}
+	for _, passage := range updated.OrderedPassages() {
+		previous, exists := old.Passages[passage.Title]
+		if !exists {
+			changes.Added = append(changes.Added, passage.Title)
+		} else if !samePassage(previous, passage) {
+			changes.Modified = append(changes.Modified, passage.Title)
+		}
+	}
+	return changes
+}
+
+// samePassage confronta contenuto, tag e metadata di due passaggi
+// Le posizioni nel sorgente non contano: un passaggio solo spostato non è modificato
+func samePassage(a, b *Passage) bool {
+	return a.Title == b.Title &&
+		a.Content == b.Content &&
+		reflect.DeepEqual(a.Tags, b.Tags) &&
+		a.Position == b.Position &&
+		a.Size == b.Size &&
+		reflect.DeepEqual(a.Metadata, b.Metadata)
+}
+
+// shift sposta le posizioni nel sorgente del passaggio
+func (p *Passage) shift(offset, lines int) {
+	for _, pos := range []*SourcePos{&p.Header, &p.BodyStart, &p.BodyEnd} {
+		pos.Offset += offset
+		pos.Line += lines
+	}
+}
+
+// shiftIssue sposta la riga di un errore di validazione (se indicata)
+func shiftIssue(issue *ValidationError, lines int) {
+	if issue.Line > 0 {
+		issue.Line += lines
+	}
+}
+
+// storyScan ricostruisce dalla storia le informazioni usate da checkStory
+func storyScan(file string, story *Story) *sourceScan {
+	scan := &sourceScan{
+		file:     file,
+		passages: make(map[string]SourcePos, len(story.Passages)),
+		files:    make(map[string]string, len(story.Passages)),
+		kinds:    make(map[string]PassageKind, len(story.Passages)),
+		dialect:  story.Dialect,
+		start:    story.Start,
+	}
+
+	for title, passage := range story.Passages {
+		scan.passages[title] = passage.Header
+		scan.files[title] = passage.File
+		scan.kinds[title] = passage.Kind()
+	}
+
+	if data := story.StoryDataPassage(); data != nil {
+		scan.hasStoryData = true
+		scan.startFile = data.File
+		scan.startPos = data.Header
+		if offset := keyOffset(data.Content, "start"); offset >= 0 {
+			scan.startPos = data.PositionAt(offset)
+		}
+	}
+
+	return scan
+}
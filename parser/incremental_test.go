@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const incrementalSource = `:: StoryData
+{"ifid":"D674C58C-DEFA-4F70-B7A2-27742230C0FC","format":"Harlowe","start":"Inizio"}
+
+:: StoryTitle
+Storia incrementale
+
+:: Inizio [uno]
+Benvenuto.
+[[Corridoio]]
+
+:: Corridoio {"position":"200,100"}
+Un corridoio.
+[[Fine]]
+
+:: Fine
+Fine.
+`
+
+// newIncremental crea un parser incrementale caricato con il sorgente
+func newIncremental(t *testing.T, source string) *IncrementalParser {
+	t.Helper()
+
+	ip := NewIncrementalParser("story.twee")
+	if _, _, err := ip.Update([]byte(source)); err != nil {
+		t.Fatalf("Error loading source: %v", err)
+	}
+	return ip
+}
+
+// assertMatchesFullParse verifica che la storia incrementale coincida con un parsing completo
+func assertMatchesFullParse(t *testing.T, ip *IncrementalParser) {
+	t.Helper()
+
+	full, err := NewTweeParser("story.twee").parseData(ip.source, newValidationResult())
+	if err != nil {
+		t.Fatalf("Full parse failed: %v", err)
+	}
+
+	story := ip.Story()
+	if !reflect.DeepEqual(story.Order, full.Order) {
+		t.Fatalf("Order mismatch: %v vs %v", story.Order, full.Order)
+	}
+	for _, title := range full.Order {
+		if !reflect.DeepEqual(story.Passages[title], full.Passages[title]) {
+			t.Errorf("Passage '%s' differs:\n  incremental: %+v\n  full:        %+v", title, story.Passages[title], full.Passages[title])
+		}
+	}
+	if story.Title != full.Title || !reflect.DeepEqual(story.StoryData, full.StoryData) {
+		t.Errorf("Story fields differ: %q %+v vs %q %+v", story.Title, story.StoryData, full.Title, full.StoryData)
+	}
+}
+
+// edit sostituisce la prima occorrenza di old nel sorgente corrente
+func edit(t *testing.T, ip *IncrementalParser, old, text string) *ChangeSet {
+	t.Helper()
+
+	start := strings.Index(string(ip.source), old)
+	if start < 0 {
+		t.Fatalf("Text %q not found in source", old)
+	}
+	_, changes, err := ip.ApplyEdit(Edit{Start: start, End: start + len(old), Text: text})
+	if err != nil {
+		t.Fatalf("Error applying edit: %v", err)
+	}
+	return changes
+}
+
+// ============================================
+// Test: Parsing incrementale
+// ============================================
+
+func TestIncrementalEditModifiesPassage(t *testing.T) {
+	ip := newIncremental(t, incrementalSource)
+	before := ip.Story()
+
+	changes := edit(t, ip, "Un corridoio.", "Un corridoio\nlungo e buio.")
+	expected := &ChangeSet{Added: []string{}, Removed: []string{}, Modified: []string{"Corridoio"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+
+	// I passaggi precedenti alla modifica non vengono riparsati
+	if ip.Story().Passages["Inizio"] != before.Passages["Inizio"] {
+		t.Error("Expected passage 'Inizio' to be reused")
+	}
+	if before.Passages["Corridoio"].Content != "Un corridoio.\n[[Fine]]" {
+		t.Error("Expected previous story to be left unchanged")
+	}
+
+	// Il passaggio successivo è spostato di una riga ma non modificato
+	if ip.Story().Passages["Fine"].Header.Line != before.Passages["Fine"].Header.Line+1 {
+		t.Errorf("Expected 'Fine' to move down one line")
+	}
+
+	assertMatchesFullParse(t, ip)
+	t.Log("✅ Edit re-parses only the touched passage")
+}
+
+func TestIncrementalAddRemoveRename(t *testing.T) {
+	ip := newIncremental(t, incrementalSource)
+
+	// Una nuova intestazione nel corpo divide il passaggio
+	changes := edit(t, ip, "Un corridoio.\n", "Un corridoio.\n\n:: Stanza\nUna stanza.\n")
+	if !reflect.DeepEqual(changes.Added, []string{"Stanza"}) || !reflect.DeepEqual(changes.Modified, []string{"Corridoio"}) {
+		t.Errorf("Unexpected changes after split: %+v", changes)
+	}
+	assertMatchesFullParse(t, ip)
+
+	changes = edit(t, ip, ":: Stanza", ":: Cantina")
+	if !reflect.DeepEqual(changes.Added, []string{"Cantina"}) || !reflect.DeepEqual(changes.Removed, []string{"Stanza"}) {
+		t.Errorf("Unexpected changes after rename: %+v", changes)
+	}
+	assertMatchesFullParse(t, ip)
+
+	changes = edit(t, ip, ":: Cantina\nUna stanza.\n", "")
+	if !reflect.DeepEqual(changes.Removed, []string{"Cantina"}) {
+		t.Errorf("Unexpected changes after removal: %+v", changes)
+	}
+	assertMatchesFullParse(t, ip)
+
+	changes = edit(t, ip, "Fine.\n", "Fine.\n\n:: Epilogo [fine]\nTitoli di coda.\n")
+	if !reflect.DeepEqual(changes.Added, []string{"Epilogo"}) || len(changes.Modified) != 0 {
+		t.Errorf("Unexpected changes after append: %+v", changes)
+	}
+	assertMatchesFullParse(t, ip)
+
+	t.Log("✅ Added, removed and renamed passages reported")
+}
+
+func TestIncrementalStoryData(t *testing.T) {
+	ip := newIncremental(t, incrementalSource)
+
+	changes := edit(t, ip, `"start":"Inizio"`, `"start":"Corridoio"`)
+	if !reflect.DeepEqual(changes.Modified, []string{"StoryData"}) {
+		t.Errorf("Unexpected changes: %+v", changes)
+	}
+	if ip.Story().Start != "Corridoio" {
+		t.Errorf("Expected start 'Corridoio', got %q", ip.Story().Start)
+	}
+
+	edit(t, ip, "Storia incrementale", "Nuovo titolo")
+	if ip.Story().Title != "Nuovo titolo" {
+		t.Errorf("Expected new title, got %q", ip.Story().Title)
+	}
+
+	assertMatchesFullParse(t, ip)
+	t.Log("✅ StoryData and StoryTitle edits update the story")
+}
+
+func TestIncrementalInvalidEditRecovers(t *testing.T) {
+	ip := newIncremental(t, incrementalSource)
+	valid := ip.Story()
+
+	// Un duplicato rende il sorgente non valido
+	start := strings.Index(string(ip.source), ":: Fine")
+	_, _, err := ip.ApplyEdit(Edit{Start: start, End: start, Text: ":: Inizio\nCopia.\n\n"})
+	if err == nil || !strings.Contains(err.Error(), "definito due volte") {
+		t.Fatalf("Expected duplicate error, got %v", err)
+	}
+	if ip.Story() != valid {
+		t.Error("Expected last valid story to be kept")
+	}
+
+	// La correzione successiva riporta a una storia valida con il change set completo
+	changes := edit(t, ip, ":: Inizio\nCopia.", ":: Copia\nCopia.")
+	if !reflect.DeepEqual(changes.Added, []string{"Copia"}) || len(changes.Removed) != 0 {
+		t.Errorf("Unexpected changes after recovery: %+v", changes)
+	}
+	assertMatchesFullParse(t, ip)
+
+	if _, _, err := ip.ApplyEdit(Edit{Start: 0, End: len(ip.source) + 1}); err == nil {
+		t.Error("Expected error for edit outside source")
+	}
+
+	t.Log("✅ Invalid edits are reported and recovered from")
+}
+
+func TestIncrementalReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "story.twee")
+	if err := os.WriteFile(path, []byte(incrementalSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ip := NewIncrementalParser(path)
+	if _, err := ip.Load(); err != nil {
+		t.Fatalf("Error loading: %v", err)
+	}
+
+	_, changes, err := ip.Reload()
+	if err != nil || !changes.IsEmpty() {
+		t.Errorf("Expected no changes for unchanged file, got %+v, %v", changes, err)
+	}
+
+	updated := strings.Replace(incrementalSource, "Benvenuto.", "Ciao.", 1)
+	updated = strings.Replace(updated, "Fine.\n", "Fine davvero.\n", 1)
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, changes, err = ip.Reload()
+	if err != nil {
+		t.Fatalf("Error reloading: %v", err)
+	}
+	if !reflect.DeepEqual(changes.Modified, []string{"Inizio", "Fine"}) {
+		t.Errorf("Expected Inizio and Fine modified, got %+v", changes)
+	}
+
+	t.Log("✅ Reload diffs the new file version")
+}
+
+func TestChangeSetMerge(t *testing.T) {
+	changes := newChangeSet()
+	changes.Merge(&ChangeSet{Added: []string{"A"}, Removed: []string{"B"}, Modified: []string{"C"}})
+	changes.Merge(&ChangeSet{Added: []string{"B"}, Removed: []string{"A", "C"}, Modified: []string{"D"}})
+
+	expected := &ChangeSet{Added: []string{}, Removed: []string{"C"}, Modified: []string{"B", "D"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+
+	t.Log("✅ Change sets merged")
+}
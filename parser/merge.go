@@ -0,0 +1,410 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergeConflict descrive un passaggio che non è stato possibile unire automaticamente
+type MergeConflict struct {
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// MergeResult risultato di un merge a tre vie
+type MergeResult struct {
+	Content   []byte          `json:"content"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// Clean verifica se il merge è avvenuto senza conflitti
+func (r *MergeResult) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// TweeMerger esegue il merge a tre vie di sorgenti Twee a livello di passaggio
+// I passaggi modificati da un solo lato vengono copiati byte per byte dal loro sorgente;
+// i marcatori di conflitto compaiono solo nel contenuto dei passaggi in conflitto
+type TweeMerger struct {
+	name        string
+	oursLabel   string
+	theirsLabel string
+}
+
+// NewTweeMerger crea un merger; name viene usato come nome file negli errori
+func NewTweeMerger(name string) *TweeMerger {
+	return &TweeMerger{name: name, oursLabel: "ours", theirsLabel: "theirs"}
+}
+
+// SetLabels imposta le etichette dei marcatori di conflitto (<<<<<<< ours, >>>>>>> theirs)
+func (tm *TweeMerger) SetLabels(ours, theirs string) {
+	tm.oursLabel = ours
+	tm.theirsLabel = theirs
+}
+
+// mergeSource è un sorgente analizzato di cui si conoscono gli intervalli dei passaggi
+type mergeSource struct {
+	data  []byte
+	story *Story
+	index map[string]int // Titolo → posizione in story.Order
+}
+
+// mergeChunk è un passaggio del risultato: copiato da un sorgente o generato
+type mergeChunk struct {
+	source *mergeSource // nil se generato
+	index  int
+	text   []byte
+}
+
+// parseSource analizza un sorgente senza i controlli a livello di storia
+// (un ramo può essere temporaneamente privo del passaggio iniziale)
+func (tm *TweeMerger) parseSource(data []byte) (*mergeSource, error) {
+	result := newValidationResult()
+	story := &Story{Passages: make(map[string]*Passage)}
+	NewTweeParser(tm.name).scanSource(data, result, story)
+
+	if !result.Valid {
+		return nil, validationError(result)
+	}
+	if story.Dialect == DialectTwee1 {
+		return nil, fmt.Errorf("merge per passaggi non supportato per sorgenti Twee 1")
+	}
+
+	src := &mergeSource{data: data, story: story, index: make(map[string]int, len(story.Order))}
+	for i, title := range story.Order {
+		src.index[title] = i
+	}
+	return src, nil
+}
+
+// chunk restituisce il passaggio i-esimo del sorgente, dall'intestazione alla successiva
+func (src *mergeSource) chunk(i int) mergeChunk {
+	start := src.story.Passages[src.story.Order[i]].Header.Offset
+	end := len(src.data)
+	if i+1 < len(src.story.Order) {
+		end = src.story.Passages[src.story.Order[i+1]].Header.Offset
+	}
+	return mergeChunk{source: src, index: i, text: src.data[start:end]}
+}
+
+// preamble restituisce il testo che precede il primo passaggio
+func (src *mergeSource) preamble() []byte {
+	if len(src.story.Order) == 0 {
+		return src.data
+	}
+	return src.data[:src.story.Passages[src.story.Order[0]].Header.Offset]
+}
+
+// Merge unisce ours e theirs rispetto all'antenato comune base
+// Se un sorgente non è analizzabile ripiega su un merge per righe dell'intero file
+func (tm *TweeMerger) Merge(base, ours, theirs []byte) *MergeResult {
+	result := &MergeResult{Conflicts: []MergeConflict{}}
+
+	b, err := tm.parseSource(base)
+	var o, t *mergeSource
+	if err == nil {
+		o, err = tm.parseSource(ours)
+	}
+	if err == nil {
+		t, err = tm.parseSource(theirs)
+	}
+	if err != nil {
+		lines, clean := tm.mergeLines(strings.Split(string(base), "\n"), strings.Split(string(ours), "\n"), strings.Split(string(theirs), "\n"))
+		result.Content = []byte(strings.Join(lines, "\n"))
+		if !clean {
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				Reason: fmt.Sprintf("sorgente non analizzabile, merge per righe: %s", strings.SplitN(err.Error(), "\n", 2)[0]),
+			})
+		}
+		return result
+	}
+
+	oursMatches := matchPassages(b.story, o.story)
+	theirsMatches := matchPassages(b.story, t.story)
+	baseOfOurs := invertMatches(oursMatches)
+	baseOfTheirs := invertMatches(theirsMatches)
+
+	// 1. Passaggi di ours, nell'ordine di ours
+	chunks := []mergeChunk{}
+	placed := make(map[string]int) // Titolo in theirs → chunk corrispondente
+
+	for i, title := range o.story.Order {
+		passage := o.story.Passages[title]
+		baseTitle, inBase := baseOfOurs[title]
+
+		if !inBase {
+			theirsPassage, exists := t.story.Passages[title]
+			_, theirsFromBase := baseOfTheirs[title]
+			switch {
+			case !exists:
+				chunks = append(chunks, o.chunk(i))
+			case theirsFromBase:
+				// Stesso titolo per passaggi diversi: il risultato avrà un duplicato da risolvere
+				result.Conflicts = append(result.Conflicts, MergeConflict{Title: title, Reason: "titolo usato da entrambi i lati per passaggi diversi"})
+				chunks = append(chunks, o.chunk(i))
+			case samePassage(passage, theirsPassage):
+				placed[title] = len(chunks)
+				chunks = append(chunks, o.chunk(i))
+			default:
+				placed[title] = len(chunks)
+				chunks = append(chunks, tm.conflictChunk(result, passage, theirsPassage, "aggiunto da entrambi i lati con contenuto diverso"))
+			}
+			continue
+		}
+
+		basePassage := b.story.Passages[baseTitle]
+		theirsTitle, inTheirs := theirsMatches[baseTitle]
+		if !inTheirs {
+			// Rimosso in theirs: resta solo se ours lo ha modificato
+			if !samePassage(basePassage, passage) {
+				chunks = append(chunks, tm.conflictChunk(result, passage, nil, "modificato in ours e rimosso in theirs"))
+			}
+			continue
+		}
+
+		placed[theirsTitle] = len(chunks)
+		chunks = append(chunks, tm.mergePassage(result, basePassage, o, i, t, t.index[theirsTitle]))
+	}
+
+	// 2. Passaggi presenti solo in theirs, dopo il passaggio che li precede in theirs
+	inserts := make(map[int][]mergeChunk)
+	anchor := -1
+	for i, title := range t.story.Order {
+		if index, exists := placed[title]; exists {
+			anchor = index
+			continue
+		}
+
+		passage := t.story.Passages[title]
+		baseTitle, inBase := baseOfTheirs[title]
+		switch {
+		case !inBase:
+			if _, exists := o.story.Passages[title]; exists {
+				result.Conflicts = append(result.Conflicts, MergeConflict{Title: title, Reason: "titolo usato da entrambi i lati per passaggi diversi"})
+			}
+			inserts[anchor] = append(inserts[anchor], t.chunk(i))
+		case samePassage(b.story.Passages[baseTitle], passage):
+			// Rimosso in ours, invariato in theirs
+		default:
+			inserts[anchor] = append(inserts[anchor], tm.conflictChunk(result, nil, passage, "rimosso in ours e modificato in theirs"))
+		}
+	}
+
+	// 3. Composizione: i passaggi consecutivi nello stesso sorgente restano intatti
+	var out bytes.Buffer
+	out.Write(o.preamble())
+	prev := mergeChunk{source: o, index: -1}
+
+	emit := func(chunk mergeChunk) {
+		if chunk.source == nil || chunk.source != prev.source || chunk.index != prev.index+1 {
+			for out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+				out.WriteString("\n")
+			}
+		}
+		out.Write(chunk.text)
+		prev = chunk
+	}
+
+	for _, chunk := range inserts[-1] {
+		emit(chunk)
+	}
+	for i, chunk := range chunks {
+		emit(chunk)
+		for _, inserted := range inserts[i] {
+			emit(inserted)
+		}
+	}
+
+	// Un passaggio spostato in fondo porta con sé le righe vuote di separazione
+	content := out.Bytes()
+	if prev.source != nil && prev.index != len(prev.source.story.Order)-1 {
+		content = append(bytes.TrimRight(content, "\r\n"), '\n')
+	}
+	result.Content = content
+
+	return result
+}
+
+// mergePassage unisce un passaggio presente in base, ours e theirs
+// Titolo, tag, posizione e metadata si uniscono campo per campo, il contenuto riga per riga
+func (tm *TweeMerger) mergePassage(result *MergeResult, base *Passage, o *mergeSource, oi int, t *mergeSource, ti int) mergeChunk {
+	ours := o.story.Passages[o.story.Order[oi]]
+	theirs := t.story.Passages[t.story.Order[ti]]
+
+	switch {
+	case samePassage(base, theirs) || samePassage(ours, theirs):
+		return o.chunk(oi)
+	case samePassage(base, ours):
+		return t.chunk(ti)
+	}
+
+	merged := *ours
+	conflicts := []string{}
+
+	if ours.Title == base.Title {
+		merged.Title = theirs.Title
+	} else if theirs.Title != base.Title && theirs.Title != ours.Title {
+		conflicts = append(conflicts, "titolo")
+	}
+
+	merged.Tags = mergeTags(base.Tags, ours.Tags, theirs.Tags)
+
+	if ours.Position == base.Position {
+		merged.Position = theirs.Position
+	} else if theirs.Position != base.Position && theirs.Position != ours.Position {
+		conflicts = append(conflicts, "posizione")
+	}
+	if ours.Size == base.Size {
+		merged.Size = theirs.Size
+	} else if theirs.Size != base.Size && theirs.Size != ours.Size {
+		conflicts = append(conflicts, "dimensione")
+	}
+	if reflect.DeepEqual(ours.Metadata, base.Metadata) {
+		merged.Metadata = theirs.Metadata
+	} else if !reflect.DeepEqual(theirs.Metadata, base.Metadata) && !reflect.DeepEqual(theirs.Metadata, ours.Metadata) {
+		conflicts = append(conflicts, "metadata")
+	}
+
+	// I conflitti dell'intestazione vengono mostrati in testa al contenuto
+	lines := []string{}
+	if len(conflicts) > 0 {
+		lines = tm.conflictBlock([]string{formatHeader(ours)}, []string{formatHeader(theirs)})
+	}
+
+	content, clean := tm.mergeLines(contentLines(base.Content), contentLines(ours.Content), contentLines(theirs.Content))
+	if !clean {
+		conflicts = append(conflicts, "contenuto")
+	}
+	merged.Content = strings.Join(append(lines, content...), "\n")
+
+	if len(conflicts) > 0 {
+		result.Conflicts = append(result.Conflicts, MergeConflict{
+			Title:  merged.Title,
+			Reason: fmt.Sprintf("modificato da entrambi i lati (%s)", strings.Join(conflicts, ", ")),
+		})
+	}
+
+	return generatedChunk(&merged)
+}
+
+// conflictChunk genera un passaggio con l'intero contenuto dei due lati tra i marcatori
+// Uno dei due lati può essere nil (passaggio rimosso da quel lato)
+func (tm *TweeMerger) conflictChunk(result *MergeResult, ours, theirs *Passage, reason string) mergeChunk {
+	header := ours
+	if header == nil {
+		header = theirs
+	}
+
+	lines := []string{}
+	oursLines, theirsLines := []string{}, []string{}
+	if ours != nil {
+		oursLines = contentLines(ours.Content)
+	}
+	if theirs != nil {
+		theirsLines = contentLines(theirs.Content)
+	}
+	if ours != nil && theirs != nil && formatHeader(ours) != formatHeader(theirs) {
+		lines = tm.conflictBlock([]string{formatHeader(ours)}, []string{formatHeader(theirs)})
+	}
+
+	conflicted := *header
+	conflicted.Content = strings.Join(append(lines, tm.conflictBlock(oursLines, theirsLines)...), "\n")
+
+	result.Conflicts = append(result.Conflicts, MergeConflict{Title: header.Title, Reason: reason})
+	return generatedChunk(&conflicted)
+}
+
+// conflictBlock racchiude le due versioni tra i marcatori di conflitto
+func (tm *TweeMerger) conflictBlock(ours, theirs []string) []string {
+	block := []string{"<<<<<<< " + tm.oursLabel}
+	block = append(block, ours...)
+	block = append(block, "=======")
+	block = append(block, theirs...)
+	return append(block, ">>>>>>> "+tm.theirsLabel)
+}
+
+// mergeLines esegue il merge a tre vie di una sequenza di righe (diff3)
+// Restituisce false se sono stati inseriti marcatori di conflitto
+func (tm *TweeMerger) mergeLines(base, ours, theirs []string) ([]string, bool) {
+	oursMatches := lcsLines(base, ours)
+	theirsMatches := lcsLines(base, theirs)
+
+	out := []string{}
+	clean := true
+	i, j, k := 0, 0, 0
+
+	for {
+		// Prossima riga stabile: presente invariata in base, ours e theirs
+		next := i
+		for next < len(base) && (oursMatches[next] < 0 || theirsMatches[next] < 0) {
+			next++
+		}
+		oursEnd, theirsEnd := len(ours), len(theirs)
+		if next < len(base) {
+			oursEnd, theirsEnd = oursMatches[next], theirsMatches[next]
+		}
+
+		// Blocco instabile tra la riga stabile precedente e la successiva
+		baseChunk, oursChunk, theirsChunk := base[i:next], ours[j:oursEnd], theirs[k:theirsEnd]
+		switch {
+		case reflect.DeepEqual(oursChunk, baseChunk):
+			out = append(out, theirsChunk...)
+		case reflect.DeepEqual(theirsChunk, baseChunk), reflect.DeepEqual(oursChunk, theirsChunk):
+			out = append(out, oursChunk...)
+		default:
+			out = append(out, tm.conflictBlock(oursChunk, theirsChunk)...)
+			clean = false
+		}
+
+		if next == len(base) {
+			break
+		}
+		out = append(out, base[next])
+		i, j, k = next+1, oursEnd+1, theirsEnd+1
+	}
+
+	return out, clean
+}
+
+// mergeTags unisce i tag: si parte da ours, si applicano aggiunte e rimozioni di theirs
+func mergeTags(base, ours, theirs []string) []string {
+	merged := []string{}
+	for _, tag := range ours {
+		if containsTitle(base, tag) && !containsTitle(theirs, tag) {
+			continue
+		}
+		merged = append(merged, tag)
+	}
+	for _, tag := range theirs {
+		if !containsTitle(base, tag) && !containsTitle(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// generatedChunk serializza un passaggio come fa TweeWriter
+func generatedChunk(passage *Passage) mergeChunk {
+	var buf bytes.Buffer
+	NewTweeWriter(&buf).writePassage(&buf, passage)
+	return mergeChunk{text: buf.Bytes()}
+}
+
+// contentLines divide il contenuto in righe (nessuna riga se vuoto)
+func contentLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(content, "\n")
+}
+
+// invertMatches inverte un'associazione vecchio → nuovo titolo
+func invertMatches(matches map[string]string) map[string]string {
+	inverted := make(map[string]string, len(matches))
+	for from, to := range matches {
+		inverted[to] = from
+	}
+	return inverted
+}
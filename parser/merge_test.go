@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const mergeBase = `:: StoryData
+{"ifid":"D674C58C-DEFA-4F70-B7A2-27742230C0FC","format":"Harlowe","start":"Inizio"}
+
+:: Inizio
+Benvenuto.
+[[Corridoio]]
+
+:: Corridoio
+Un corridoio.
+Una porta.
+[[Fine]]
+
+:: Fine
+Fine.
+`
+
+// mergeTwee esegue il merge e verifica che il risultato sia analizzabile
+func mergeTwee(t *testing.T, base, ours, theirs string) *MergeResult {
+	t.Helper()
+
+	result := NewTweeMerger("story.twee").Merge([]byte(base), []byte(ours), []byte(theirs))
+	if _, err := ParseString(string(result.Content), "merged.twee"); err != nil {
+		t.Fatalf("Merged source does not parse: %v\n%s", err, result.Content)
+	}
+	return result
+}
+
+// ============================================
+// Test: Merge a tre vie
+// ============================================
+
+func TestMergeNonOverlappingEdits(t *testing.T) {
+	ours := strings.Replace(mergeBase, "Benvenuto.", "Benvenuto, viaggiatore.", 1)
+	ours = strings.Replace(ours, "Fine.\n", "Fine.\n\n:: Segreto\nHai trovato il segreto.\n", 1)
+	theirs := strings.Replace(mergeBase, "Una porta.", "Una porta socchiusa.", 1)
+	theirs = strings.Replace(theirs, ":: Corridoio\n", ":: Corridoio [buio]\n", 1)
+	theirs = strings.Replace(theirs, "[[Fine]]\n\n:: Fine", "[[Fine]]\n\n:: Cantina\nUmido.\n\n:: Fine", 1)
+
+	result := mergeTwee(t, mergeBase, ours, theirs)
+	if !result.Clean() {
+		t.Fatalf("Expected clean merge, got %+v\n%s", result.Conflicts, result.Content)
+	}
+
+	expected := strings.Replace(ours, "Una porta.", "Una porta socchiusa.", 1)
+	expected = strings.Replace(expected, ":: Corridoio\n", ":: Corridoio [buio]\n", 1)
+	expected = strings.Replace(expected, "[[Fine]]\n\n:: Fine", "[[Fine]]\n\n:: Cantina\nUmido.\n\n:: Fine", 1)
+	if string(result.Content) != expected {
+		t.Errorf("Unexpected merge result:\n%s\nexpected:\n%s", result.Content, expected)
+	}
+
+	t.Log("✅ Non-overlapping passage edits merged byte for byte")
+}
+
+func TestMergeSamePassageDifferentLines(t *testing.T) {
+	ours := strings.Replace(mergeBase, "Un corridoio.", "Un corridoio stretto.", 1)
+	theirs := strings.Replace(mergeBase, "[[Fine]]", "[[Torna indietro->Inizio]]\n[[Fine]]", 1)
+
+	result := mergeTwee(t, mergeBase, ours, theirs)
+	if !result.Clean() {
+		t.Fatalf("Expected clean merge, got %+v", result.Conflicts)
+	}
+	if !strings.Contains(string(result.Content), "Un corridoio stretto.\nUna porta.\n[[Torna indietro->Inizio]]\n[[Fine]]\n") {
+		t.Errorf("Expected both line edits, got:\n%s", result.Content)
+	}
+
+	t.Log("✅ Edits to different lines of the same passage merged")
+}
+
+func TestMergeConflictMarkersInsidePassage(t *testing.T) {
+	ours := strings.Replace(mergeBase, "Una porta.", "Una porta blu.", 1)
+	ours = strings.Replace(ours, "Benvenuto.", "Ciao.", 1)
+	theirs := strings.Replace(mergeBase, "Una porta.", "Una porta verde.", 1)
+
+	result := mergeTwee(t, mergeBase, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Title != "Corridoio" {
+		t.Fatalf("Expected one conflict in Corridoio, got %+v", result.Conflicts)
+	}
+
+	story, _ := ParseString(string(result.Content), "merged.twee")
+	expected := "Un corridoio.\n<<<<<<< ours\nUna porta blu.\n=======\nUna porta verde.\n>>>>>>> theirs\n[[Fine]]"
+	if story.Passages["Corridoio"].Content != expected {
+		t.Errorf("Unexpected conflict content:\n%s", story.Passages["Corridoio"].Content)
+	}
+	if story.Passages["Inizio"].Content != "Ciao.\n[[Corridoio]]" || strings.Count(string(result.Content), "<<<<<<<") != 1 {
+		t.Errorf("Expected markers only inside Corridoio:\n%s", result.Content)
+	}
+
+	t.Log("✅ Conflict markers limited to the conflicting passage")
+}
+
+func TestMergeRenameAndDelete(t *testing.T) {
+	// ours rinomina Corridoio, theirs ne modifica il contenuto
+	ours := strings.Replace(mergeBase, ":: Corridoio", ":: Galleria", 1)
+	theirs := strings.Replace(mergeBase, "Una porta.", "Una porta aperta.", 1)
+
+	result := mergeTwee(t, mergeBase, ours, theirs)
+	story, _ := ParseString(string(result.Content), "merged.twee")
+	if !result.Clean() || story.Passages["Galleria"] == nil || !strings.Contains(story.Passages["Galleria"].Content, "Una porta aperta.") {
+		t.Errorf("Expected renamed passage with theirs content, got %+v\n%s", result.Conflicts, result.Content)
+	}
+
+	// ours rimuove Fine, theirs lo modifica
+	ours = strings.Replace(mergeBase, ":: Fine\nFine.\n", "", 1)
+	theirs = strings.Replace(mergeBase, "Fine.\n", "Fine davvero.\n", 1)
+
+	result = mergeTwee(t, mergeBase, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Title != "Fine" {
+		t.Errorf("Expected modify/delete conflict on Fine, got %+v", result.Conflicts)
+	}
+
+	// ours rimuove Fine, theirs non lo tocca
+	theirs = strings.Replace(mergeBase, "Benvenuto.", "Ciao.", 1)
+	result = mergeTwee(t, mergeBase, ours, theirs)
+	if !result.Clean() || strings.Contains(string(result.Content), ":: Fine") {
+		t.Errorf("Expected Fine removed cleanly:\n%s", result.Content)
+	}
+
+	t.Log("✅ Renames and deletions merged")
+}
+
+func TestMergeFallsBackToLines(t *testing.T) {
+	broken := mergeBase + "\n:: Fine\nDuplicato.\n"
+	result := NewTweeMerger("story.twee").Merge([]byte(mergeBase), []byte(broken), []byte(mergeBase))
+
+	if !result.Clean() || string(result.Content) != broken {
+		t.Errorf("Expected line merge to keep ours, got %+v\n%s", result.Conflicts, result.Content)
+	}
+
+	t.Log("✅ Unparsable sources merged line by line")
+}
@@ -1,28 +1,135 @@
 package parser
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 // Passage rappresenta un singolo passaggio Twine
 type Passage struct {
-	Title      string            `json:"title"`
-	Tags       []string          `json:"tags"`
-	Content    string            `json:"content"`
-	Position   Position          `json:"position"`
-	Metadata   map[string]string `json:"metadata"`
-	ParsedAt   time.Time         `json:"parsed_at"`
+	Title    string                 `json:"title"`
+	Tags     []string               `json:"tags"`
+	Content  string                 `json:"content"`
+	Position Position               `json:"position"`
+	Size     Size                   `json:"size"`
+	Metadata map[string]interface{} `json:"metadata"` // Chiavi metadata non riconosciute
+	ParsedAt time.Time              `json:"parsed_at"`
+
+	// Posizione nel sorgente
+	File      string    `json:"file,omitempty"`
+	Header    SourcePos `json:"header"`     // Inizio della riga "::"
+	BodyStart SourcePos `json:"body_start"` // Primo byte di Content
+	BodyEnd   SourcePos `json:"body_end"`   // Byte successivo all'ultimo di Content
+
+	crlf bool // Il sorgente usa terminatori \r\n
 }
 
 // Position rappresenta la posizione del passaggio nell'editor
 type Position struct {
-	X int `json:"x"`
-	Y int `json:"y"`
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Size rappresenta la dimensione del passaggio nell'editor
+// Zero significa dimensione non specificata (Twine usa 100,100)
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// SourcePos rappresenta una posizione nel file sorgente
+// Line e Column partono da 1, Offset è in byte dall'inizio del file
+type SourcePos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// PositionAt converte un offset relativo a Content in una posizione nel file
+// Usato per mappare macro e link trovati dai formati sul sorgente originale
+func (p *Passage) PositionAt(offset int) SourcePos {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(p.Content) {
+		offset = len(p.Content)
+	}
+
+	before := p.Content[:offset]
+	newlines := strings.Count(before, "\n")
+
+	pos := SourcePos{
+		Line:   p.BodyStart.Line + newlines,
+		Offset: p.BodyStart.Offset + offset,
+	}
+
+	if newlines == 0 {
+		pos.Column = p.BodyStart.Column + offset
+	} else {
+		pos.Column = offset - strings.LastIndex(before, "\n")
+	}
+
+	// Content usa sempre \n: ogni riga attraversata ha un \r in più nel file
+	if p.crlf {
+		pos.Offset += newlines
+	}
+
+	return pos
 }
 
 // Story rappresenta l'intera storia
 type Story struct {
-	Title     string              `json:"title"`
-	Passages  map[string]*Passage `json:"passages"`
-	IFID      string              `json:"ifid"`
-	Format    string              `json:"format"`
-	FormatVersion string           `json:"format_version"`
+	Title    string              `json:"title"`
+	Passages map[string]*Passage `json:"passages"`
+	Order    []string            `json:"order"` // Titoli in ordine di sorgente
+
+	// Dati del passaggio StoryData (IFID, Format, FormatVersion, Start, ...)
+	StoryData
+
+	// Sintassi del sorgente e dati specifici di Twee 1
+	Dialect  Dialect           `json:"dialect,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"` // StorySettings
+	Includes []string          `json:"includes,omitempty"` // StoryIncludes
+}
+
+// AddPassage aggiunge un passaggio mantenendo l'ordine di inserimento
+// Un passaggio con titolo già presente sostituisce il precedente nella stessa posizione
+func (s *Story) AddPassage(passage *Passage) {
+	if s.Passages == nil {
+		s.Passages = make(map[string]*Passage)
+	}
+
+	if _, exists := s.Passages[passage.Title]; !exists {
+		s.Order = append(s.Order, passage.Title)
+	}
+	s.Passages[passage.Title] = passage
+}
+
+// OrderedPassages restituisce i passaggi in ordine di sorgente
+// I passaggi presenti solo nella mappa (aggiunti a mano) seguono in ordine alfabetico
+func (s *Story) OrderedPassages() []*Passage {
+	passages := make([]*Passage, 0, len(s.Passages))
+	seen := make(map[string]bool, len(s.Passages))
+
+	for _, title := range s.Order {
+		if passage, exists := s.Passages[title]; exists && !seen[title] {
+			passages = append(passages, passage)
+			seen[title] = true
+		}
+	}
+
+	extra := []string{}
+	for title := range s.Passages {
+		if !seen[title] {
+			extra = append(extra, title)
+		}
+	}
+	sort.Strings(extra)
+
+	for _, title := range extra {
+		passages = append(passages, s.Passages[title])
+	}
+
+	return passages
 }
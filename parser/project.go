@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectLoader carica un progetto Twee composto da più file e cartelle
+// Come Tweego accetta file singoli e directory (visitate ricorsivamente)
+type ProjectLoader struct {
+	paths []string
+}
+
+// NewProjectLoader crea un nuovo loader per i path indicati
+func NewProjectLoader(paths ...string) *ProjectLoader {
+	return &ProjectLoader{paths: paths}
+}
+
+// IsTweeFile verifica se un file ha un'estensione sorgente Twee (.twee o .tw)
+func IsTweeFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".twee" || ext == ".tw"
+}
+
+// Files restituisce i file sorgente del progetto in ordine stabile
+// I file indicati esplicitamente mantengono l'ordine dato, quelli nelle directory
+// seguono in ordine alfabetico di path
+func (pl *ProjectLoader) Files() ([]string, error) {
+	files := []string{}
+	seen := map[string]bool{}
+
+	add := func(path string) {
+		clean := filepath.Clean(path)
+		if !seen[clean] {
+			seen[clean] = true
+			files = append(files, clean)
+		}
+	}
+
+	for _, path := range pl.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("path non trovato: %s", path)
+		}
+
+		if !info.IsDir() {
+			add(path)
+			continue
+		}
+
+		dirFiles := []string{}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Salta directory nascoste (.git, .vscode, ...)
+			if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && IsTweeFile(p) {
+				dirFiles = append(dirFiles, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("errore lettura directory %s: %w", path, err)
+		}
+
+		sort.Strings(dirFiles)
+		for _, f := range dirFiles {
+			add(f)
+		}
+	}
+
+	return files, nil
+}
+
+// Validate valida tutti i file del progetto
+// Oltre ai controlli sui singoli file segnala i titoli duplicati tra file diversi
+// e verifica il passaggio iniziale sull'intero progetto
+func (pl *ProjectLoader) Validate() *ValidationResult {
+	return pl.scan(nil)
+}
+
+// scan valida i file del progetto e, se story non è nil, ne carica i passaggi
+// Ogni file viene letto una sola volta
+func (pl *ProjectLoader) scan(story *Story) *ValidationResult {
+	result := newValidationResult()
+
+	files, err := pl.Files()
+	if err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Type:    "error",
+			Message: err.Error(),
+		})
+		return result
+	}
+
+	if len(files) == 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Type:    "error",
+			Message: fmt.Sprintf("Nessun file .twee/.tw trovato in: %s", strings.Join(pl.paths, ", ")),
+		})
+		return result
+	}
+
+	merged := &sourceScan{
+		file:     files[0],
+		passages: map[string]SourcePos{},
+		files:    map[string]string{},
+		kinds:    map[string]PassageKind{},
+	}
+
+	for _, file := range files {
+		tp := NewTweeParser(file)
+		data, ok := tp.readFile(result)
+		if !ok {
+			continue
+		}
+
+		// DialectAuto finché nessun file ha un dialetto esplicito: il file lo usa senza imporlo
+		if story != nil {
+			story.Dialect = merged.dialect
+		}
+		scan := tp.scanSource(data, result, story)
+		pl.mergeScan(merged, scan, result)
+	}
+
+	if merged.dialect == DialectAuto {
+		merged.dialect = DialectTwee3
+	}
+	if story != nil {
+		story.Dialect = merged.dialect
+	}
+
+	checkStory(merged, result)
+
+	return result
+}
+
+// mergeScan unisce le informazioni di un file a quelle del progetto
+func (pl *ProjectLoader) mergeScan(merged *sourceScan, scan *sourceScan, result *ValidationResult) {
+	// Ordine di sorgente, per errori deterministici
+	titles := make([]string, 0, len(scan.passages))
+	for title := range scan.passages {
+		titles = append(titles, title)
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		return scan.passages[titles[i]].Offset < scan.passages[titles[j]].Offset
+	})
+
+	for _, title := range titles {
+		pos := scan.passages[title]
+		if first, exists := merged.passages[title]; exists {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Type: "error",
+				Message: fmt.Sprintf("Passaggio '%s' definito due volte (%s:%d e %s:%d)",
+					title, merged.files[title], first.Line, scan.file, pos.Line),
+				File:   scan.file,
+				Line:   pos.Line,
+				Column: pos.Column,
+			})
+			continue
+		}
+		merged.passages[title] = pos
+		merged.files[title] = scan.file
+		merged.kinds[title] = scan.kinds[title]
+	}
+
+	if scan.hasStoryData {
+		merged.hasStoryData = true
+	}
+	// Il primo file con un dialetto esplicito lo stabilisce per tutto il progetto
+	switch {
+	case !scan.explicit:
+	case merged.dialect == DialectAuto:
+		merged.dialect = scan.dialect
+		merged.dialectFile = scan.file
+	case merged.dialect != scan.dialect:
+		result.Warnings = append(result.Warnings, ValidationError{
+			Type: "warning",
+			Message: fmt.Sprintf("Il file usa la sintassi %s, ma il progetto usa %s (stabilita da %s)",
+				scan.dialect, merged.dialect, merged.dialectFile),
+			File:   scan.file,
+			Line:   1,
+			Column: 1,
+		})
+	}
+	if scan.start != "" {
+		merged.start = scan.start
+		merged.startFile = scan.startFile
+		merged.startPos = scan.startPos
+	}
+}
+
+// Load valida e carica il progetto in un'unica Story
+// Ogni passaggio conserva in File il file da cui proviene
+func (pl *ProjectLoader) Load() (*Story, error) {
+	story := &Story{
+		Passages: make(map[string]*Passage),
+	}
+
+	if validation := pl.scan(story); !validation.Valid {
+		return nil, validationError(validation)
+	}
+
+	return story, nil
+}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProject crea un progetto multi-file in una directory temporanea
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// ============================================
+// Test: Progetti multi-file
+// ============================================
+
+func TestProjectLoadFromDirectory(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"a_data.twee":       ":: StoryData\n{\"ifid\":\"D674C58C-DEFA-4F70-B7A2-27742230C0FC\",\"format\":\"Harlowe\",\"start\":\"Inizio\"}\n",
+		"capitoli/uno.tw":   ":: Inizio\n[[Fine]]\n",
+		"capitoli/due.twee": ":: Fine\nFine.\n",
+		"note.txt":          ":: Ignorato\n",
+	})
+
+	story, err := NewProjectLoader(dir).Load()
+	if err != nil {
+		t.Fatalf("Error loading project: %v", err)
+	}
+
+	if len(story.Passages) != 3 {
+		t.Errorf("Expected 3 passages, got %d", len(story.Passages))
+	}
+
+	inizio := story.Passages["Inizio"]
+	if inizio == nil || filepath.Base(inizio.File) != "uno.tw" {
+		t.Errorf("Expected 'Inizio' from uno.tw, got %+v", inizio)
+	}
+
+	t.Log("✅ Project loaded from nested directories")
+}
+
+func TestProjectDuplicateAcrossFiles(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"a.twee": ":: StoryData\n{\"start\":\"Inizio\"}\n\n:: Inizio\nuno\n",
+		"b.twee": ":: Altro\nx\n\n:: Inizio\ndue\n",
+	})
+
+	validation := NewProjectLoader(dir).Validate()
+	if validation.Valid {
+		t.Fatal("Expected validation to fail on cross-file duplicate")
+	}
+
+	dupErr := validation.Errors[0]
+	if filepath.Base(dupErr.File) != "b.twee" || dupErr.Line != 4 {
+		t.Errorf("Expected error at b.twee:4, got %s:%d", dupErr.File, dupErr.Line)
+	}
+	if !strings.Contains(dupErr.Message, "a.twee:4") || !strings.Contains(dupErr.Message, "b.twee:4") {
+		t.Errorf("Expected both locations in message, got %q", dupErr.Message)
+	}
+
+	t.Log("✅ Cross-file duplicates reported with both locations")
+}
+
+func TestProjectMixedDialects(t *testing.T) {
+	twee1 := ":: StorySettings\nundo:off\n\n:: Start <10,20>\n[[Fine]]\n"
+	twee3 := ":: Fine {\"position\":\"100,100\"}\nFine.\n"
+	plain := ":: Extra\nNessun indizio sul dialetto.\n"
+
+	dir := writeProject(t, map[string]string{
+		"a_plain.twee": plain,
+		"b_vecchio.tw": twee1,
+		"c_nuovo.twee": twee3,
+	})
+
+	story, err := NewProjectLoader(dir).Load()
+	if err != nil {
+		t.Fatalf("Error loading project: %v", err)
+	}
+	if story.Dialect != DialectTwee1 {
+		t.Errorf("Expected the first explicit dialect (twee1), got %q", story.Dialect)
+	}
+	if story.Settings["undo"] != "off" {
+		t.Errorf("Expected StorySettings to be read, got %v", story.Settings)
+	}
+
+	validation := NewProjectLoader(dir).Validate()
+	conflicts := []string{}
+	for _, warning := range validation.Warnings {
+		if strings.Contains(warning.Message, "sintassi") {
+			conflicts = append(conflicts, filepath.Base(warning.File))
+		}
+	}
+	if len(conflicts) != 1 || conflicts[0] != "c_nuovo.twee" {
+		t.Errorf("Expected a dialect warning only for c_nuovo.twee, got %v", conflicts)
+	}
+
+	// Con i file in ordine inverso vince Twee 3
+	dir = writeProject(t, map[string]string{
+		"a_nuovo.twee": twee3,
+		"b_vecchio.tw": twee1,
+	})
+	story, err = NewProjectLoader(dir).Load()
+	if err != nil {
+		t.Fatalf("Error loading project: %v", err)
+	}
+	if story.Dialect != DialectTwee3 {
+		t.Errorf("Expected twee3 from the first file, got %q", story.Dialect)
+	}
+
+	t.Log("✅ Project dialect comes from the first explicit file")
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// PassageKind classifica un passaggio in base a titolo e tag
+type PassageKind string
+
+const (
+	KindStory        PassageKind = "story"         // Passaggio ordinario
+	KindStoryTitle   PassageKind = "story-title"   // :: StoryTitle
+	KindStoryData    PassageKind = "story-data"    // :: StoryData
+	KindScript       PassageKind = "script"        // Tag script (JavaScript della storia)
+	KindStylesheet   PassageKind = "stylesheet"    // Tag stylesheet (CSS della storia)
+	KindStartup      PassageKind = "startup"       // Harlowe: eseguito una volta all'avvio
+	KindHeader       PassageKind = "header"        // Harlowe: anteposto a ogni passaggio
+	KindFooter       PassageKind = "footer"        // Harlowe: aggiunto in coda a ogni passaggio
+	KindDebugStartup PassageKind = "debug-startup" // Harlowe: startup solo in debug mode
+	KindDebugHeader  PassageKind = "debug-header"  // Harlowe: header solo in debug mode
+	KindDebugFooter  PassageKind = "debug-footer"  // Harlowe: footer solo in debug mode
+)
+
+// kindTags elenca i tag speciali in ordine di priorità
+// Un passaggio con più tag speciali assume il tipo del primo trovato
+var kindTags = []PassageKind{
+	KindScript,
+	KindStylesheet,
+	KindDebugStartup,
+	KindDebugHeader,
+	KindDebugFooter,
+	KindStartup,
+	KindHeader,
+	KindFooter,
+}
+
+// Kind restituisce il tipo del passaggio
+func (p *Passage) Kind() PassageKind {
+	switch p.Title {
+	case "StoryTitle":
+		return KindStoryTitle
+	case "StoryData":
+		return KindStoryData
+	}
+
+	for _, kind := range kindTags {
+		if p.HasTag(string(kind)) {
+			return kind
+		}
+	}
+	return KindStory
+}
+
+// HasTag verifica se il passaggio ha il tag indicato (case-insensitive, come Harlowe)
+func (p *Passage) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSpecial verifica se il passaggio ha un ruolo speciale
+func (p *Passage) IsSpecial() bool {
+	return p.Kind() != KindStory
+}
+
+// IsNavigable verifica se il passaggio può essere visitato dal lettore
+// StoryTitle, StoryData, script e stylesheet non diventano passaggi della storia compilata;
+// startup, header e footer restano passaggi raggiungibili
+func (p *Passage) IsNavigable() bool {
+	return p.Kind().IsNavigable()
+}
+
+// IsNavigable verifica se i passaggi di questo tipo possono essere visitati
+func (k PassageKind) IsNavigable() bool {
+	switch k {
+	case KindStoryTitle, KindStoryData, KindScript, KindStylesheet:
+		return false
+	}
+	return true
+}
+
+// PassagesOfKind restituisce i passaggi del tipo indicato in ordine di sorgente
+func (s *Story) PassagesOfKind(kind PassageKind) []*Passage {
+	passages := []*Passage{}
+	for _, passage := range s.OrderedPassages() {
+		if passage.Kind() == kind {
+			passages = append(passages, passage)
+		}
+	}
+	return passages
+}
+
+// StoryTitlePassage restituisce il passaggio StoryTitle, o nil
+func (s *Story) StoryTitlePassage() *Passage {
+	return s.Passages["StoryTitle"]
+}
+
+// StoryDataPassage restituisce il passaggio StoryData, o nil
+func (s *Story) StoryDataPassage() *Passage {
+	return s.Passages["StoryData"]
+}
+
+// Scripts restituisce i passaggi con tag script
+func (s *Story) Scripts() []*Passage {
+	return s.PassagesOfKind(KindScript)
+}
+
+// Stylesheets restituisce i passaggi con tag stylesheet
+func (s *Story) Stylesheets() []*Passage {
+	return s.PassagesOfKind(KindStylesheet)
+}
+
+// StartupPassages restituisce i passaggi startup (con debug-startup se debug è true)
+func (s *Story) StartupPassages(debug bool) []*Passage {
+	return s.passagesOfKinds(KindStartup, KindDebugStartup, debug)
+}
+
+// HeaderPassages restituisce i passaggi header (con debug-header se debug è true)
+func (s *Story) HeaderPassages(debug bool) []*Passage {
+	return s.passagesOfKinds(KindHeader, KindDebugHeader, debug)
+}
+
+// FooterPassages restituisce i passaggi footer (con debug-footer se debug è true)
+func (s *Story) FooterPassages(debug bool) []*Passage {
+	return s.passagesOfKinds(KindFooter, KindDebugFooter, debug)
+}
+
+// StoryPassages restituisce i passaggi ordinari in ordine di sorgente
+func (s *Story) StoryPassages() []*Passage {
+	return s.PassagesOfKind(KindStory)
+}
+
+// passagesOfKinds restituisce i passaggi del tipo base e, in debug, di quello debug
+// Come in Harlowe l'ordine è alfabetico per titolo
+func (s *Story) passagesOfKinds(kind, debugKind PassageKind, debug bool) []*Passage {
+	passages := []*Passage{}
+	for _, passage := range s.Passages {
+		// Un passaggio può avere più ruoli Harlowe (es. startup e header)
+		if !passage.IsNavigable() {
+			continue
+		}
+		if passage.HasTag(string(kind)) || (debug && passage.HasTag(string(debugKind))) {
+			passages = append(passages, passage)
+		}
+	}
+	sort.Slice(passages, func(i, j int) bool {
+		return passages[i].Title < passages[j].Title
+	})
+	return passages
+}
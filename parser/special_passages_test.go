@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+)
+
+const specialSource = `:: StoryTitle
+Il Castello
+
+:: StoryData
+{"ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC", "name": "Ignorato", "start": "Inizio"}
+
+:: Script [script]
+window.x = 1;
+
+:: Stile [stylesheet]
+body {}
+
+:: Zeta Header [header]
+HUD
+
+:: Alfa Header [header]
+Menu
+
+:: Debug [debug-header]
+Debug
+
+:: Avvio [startup header]
+(set: $vita to 100)
+
+:: Piede [footer]
+Fine pagina
+
+:: Inizio
+Ciao
+`
+
+// ============================================
+// Test: Passaggi speciali
+// ============================================
+
+func TestPassageKinds(t *testing.T) {
+	story := parseSource(t, specialSource)
+
+	tests := map[string]PassageKind{
+		"StoryTitle":  KindStoryTitle,
+		"StoryData":   KindStoryData,
+		"Script":      KindScript,
+		"Stile":       KindStylesheet,
+		"Zeta Header": KindHeader,
+		"Debug":       KindDebugHeader,
+		"Avvio":       KindStartup,
+		"Piede":       KindFooter,
+		"Inizio":      KindStory,
+	}
+
+	for title, expected := range tests {
+		if kind := story.Passages[title].Kind(); kind != expected {
+			t.Errorf("[%s] Expected kind %s, got %s", title, expected, kind)
+		}
+	}
+
+	if story.Title != "Il Castello" {
+		t.Errorf("Expected title from StoryTitle, got %q", story.Title)
+	}
+
+	t.Log("✅ Special passages classified correctly")
+}
+
+func TestSpecialPassageAccessors(t *testing.T) {
+	story := parseSource(t, specialSource)
+
+	titles := func(passages []*Passage) []string {
+		result := []string{}
+		for _, p := range passages {
+			result = append(result, p.Title)
+		}
+		return result
+	}
+
+	tests := []struct {
+		got      []string
+		expected []string
+		name     string
+	}{
+		{titles(story.Scripts()), []string{"Script"}, "Scripts"},
+		{titles(story.Stylesheets()), []string{"Stile"}, "Stylesheets"},
+		{titles(story.StartupPassages(false)), []string{"Avvio"}, "Startup"},
+		{titles(story.HeaderPassages(false)), []string{"Alfa Header", "Avvio", "Zeta Header"}, "Header (ordine alfabetico)"},
+		{titles(story.HeaderPassages(true)), []string{"Alfa Header", "Avvio", "Debug", "Zeta Header"}, "Header in debug"},
+		{titles(story.FooterPassages(false)), []string{"Piede"}, "Footer"},
+		{titles(story.StoryPassages()), []string{"Inizio"}, "Passaggi ordinari"},
+	}
+
+	for _, test := range tests {
+		if len(test.got) != len(test.expected) {
+			t.Errorf("[%s] Expected %v, got %v", test.name, test.expected, test.got)
+			continue
+		}
+		for i := range test.got {
+			if test.got[i] != test.expected[i] {
+				t.Errorf("[%s] Expected %v, got %v", test.name, test.expected, test.got)
+				break
+			}
+		}
+	}
+
+	if story.StoryTitlePassage() == nil || story.StoryDataPassage() == nil {
+		t.Error("Expected StoryTitle and StoryData accessors")
+	}
+
+	t.Log("✅ Special passage accessors work correctly")
+}
+
+func TestStartPassageMustBeNavigable(t *testing.T) {
+	validation := validateSource(t, ":: StoryData\n{\"start\": \"Script\"}\n\n:: Script [script]\nx\n")
+	if validation.Valid {
+		t.Error("Expected error when start passage is a script")
+	}
+
+	t.Log("✅ Special start passage rejected")
+}
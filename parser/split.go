@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SplitManifest è il file che registra l'ordine dei passaggi di una storia divisa
+const SplitManifest = "order.txt"
+
+// FolderRule sceglie la cartella (relativa) di un passaggio; "" indica la radice
+type FolderRule func(passage *Passage) string
+
+// FolderByTag mette ogni passaggio nella cartella del suo primo tag
+// I passaggi senza tag restano nella radice
+func FolderByTag(passage *Passage) string {
+	if len(passage.Tags) == 0 {
+		return ""
+	}
+	return passage.Tags[0]
+}
+
+// FolderByPrefix usa come cartella la parte del titolo prima del separatore
+// Es. con " - " il passaggio "Capitolo 1 - Cantina" va in "Capitolo 1"
+func FolderByPrefix(separator string) FolderRule {
+	return func(passage *Passage) string {
+		prefix, _, found := strings.Cut(passage.Title, separator)
+		if !found {
+			return ""
+		}
+		return strings.TrimSpace(prefix)
+	}
+}
+
+// StorySplitter divide una storia in un file .twee per passaggio
+// StoryTitle e StoryData hanno un file proprio nella radice; l'ordine dei passaggi
+// è registrato in SplitManifest, così che StoryJoiner ricostruisca lo stesso sorgente
+type StorySplitter struct {
+	dir  string
+	rule FolderRule
+}
+
+// NewStorySplitter crea uno splitter che scrive nella directory indicata
+func NewStorySplitter(dir string) *StorySplitter {
+	return &StorySplitter{dir: dir}
+}
+
+// SetFolderRule imposta la regola che assegna i passaggi alle cartelle (nil: tutti nella radice)
+func (ss *StorySplitter) SetFolderRule(rule FolderRule) {
+	ss.rule = rule
+}
+
+// Split scrive i file della storia e restituisce i path relativi in ordine
+// Se la directory contiene una divisione precedente, i suoi file vengono prima rimossi;
+// una directory non vuota senza manifest non viene toccata
+func (ss *StorySplitter) Split(story *Story) ([]string, error) {
+	if err := ss.clean(); err != nil {
+		return nil, err
+	}
+
+	writer := NewTweeWriter(nil)
+	files := []string{}
+	used := map[string]bool{} // Path in minuscolo, per i filesystem case-insensitive
+
+	write := func(folder string, passage *Passage) error {
+		path := uniquePath(folder, sanitizeFileName(passage.Title), used)
+
+		var buf bytes.Buffer
+		writer.writePassage(&buf, passage)
+
+		full := filepath.Join(ss.dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return fmt.Errorf("errore creazione cartella: %w", err)
+		}
+		if err := os.WriteFile(full, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("errore scrittura %s: %w", path, err)
+		}
+		files = append(files, path)
+		return nil
+	}
+
+	// 1. StoryTitle e StoryData, come in TweeWriter
+	title := story.Title
+	if title == "" {
+		if passage := story.StoryTitlePassage(); passage != nil {
+			title = passage.Content
+		}
+	}
+	if title != "" {
+		if err := write("", &Passage{Title: "StoryTitle", Content: title}); err != nil {
+			return nil, err
+		}
+	}
+
+	storyData, err := writer.buildStoryData(story)
+	if err != nil {
+		return nil, err
+	}
+	if storyData != "" {
+		if err := write("", &Passage{Title: "StoryData", Content: storyData}); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Passaggi in ordine di sorgente, nella cartella scelta dalla regola
+	for _, passage := range story.OrderedPassages() {
+		if passage.Title == "StoryTitle" || passage.Title == "StoryData" {
+			continue
+		}
+
+		folder := ""
+		if ss.rule != nil {
+			folder = sanitizeFileName(ss.rule(passage))
+			if folder == "_" {
+				folder = ""
+			}
+		}
+		if err := write(folder, passage); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Manifest con l'ordine
+	manifest := strings.Join(files, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(ss.dir, SplitManifest), []byte(manifest), 0644); err != nil {
+		return nil, fmt.Errorf("errore scrittura manifest: %w", err)
+	}
+
+	return files, nil
+}
+
+// clean prepara la directory: rimuove i file di una divisione precedente
+func (ss *StorySplitter) clean() error {
+	manifest, err := readManifest(ss.dir)
+	if os.IsNotExist(err) {
+		entries, err := os.ReadDir(ss.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("errore lettura directory %s: %w", ss.dir, err)
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("la directory %s non è vuota e non contiene %s", ss.dir, SplitManifest)
+		}
+		return os.MkdirAll(ss.dir, 0755)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, path := range manifest {
+		full := filepath.Join(ss.dir, filepath.FromSlash(path))
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("errore rimozione %s: %w", path, err)
+		}
+		// Le cartelle rimaste vuote vengono rimosse (os.Remove fallisce se non lo sono)
+		if dir := filepath.Dir(full); dir != filepath.Clean(ss.dir) {
+			os.Remove(dir)
+		}
+	}
+	return nil
+}
+
+// StoryJoiner ricompone una storia divisa da StorySplitter
+type StoryJoiner struct {
+	dir string
+}
+
+// NewStoryJoiner crea un joiner per la directory indicata
+func NewStoryJoiner(dir string) *StoryJoiner {
+	return &StoryJoiner{dir: dir}
+}
+
+// Join restituisce il sorgente Twee ricomposto
+// I file seguono l'ordine del manifest; i file .twee aggiunti dopo la divisione
+// vengono accodati in ordine alfabetico di path
+func (sj *StoryJoiner) Join() ([]byte, error) {
+	manifest, err := readManifest(sj.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]bool, len(manifest))
+	for _, path := range manifest {
+		listed[path] = true
+	}
+
+	files, err := NewProjectLoader(sj.dir).Files()
+	if err != nil {
+		return nil, err
+	}
+	paths := append([]string{}, manifest...)
+	for _, file := range files {
+		rel, err := filepath.Rel(sj.dir, file)
+		if err != nil {
+			return nil, err
+		}
+		if rel = filepath.ToSlash(rel); !listed[rel] {
+			paths = append(paths, rel)
+		}
+	}
+
+	// Stessa separazione di TweeWriter: due righe vuote tra i passaggi
+	var buf bytes.Buffer
+	for _, path := range paths {
+		data, err := os.ReadFile(filepath.Join(sj.dir, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file %s elencato in %s non trovato", path, SplitManifest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("errore lettura %s: %w", path, err)
+		}
+
+		if buf.Len() > 0 {
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\n\n")
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Parse ricompone e parsa la storia
+// Le posizioni nel sorgente si riferiscono al sorgente ricomposto
+func (sj *StoryJoiner) Parse() (*Story, error) {
+	data, err := sj.Join()
+	if err != nil {
+		return nil, err
+	}
+	return NewTweeParser(filepath.Join(sj.dir, SplitManifest)).parseData(data, newValidationResult())
+}
+
+// readManifest legge l'elenco dei file (path relativi con /) dal manifest
+// I path assoluti o che escono dalla directory vengono rifiutati: clean li rimuoverebbe
+func readManifest(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SplitManifest))
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if !insideDir(line) {
+			return nil, fmt.Errorf("%s contiene un path fuori dalla directory: %s", SplitManifest, line)
+		}
+		paths = append(paths, line)
+	}
+	return paths, nil
+}
+
+// insideDir verifica che un path del manifest sia relativo e resti nella directory divisa
+func insideDir(path string) bool {
+	local := filepath.FromSlash(path)
+	if filepath.IsAbs(local) || strings.HasPrefix(path, "/") || filepath.VolumeName(local) != "" {
+		return false
+	}
+	clean := filepath.Clean(local)
+	return clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+// sanitizeFileName rende un titolo utilizzabile come nome di file su ogni sistema
+func sanitizeFileName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			sb.WriteRune('_')
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+
+	clean := strings.Trim(sb.String(), " .")
+	if clean == "" {
+		return "_"
+	}
+	return clean
+}
+
+// uniquePath restituisce "cartella/nome.twee" aggiungendo " (2)", " (3)", ... in caso di collisione
+func uniquePath(folder, name string, used map[string]bool) string {
+	base := name
+	if folder != "" {
+		base = folder + "/" + name
+	}
+
+	path := base + ".twee"
+	for n := 2; used[strings.ToLower(path)]; n++ {
+		path = fmt.Sprintf("%s (%d).twee", base, n)
+	}
+	used[strings.ToLower(path)] = true
+	return path
+}
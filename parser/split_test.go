@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const splitSource = `:: StoryTitle
+Storia divisa
+
+:: StoryData
+{"ifid":"D674C58C-DEFA-4F70-B7A2-27742230C0FC","format":"Harlowe","format-version":"3.3.8","start":"Capitolo 1 - Inizio","zoom":0.6}
+
+:: Capitolo 1 - Inizio [stanza] {"position":"100,100"}
+Benvenuto.
+[[Capitolo 1 - Cantina]]
+
+:: Capitolo 1 - Cantina [stanza buio] {"position":"250,100","size":"200,100"}
+Umido.
+[[Fine?]]
+
+:: Fine?
+Fine.
+
+:: fine/
+Variante.
+
+:: Stile [stylesheet]
+body { color: red; }
+`
+
+// ============================================
+// Test: Divisione in un file per passaggio
+// ============================================
+
+func TestSplitJoinRoundTrip(t *testing.T) {
+	story, err := ParseString(splitSource, "story.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "split")
+	files, err := NewStorySplitter(dir).Split(story)
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+
+	expected := []string{
+		"StoryTitle.twee",
+		"StoryData.twee",
+		"Capitolo 1 - Inizio.twee",
+		"Capitolo 1 - Cantina.twee",
+		"Fine_.twee",
+		"fine_ (2).twee",
+		"Stile.twee",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Unexpected files:\n  got:      %v\n  expected: %v", files, expected)
+	}
+
+	// join(split(storia)) coincide con l'output di TweeWriter
+	joined, err := NewStoryJoiner(dir).Join()
+	if err != nil {
+		t.Fatalf("Error joining: %v", err)
+	}
+	var written bytes.Buffer
+	if err := story.WriteTwee(&written); err != nil {
+		t.Fatal(err)
+	}
+	if string(joined) != written.String() {
+		t.Errorf("Joined source differs from writer output:\n%s\n---\n%s", joined, written.String())
+	}
+
+	// Un sorgente già in forma canonica resta identico byte per byte
+	rejoined, err := NewStoryJoiner(dir).Parse()
+	if err != nil {
+		t.Fatalf("Error parsing joined story: %v", err)
+	}
+	again := filepath.Join(t.TempDir(), "again")
+	if _, err := NewStorySplitter(again).Split(rejoined); err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewStoryJoiner(again).Join()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(joined, second) {
+		t.Errorf("Split → join is not byte-stable:\n%s\n---\n%s", joined, second)
+	}
+
+	if !reflect.DeepEqual(rejoined.Order, story.Order) {
+		t.Errorf("Order not preserved: %v vs %v", rejoined.Order, story.Order)
+	}
+	cantina := rejoined.Passages["Capitolo 1 - Cantina"]
+	if cantina.Size != (Size{Width: 200, Height: 100}) || !reflect.DeepEqual(cantina.Tags, []string{"stanza", "buio"}) {
+		t.Errorf("Metadata not preserved: %+v", cantina)
+	}
+
+	t.Log("✅ Split and join are byte-stable")
+}
+
+func TestSplitFolderRules(t *testing.T) {
+	story, err := ParseString(splitSource, "story.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "by-tag")
+	splitter := NewStorySplitter(dir)
+	splitter.SetFolderRule(FolderByTag)
+	files, err := splitter.Split(story)
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+	if files[2] != "stanza/Capitolo 1 - Inizio.twee" || files[6] != "stylesheet/Stile.twee" || files[4] != "Fine_.twee" {
+		t.Errorf("Unexpected tag layout: %v", files)
+	}
+
+	// Una nuova divisione nella stessa directory rimuove i file precedenti
+	splitter.SetFolderRule(FolderByPrefix(" - "))
+	files, err = splitter.Split(story)
+	if err != nil {
+		t.Fatalf("Error re-splitting: %v", err)
+	}
+	if files[2] != "Capitolo 1/Capitolo 1 - Inizio.twee" || files[6] != "Stile.twee" {
+		t.Errorf("Unexpected prefix layout: %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stanza")); !os.IsNotExist(err) {
+		t.Error("Expected stale tag folder to be removed")
+	}
+
+	t.Log("✅ Folder rules applied")
+}
+
+func TestJoinAppendsNewFiles(t *testing.T) {
+	story, err := ParseString(splitSource, "story.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	dir := t.TempDir()
+	if _, err := NewStorySplitter(dir).Split(story); err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Epilogo.twee"), []byte(":: Epilogo\nTitoli di coda."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	joined, err := NewStoryJoiner(dir).Parse()
+	if err != nil {
+		t.Fatalf("Error joining: %v", err)
+	}
+	if last := joined.Order[len(joined.Order)-1]; last != "Epilogo" {
+		t.Errorf("Expected new file appended, got order %v", joined.Order)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "Stile.twee")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStoryJoiner(dir).Join(); err == nil || !strings.Contains(err.Error(), "Stile.twee") {
+		t.Errorf("Expected missing file error, got %v", err)
+	}
+
+	// Una directory non vuota senza manifest non viene toccata
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "note.md"), []byte("appunti"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStorySplitter(other).Split(story); err == nil {
+		t.Error("Expected error for non-empty directory without manifest")
+	}
+
+	t.Log("✅ Join appends new files and reports missing ones")
+}
+
+// ============================================
+// Test: Manifest con path fuori dalla directory
+// ============================================
+
+func TestManifestRejectsPathsOutsideDir(t *testing.T) {
+	story, err := ParseString(splitSource, "story.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	root := t.TempDir()
+	victim := filepath.Join(root, "vittima.twee")
+	if err := os.WriteFile(victim, []byte(":: Vittima\nNon toccare."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []string{"../vittima.twee", "sub/../../vittima.twee", victim, "/etc/passwd"} {
+		dir := filepath.Join(root, "split")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, SplitManifest), []byte(entry+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewStorySplitter(dir).Split(story); err == nil {
+			t.Errorf("[%s] Expected split to reject the manifest", entry)
+		}
+		if _, err := NewStoryJoiner(dir).Join(); err == nil {
+			t.Errorf("[%s] Expected join to reject the manifest", entry)
+		}
+		if _, err := os.Stat(victim); err != nil {
+			t.Fatalf("[%s] File outside the split directory was removed: %v", entry, err)
+		}
+	}
+
+	t.Log("✅ Manifest paths cannot leave the split directory")
+}
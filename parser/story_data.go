@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// StoryData rappresenta il contenuto del passaggio StoryData (specifica Twee 3)
+// I tag JSON sono quelli delle risposte dell'API, dove Story li espone da sempre (format_version);
+// le chiavi della specifica ("format-version", "tag-colors") sono in decodeStoryData e buildStoryData
+type StoryData struct {
+	IFID          string            `json:"ifid"`
+	Format        string            `json:"format"`
+	FormatVersion string            `json:"format_version"`
+	Start         string            `json:"start,omitempty"`
+	TagColors     map[string]string `json:"tag_colors,omitempty"`
+	Zoom          float64           `json:"zoom,omitempty"`
+}
+
+// ifidRegex riconosce un UUID versione 4 (variante RFC 4122)
+var ifidRegex = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-4[0-9A-Fa-f]{3}-[89ABab][0-9A-Fa-f]{3}-[0-9A-Fa-f]{12}$`)
+
+// ValidIFID verifica che l'IFID sia un UUID v4 come richiesto da Twine e Tweego
+func ValidIFID(ifid string) bool {
+	return ifidRegex.MatchString(ifid)
+}
+
+// GenerateIFID genera un nuovo IFID (UUID v4 maiuscolo, come Twine)
+func GenerateIFID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("errore generazione IFID: %w", err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // Versione 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variante RFC 4122
+
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])), nil
+}
+
+// EnsureIFID assegna un nuovo IFID alla storia se non ne ha uno
+// Restituisce l'IFID della storia (esistente o generato)
+func (s *Story) EnsureIFID() (string, error) {
+	if s.IFID != "" {
+		return s.IFID, nil
+	}
+
+	ifid, err := GenerateIFID()
+	if err != nil {
+		return "", err
+	}
+	s.IFID = ifid
+	return ifid, nil
+}
+
+// storyDataKeyError indica una chiave di StoryData con un valore del tipo sbagliato
+type storyDataKeyError struct {
+	key      string
+	expected string // Tipo atteso, es. "un numero"
+}
+
+// decodeStoryData decodifica il JSON di StoryData nella struttura tipizzata
+// Le chiavi vengono decodificate una per una: un valore del tipo sbagliato viene ignorato
+// e restituito in keyErrs, le altre chiavi restano valide.
+// err è diverso da nil solo se il JSON non è un oggetto valido.
+// Il formato viene normalizzato in minuscolo per la ricerca nel registry
+func decodeStoryData(content string) (data *StoryData, keyErrs []storyDataKeyError, err error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return nil, nil, err
+	}
+
+	data = &StoryData{}
+	fields := []struct {
+		key      string
+		target   interface{}
+		expected string
+	}{
+		{"ifid", &data.IFID, "una stringa"},
+		{"format", &data.Format, "una stringa"},
+		{"format-version", &data.FormatVersion, "una stringa"},
+		{"start", &data.Start, "una stringa"},
+		{"tag-colors", &data.TagColors, "un oggetto con colori stringa"},
+		{"zoom", &data.Zoom, "un numero"},
+	}
+
+	for _, field := range fields {
+		value, exists := raw[field.key]
+		if !exists {
+			continue
+		}
+		// Decodifica in un valore nuovo, così un errore non lascia il campo a metà
+		target := reflect.ValueOf(field.target).Elem()
+		decoded := reflect.New(target.Type())
+		if err := json.Unmarshal(value, decoded.Interface()); err != nil {
+			keyErrs = append(keyErrs, storyDataKeyError{key: field.key, expected: field.expected})
+			continue
+		}
+		target.Set(decoded.Elem())
+	}
+
+	data.Format = strings.ToLower(data.Format)
+	return data, keyErrs, nil
+}
+
+// storyDataErrorOffset restituisce l'offset nel JSON a cui si riferisce un errore di decodifica
+func storyDataErrorOffset(content string, err error) int {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return int(e.Offset)
+	case *json.UnmarshalTypeError:
+		if e.Field != "" {
+			if idx := keyOffset(content, e.Field); idx >= 0 {
+				return idx
+			}
+		}
+		return int(e.Offset)
+	}
+	return 0
+}
+
+// keyOffset restituisce l'offset della chiave di primo livello nel JSON, o -1
+func keyOffset(content string, key string) int {
+	quoted, _ := json.Marshal(key)
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	depth := 0
+	expectKey := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return -1
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			if t == '{' || t == '[' {
+				depth++
+			} else {
+				depth--
+			}
+			// Aprendo l'oggetto radice o chiudendo un valore annidato segue una chiave
+			if depth == 1 {
+				expectKey = true
+			}
+			continue
+		case string:
+			if depth == 1 && expectKey && t == key {
+				end := int(dec.InputOffset())
+				return bytes.LastIndex([]byte(content[:end]), quoted)
+			}
+		}
+
+		// A profondità 1 chiavi e valori si alternano
+		if depth == 1 {
+			expectKey = !expectKey
+		}
+	}
+}
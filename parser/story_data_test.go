@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validateSource scrive il sorgente in un file temporaneo e lo valida
+func validateSource(t *testing.T, source string) *ValidationResult {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "story.twee")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewTweeParser(path).Validate()
+}
+
+// ============================================
+// Test: StoryData e IFID
+// ============================================
+
+func TestStoryDataFullSchema(t *testing.T) {
+	story := parseSource(t, `:: StoryData
+{
+  "ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC",
+  "format": "Harlowe",
+  "format-version": "3.3.8",
+  "start": "Inizio",
+  "tag-colors": {"bar": "green"},
+  "zoom": 0.6
+}
+
+:: Inizio
+Ciao
+`)
+
+	if story.Format != "harlowe" || story.FormatVersion != "3.3.8" {
+		t.Errorf("Unexpected format: %s %s", story.Format, story.FormatVersion)
+	}
+	if story.Start != "Inizio" {
+		t.Errorf("Expected start 'Inizio', got %q", story.Start)
+	}
+	if story.TagColors["bar"] != "green" {
+		t.Errorf("Expected tag color green, got %v", story.TagColors)
+	}
+	if story.Zoom != 0.6 {
+		t.Errorf("Expected zoom 0.6, got %v", story.Zoom)
+	}
+
+	t.Log("✅ StoryData decoded with full schema")
+}
+
+func TestStoryJSONKeepsAPIKeys(t *testing.T) {
+	story := &Story{StoryData: StoryData{Format: "harlowe", FormatVersion: "3.3.8"}}
+
+	encoded, err := json.Marshal(story)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if fields["format_version"] != "3.3.8" {
+		t.Errorf("Expected format_version in Story JSON, got %s", encoded)
+	}
+	if _, exists := fields["format-version"]; exists {
+		t.Errorf("Expected no Twee 3 key in Story JSON, got %s", encoded)
+	}
+
+	t.Log("✅ Story JSON keeps the API field names")
+}
+
+func TestStoryDataValidation(t *testing.T) {
+	tests := []struct {
+		source  string
+		valid   bool
+		message string
+		line    int
+		name    string
+	}{
+		{":: StoryData\n{\"ifid\": \"not-a-uuid\"}\n\n:: A\nx\n", false, "IFID", 2, "IFID non valido"},
+		{":: StoryData\n{\"ifid\": \"D674C58C-DEFA-1F70-B7A2-27742230C0FC\"}\n\n:: A\nx\n", false, "IFID", 2, "UUID non v4"},
+		{":: StoryData\n{\"format\": \"Harlowe\"}\n\n:: A\nx\n", true, "IFID mancante", 1, "IFID mancante"},
+		{":: StoryData\n{\n  \"ifid\": \"D674C58C-DEFA-4F70-B7A2-27742230C0FC\",\n  \"start\": \"Manca\"\n}\n\n:: A\nx\n", false, "Manca", 4, "Start inesistente"},
+		{":: StoryData\n{\"ifid\": \"D674C58C-DEFA-4F70-B7A2-27742230C0FC\",\n\"zoom\": }\n\n:: A\nx\n", true, "StoryData non valido", 3, "JSON malformato"},
+	}
+
+	for _, test := range tests {
+		validation := validateSource(t, test.source)
+
+		if validation.Valid != test.valid {
+			t.Errorf("[%s] Expected valid=%v, got %v (%+v)", test.name, test.valid, validation.Valid, validation.Errors)
+			continue
+		}
+
+		issues := append(validation.Errors, validation.Warnings...)
+		found := false
+		for _, issue := range issues {
+			if strings.Contains(issue.Message, test.message) {
+				found = true
+				if issue.Line != test.line {
+					t.Errorf("[%s] Expected line %d, got %d", test.name, test.line, issue.Line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("[%s] Expected message containing %q, got %+v", test.name, test.message, issues)
+		}
+	}
+
+	t.Log("✅ StoryData validation works correctly")
+}
+
+func TestStoryDataKeepsValidKeys(t *testing.T) {
+	source := ":: StoryData\n{\n  \"ifid\": \"D674C58C-DEFA-4F70-B7A2-27742230C0FC\",\n  \"format\": \"Harlowe\",\n  \"zoom\": \"1\",\n  \"start\": \"A\"\n}\n\n:: A\nx\n"
+
+	validation := validateSource(t, source)
+	if !validation.Valid || len(validation.Warnings) != 1 {
+		t.Fatalf("Expected one warning, got %+v %+v", validation.Errors, validation.Warnings)
+	}
+	warning := validation.Warnings[0]
+	if !strings.Contains(warning.Message, "'zoom'") || warning.Line != 5 {
+		t.Errorf("Expected warning on zoom at line 5, got %+v", warning)
+	}
+
+	story := parseSource(t, source)
+	if story.IFID != "D674C58C-DEFA-4F70-B7A2-27742230C0FC" || story.Format != "harlowe" || story.Start != "A" {
+		t.Errorf("Expected valid keys to be kept, got %+v", story.StoryData)
+	}
+	if story.Zoom != 0 {
+		t.Errorf("Expected invalid zoom to be ignored, got %v", story.Zoom)
+	}
+
+	t.Log("✅ One bad StoryData key doesn't discard the others")
+}
+
+func TestGenerateIFID(t *testing.T) {
+	ifid, err := GenerateIFID()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if !ValidIFID(ifid) {
+		t.Errorf("Generated IFID %q is not a valid v4 UUID", ifid)
+	}
+	if ifid != strings.ToUpper(ifid) {
+		t.Errorf("Expected uppercase IFID, got %q", ifid)
+	}
+
+	story := &Story{}
+	generated, err := story.EnsureIFID()
+	if err != nil || story.IFID != generated || !ValidIFID(generated) {
+		t.Errorf("EnsureIFID did not assign a valid IFID: %q (%v)", story.IFID, err)
+	}
+
+	t.Log("✅ IFID generation works correctly")
+}
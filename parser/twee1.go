@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect indica la versione della sintassi Twee di un sorgente
+type Dialect string
+
+const (
+	DialectAuto  Dialect = ""      // Rileva automaticamente
+	DialectTwee3 Dialect = "twee3" // Twee 3 (Tweego, Twine 2)
+	DialectTwee1 Dialect = "twee1" // Twee 1 (twee.py, Twine 1)
+)
+
+// SetDialect forza il dialetto del sorgente invece di rilevarlo automaticamente
+func (tp *TweeParser) SetDialect(dialect Dialect) {
+	tp.dialect = dialect
+}
+
+// detectDialect rileva il dialetto dalle intestazioni
+// Blocchi <x,y>, StorySettings o StoryIncludes indicano Twee 1;
+// blocchi {metadata} o StoryData indicano Twee 3. Senza indizi restituisce DialectAuto
+func detectDialect(lines []sourceLine) Dialect {
+	twee1 := false
+
+	for _, line := range lines {
+		if !strings.HasPrefix(line.text, "::") {
+			continue
+		}
+
+		if header, err := parseHeader(line.text); err == nil {
+			if header.Metadata != "" || header.Title == "StoryData" {
+				return DialectTwee3
+			}
+			if header.Title == "StorySettings" || header.Title == "StoryIncludes" {
+				twee1 = true
+			}
+			continue
+		}
+
+		if header, err := parseHeaderTwee1(line.text); err == nil && header.Position != "" {
+			twee1 = true
+		}
+	}
+
+	if twee1 {
+		return DialectTwee1
+	}
+	return DialectAuto
+}
+
+// parseStorySettings legge le righe "chiave:valore" del passaggio StorySettings
+func parseStorySettings(content string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		settings[key] = strings.TrimSpace(value)
+	}
+	return settings
+}
+
+// parseStoryIncludes legge i file elencati nel passaggio StoryIncludes (uno per riga)
+func parseStoryIncludes(content string) []string {
+	includes := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			includes = append(includes, line)
+		}
+	}
+	return includes
+}
+
+// ============================================
+// Conversione Twee 1 → Twee 3
+// ============================================
+
+// twee1OnlyPassages sono passaggi speciali di Twine 1 senza equivalente in Twee 3
+var twee1OnlyPassages = map[string]string{
+	"StoryAuthor":   "autore mostrato da Sugarcane/Jonah",
+	"StorySubtitle": "sottotitolo mostrato da Sugarcane/Jonah",
+	"StoryMenu":     "menu di Sugarcane/Jonah",
+}
+
+// MigrationReport riepiloga la conversione di una storia Twee 1
+type MigrationReport struct {
+	Migrated []string          `json:"migrated"` // Elementi convertiti
+	Issues   []ValidationError `json:"issues"`   // Elementi non convertibili
+}
+
+// Twee1Converter converte storie Twee 1 in Twee 3
+type Twee1Converter struct {
+	format        string
+	formatVersion string
+}
+
+// NewTwee1Converter crea un convertitore verso il formato indicato (es. "harlowe", "3.3.8")
+// Twine 1 non dichiara il formato nel sorgente, quindi va scelto da chi converte
+func NewTwee1Converter(format, formatVersion string) *Twee1Converter {
+	return &Twee1Converter{format: strings.ToLower(format), formatVersion: formatVersion}
+}
+
+// Convert restituisce una copia Twee 3 della storia e il resoconto della migrazione
+// Accetta solo storie lette da sorgenti Twee 1: quelle importate da HTML o archivi non hanno
+// dialetto e non vanno convertite. La storia originale non viene modificata
+func (tc *Twee1Converter) Convert(story *Story) (*Story, *MigrationReport, error) {
+	switch story.Dialect {
+	case DialectTwee1:
+	case DialectTwee3:
+		return nil, nil, fmt.Errorf("la storia è già in sintassi Twee 3")
+	default:
+		return nil, nil, fmt.Errorf("la storia non viene da un sorgente Twee 1 e non può essere convertita")
+	}
+
+	report := &MigrationReport{
+		Migrated: []string{},
+		Issues:   []ValidationError{},
+	}
+
+	converted := &Story{
+		Title:    story.Title,
+		Passages: make(map[string]*Passage),
+		Dialect:  DialectTwee3,
+	}
+	converted.StoryData = story.StoryData
+
+	// 1. StoryData: IFID, formato e passaggio iniziale
+	if converted.IFID == "" {
+		if _, err := converted.EnsureIFID(); err != nil {
+			return nil, nil, err
+		}
+		report.Migrated = append(report.Migrated, fmt.Sprintf("IFID generato: %s", converted.IFID))
+	}
+
+	if tc.format != "" {
+		converted.Format = tc.format
+		converted.FormatVersion = tc.formatVersion
+		report.Migrated = append(report.Migrated, fmt.Sprintf("Formato impostato: %s %s", tc.format, tc.formatVersion))
+	} else {
+		report.Issues = append(report.Issues, ValidationError{
+			Type:    "warning",
+			Message: "Formato non specificato: Twine 1 non lo dichiara nel sorgente",
+		})
+	}
+
+	// In Twine 1 il passaggio iniziale è sempre "Start"
+	if converted.Start == "" {
+		if _, exists := story.Passages["Start"]; exists {
+			converted.Start = "Start"
+			report.Migrated = append(report.Migrated, "Passaggio iniziale: Start")
+		} else {
+			report.Issues = append(report.Issues, ValidationError{
+				Type:    "warning",
+				Message: "Nessun passaggio 'Start': impostare il passaggio iniziale in StoryData",
+			})
+		}
+	}
+
+	// 2. Passaggi: le posizioni <x,y> diventano metadata {"position":"x,y"}
+	positioned := 0
+	for _, passage := range story.OrderedPassages() {
+		// Contenuto riportato più sotto, impostazione per impostazione
+		if passage.Title == "StorySettings" || passage.Title == "StoryIncludes" {
+			continue
+		}
+		if reason, special := twee1OnlyPassages[passage.Title]; special {
+			report.Issues = append(report.Issues, tc.issue(passage,
+				fmt.Sprintf("Passaggio '%s' (%s) non ha equivalente in Twee 3 e non viene convertito", passage.Title, reason)))
+			continue
+		}
+
+		copied := *passage
+		copied.Tags = append([]string{}, passage.Tags...)
+		copied.Metadata = make(map[string]interface{}, len(passage.Metadata))
+		for key, value := range passage.Metadata {
+			copied.Metadata[key] = value
+		}
+		converted.AddPassage(&copied)
+
+		if passage.Position != (Position{}) {
+			positioned++
+		}
+		if passage.HasTag("Twine.image") {
+			report.Issues = append(report.Issues, tc.issue(passage,
+				fmt.Sprintf("Immagine incorporata '%s' (tag Twine.image) non supportata dai formati Twine 2", passage.Title)))
+		}
+	}
+	if positioned > 0 {
+		report.Migrated = append(report.Migrated, fmt.Sprintf("Posizioni convertite in metadata: %d passaggi", positioned))
+	}
+
+	// 3. Impostazioni e inclusioni non hanno equivalente in StoryData
+	keys := make([]string, 0, len(story.Settings))
+	for key := range story.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report.Issues = append(report.Issues, tc.issue(story.Passages["StorySettings"],
+			fmt.Sprintf("Impostazione '%s: %s' di StorySettings non migrabile", key, story.Settings[key])))
+	}
+
+	if len(story.Includes) > 0 {
+		report.Issues = append(report.Issues, tc.issue(story.Passages["StoryIncludes"],
+			fmt.Sprintf("StoryIncludes non migrabile: aggiungere al progetto i file %s", strings.Join(story.Includes, ", "))))
+	}
+
+	return converted, report, nil
+}
+
+// issue costruisce un problema di migrazione riferito a un passaggio (se presente)
+func (tc *Twee1Converter) issue(passage *Passage, message string) ValidationError {
+	issue := ValidationError{Type: "warning", Message: message}
+	if passage != nil {
+		issue.File = passage.File
+		issue.Line = passage.Header.Line
+		issue.Column = passage.Header.Column
+	}
+	return issue
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const twee1Source = `:: StoryTitle
+Vecchia Storia
+
+:: StorySettings
+undo:on
+bookmark:off
+
+:: StoryIncludes
+capitolo2.tw
+
+:: StoryAuthor
+Anonimo
+
+:: Start [inizio] <100,200>
+Benvenuto <<set $x to 1>>
+[[Avanti]]
+
+:: Avanti <350,200>
+Fine
+`
+
+// ============================================
+// Test: Dialetto Twee 1
+// ============================================
+
+func TestTwee1DialectDetected(t *testing.T) {
+	story, err := ParseString(twee1Source, "old.tw")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	if story.Dialect != DialectTwee1 {
+		t.Errorf("Expected dialect twee1, got %q", story.Dialect)
+	}
+
+	start := story.Passages["Start"]
+	if start == nil {
+		t.Fatal("Expected passage 'Start'")
+	}
+	if start.Position != (Position{X: 100, Y: 200}) {
+		t.Errorf("Unexpected position: %+v", start.Position)
+	}
+	if !reflect.DeepEqual(start.Tags, []string{"inizio"}) {
+		t.Errorf("Unexpected tags: %v", start.Tags)
+	}
+
+	expectedSettings := map[string]string{"undo": "on", "bookmark": "off"}
+	if !reflect.DeepEqual(story.Settings, expectedSettings) {
+		t.Errorf("Expected settings %v, got %v", expectedSettings, story.Settings)
+	}
+	if !reflect.DeepEqual(story.Includes, []string{"capitolo2.tw"}) {
+		t.Errorf("Unexpected includes: %v", story.Includes)
+	}
+
+	validation := ValidateString(twee1Source, "old.tw")
+	if len(validation.Warnings) != 0 {
+		t.Errorf("Expected no StoryData warning for Twee 1, got %+v", validation.Warnings)
+	}
+
+	t.Log("✅ Twee 1 source detected and parsed")
+}
+
+func TestTwee1DialectExplicit(t *testing.T) {
+	// Senza blocchi posizione il sorgente sembra Twee 3: in Twee 1 il backslash è letterale
+	source := ":: Cartella C:\\giochi\nx\n"
+
+	story, err := ParseString(source, "test.twee")
+	if err != nil {
+		t.Fatalf("Error parsing as Twee 3: %v", err)
+	}
+	if _, exists := story.Passages["Cartella C:giochi"]; !exists {
+		t.Errorf("Expected unescaped Twee 3 title, got %v", story.Order)
+	}
+
+	tp := NewTweeParser("test.tw")
+	tp.SetDialect(DialectTwee1)
+	story, err = tp.parseData([]byte(source), newValidationResult())
+	if err != nil {
+		t.Fatalf("Error parsing as Twee 1: %v", err)
+	}
+	if _, exists := story.Passages[`Cartella C:\giochi`]; !exists {
+		t.Errorf("Expected literal Twee 1 title, got %v", story.Order)
+	}
+	if story.Dialect != DialectTwee1 {
+		t.Errorf("Expected dialect twee1, got %q", story.Dialect)
+	}
+
+	t.Log("✅ Twee 1 dialect can be selected explicitly")
+}
+
+func TestConvertTwee1(t *testing.T) {
+	story, err := ParseString(twee1Source, "old.tw")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	converted, report, err := NewTwee1Converter("Harlowe", "3.3.8").Convert(story)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+
+	if !ValidIFID(converted.IFID) || converted.Format != "harlowe" || converted.Start != "Start" {
+		t.Errorf("Unexpected StoryData: %+v", converted.StoryData)
+	}
+	for _, title := range []string{"StorySettings", "StoryIncludes", "StoryAuthor"} {
+		if _, exists := converted.Passages[title]; exists {
+			t.Errorf("Expected %s to be dropped", title)
+		}
+	}
+
+	messages := []string{}
+	for _, issue := range report.Issues {
+		messages = append(messages, issue.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, expected := range []string{"undo: on", "bookmark: off", "capitolo2.tw", "StoryAuthor"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Expected report to mention %q, got:\n%s", expected, joined)
+		}
+	}
+
+	// Il risultato è un sorgente Twee 3 valido con le posizioni nei metadata
+	var buf bytes.Buffer
+	if err := converted.WriteTwee(&buf); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+	if !strings.Contains(buf.String(), `:: Start [inizio] {"position":"100,200"}`) {
+		t.Errorf("Expected position metadata in output:\n%s", buf.String())
+	}
+
+	reparsed, err := ParseString(buf.String(), "new.twee")
+	if err != nil {
+		t.Fatalf("Converted source does not parse: %v", err)
+	}
+	if reparsed.Dialect != DialectTwee3 || reparsed.IFID != converted.IFID {
+		t.Errorf("Unexpected reparsed story: %q %q", reparsed.Dialect, reparsed.IFID)
+	}
+
+	if _, _, err := NewTwee1Converter("harlowe", "").Convert(reparsed); err == nil {
+		t.Error("Expected error converting a Twee 3 story")
+	}
+	if _, _, err := NewTwee1Converter("harlowe", "").Convert(&Story{Title: "Importata"}); err == nil {
+		t.Error("Expected error converting a story without a Twee 1 dialect")
+	}
+
+	t.Log("✅ Twee 1 story converted to Twee 3")
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// passageHeader rappresenta un'intestazione ":: Titolo [tag] {metadata}" tokenizzata
+type passageHeader struct {
+	Title          string   // Titolo senza escape
+	Tags           []string // Tag senza escape
+	Metadata       string   // Blocco metadata JSON grezzo, vuoto se assente
+	MetadataColumn int      // Colonna (da 1) di inizio del blocco metadata
+	Position       string   // Twee 1: contenuto del blocco <x,y>, vuoto se assente
+	PositionColumn int      // Twee 1: colonna (da 1) di inizio del blocco posizione
+}
+
+// parseHeader tokenizza una riga di intestazione Twee 3
+// Nel titolo e nei tag i caratteri \[ \] \{ \} \\ sono escape, come in Tweego
+func parseHeader(line string) (*passageHeader, error) {
+	if !strings.HasPrefix(line, "::") {
+		return nil, fmt.Errorf("intestazione senza prefisso '::'")
+	}
+
+	header := &passageHeader{Tags: []string{}}
+	pos := 2
+
+	// 1. Titolo: fino al primo [ o { non preceduto da escape
+	end := scanUntil(line, pos, "[{")
+	header.Title = UnescapeTwee(strings.TrimSpace(line[pos:end]))
+	if header.Title == "" {
+		return nil, fmt.Errorf("passaggio senza titolo")
+	}
+	pos = end
+
+	// 2. Tag: [tag1 tag2]
+	if pos < len(line) && line[pos] == '[' {
+		end = scanUntil(line, pos+1, "]")
+		if end >= len(line) {
+			return nil, fmt.Errorf("blocco tag non chiuso (manca ']')")
+		}
+
+		for _, tag := range splitTags(line[pos+1 : end]) {
+			header.Tags = append(header.Tags, UnescapeTwee(tag))
+		}
+		pos = skipSpaces(line, end+1)
+	}
+
+	// 3. Metadata: {...} fino a fine riga
+	if pos < len(line) && line[pos] == '{' {
+		header.Metadata = strings.TrimRightFunc(line[pos:], unicode.IsSpace)
+		header.MetadataColumn = pos + 1
+		return header, nil
+	}
+
+	if pos < len(line) {
+		return nil, fmt.Errorf("contenuto inatteso dopo l'intestazione: %q", line[pos:])
+	}
+
+	return header, nil
+}
+
+// twee1HeaderRegex riproduce l'intestazione di twee.py: ":: Titolo [tag] <x,y>"
+var twee1HeaderRegex = regexp.MustCompile(`^::\s*([^\[]*?)(\s*\[(.*?)\])?(\s*<(.*?)>)?\s*$`)
+
+// parseHeaderTwee1 tokenizza una riga di intestazione Twee 1
+// Twee 1 non prevede escape: titolo e tag sono letterali e il titolo non può contenere '['
+func parseHeaderTwee1(line string) (*passageHeader, error) {
+	m := twee1HeaderRegex.FindStringSubmatchIndex(line)
+	if m == nil {
+		return nil, fmt.Errorf("intestazione senza prefisso '::'")
+	}
+
+	header := &passageHeader{
+		Title: strings.TrimSpace(line[m[2]:m[3]]),
+		Tags:  []string{},
+	}
+	if header.Title == "" {
+		return nil, fmt.Errorf("passaggio senza titolo")
+	}
+	if m[6] != -1 {
+		header.Tags = strings.Fields(line[m[6]:m[7]])
+	}
+	if m[10] != -1 {
+		header.Position = line[m[10]:m[11]]
+		header.PositionColumn = m[10]
+	}
+
+	return header, nil
+}
+
+// scanUntil restituisce l'indice del primo carattere di stop non preceduto da escape
+// Se non lo trova restituisce len(s)
+func scanUntil(s string, start int, stop string) int {
+	for i := start; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++ // Salta il carattere escapato
+			continue
+		}
+		if strings.IndexByte(stop, s[i]) != -1 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// skipSpaces salta spazi e tab a partire da start
+func skipSpaces(s string, start int) int {
+	for start < len(s) && (s[start] == ' ' || s[start] == '\t') {
+		start++
+	}
+	return start
+}
+
+// splitTags divide il blocco tag sugli spazi non escapati
+func splitTags(block string) []string {
+	tags := []string{}
+	current := strings.Builder{}
+
+	for i := 0; i < len(block); i++ {
+		char := block[i]
+
+		if char == '\\' && i+1 < len(block) {
+			current.WriteByte(char)
+			current.WriteByte(block[i+1])
+			i++
+			continue
+		}
+
+		if char == ' ' || char == '\t' {
+			if current.Len() > 0 {
+				tags = append(tags, current.String())
+				current.Reset()
+			}
+			continue
+		}
+
+		current.WriteByte(char)
+	}
+
+	if current.Len() > 0 {
+		tags = append(tags, current.String())
+	}
+
+	return tags
+}
+
+// EscapeTwee applica l'escape Twee 3 a titoli e tag: \ [ ] { } diventano \\ \[ \] \{ \}
+func EscapeTwee(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\', '[', ']', '{', '}':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// UnescapeTwee rimuove l'escape Twee 3: ogni \x diventa x
+func UnescapeTwee(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ============================================
+// Test: Tokenizer intestazioni Twee 3
+// ============================================
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		line     string
+		title    string
+		tags     []string
+		metadata string
+		name     string
+	}{
+		{`:: Inizio`, "Inizio", []string{}, "", "Solo titolo"},
+		{`:: Inizio [a b]`, "Inizio", []string{"a", "b"}, "", "Titolo e tag"},
+		{`:: Inizio [a] {"position":"1,2"}`, "Inizio", []string{"a"}, `{"position":"1,2"}`, "Titolo, tag e metadata"},
+		{`:: Inizio {"position":"1,2"}`, "Inizio", []string{}, `{"position":"1,2"}`, "Titolo e metadata"},
+		{`:: Room \[A\]`, "Room [A]", []string{}, "", "Parentesi quadre escapate"},
+		{`:: Room \[A\] [tag]`, "Room [A]", []string{"tag"}, "", "Escape seguito da tag"},
+		{`:: Set \{x\} \\ y`, `Set {x} \ y`, []string{}, "", "Graffe e backslash escapati"},
+		{`:: A [x\]y z]`, "A", []string{"x]y", "z"}, "", "Escape nei tag"},
+	}
+
+	for _, test := range tests {
+		header, err := parseHeader(test.line)
+		if err != nil {
+			t.Errorf("[%s] Error: %v", test.name, err)
+			continue
+		}
+
+		if header.Title != test.title {
+			t.Errorf("[%s] Expected title %q, got %q", test.name, test.title, header.Title)
+		}
+		if !reflect.DeepEqual(header.Tags, test.tags) {
+			t.Errorf("[%s] Expected tags %v, got %v", test.name, test.tags, header.Tags)
+		}
+		if header.Metadata != test.metadata {
+			t.Errorf("[%s] Expected metadata %q, got %q", test.name, test.metadata, header.Metadata)
+		}
+	}
+
+	t.Log("✅ Header tokenizer works correctly")
+}
+
+func TestParseHeaderErrors(t *testing.T) {
+	invalid := []string{
+		`::`,
+		`:: [tag]`,
+		`:: A [tag`,
+		`:: A [tag] extra`,
+	}
+
+	for _, line := range invalid {
+		if _, err := parseHeader(line); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+
+	t.Log("✅ Malformed headers are rejected")
+}
+
+func TestEscapeRoundTrip(t *testing.T) {
+	names := []string{"Room [A]", `Set {x} \ y`, "Plain", "a]b[c"}
+
+	for _, name := range names {
+		header, err := parseHeader(":: " + EscapeTwee(name))
+		if err != nil {
+			t.Errorf("[%s] Error: %v", name, err)
+			continue
+		}
+		if header.Title != name {
+			t.Errorf("Expected %q after round-trip, got %q", name, header.Title)
+		}
+	}
+
+	t.Log("✅ Escape/unescape round-trip works correctly")
+}
@@ -1,24 +1,66 @@
 package parser
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // TweeParser gestisce il parsing dei file .twee
 type TweeParser struct {
 	filepath string
+	dialect  Dialect // DialectAuto rileva la sintassi dal sorgente
 }
 
 // ValidationError rappresenta un errore di validazione
 type ValidationError struct {
 	Type    string `json:"type"`    // "error" o "warning"
 	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
 	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// sourceLine rappresenta una riga del sorgente con la sua posizione
+type sourceLine struct {
+	text   string // Testo della riga senza terminatore
+	num    int    // Numero di riga (da 1)
+	offset int    // Byte offset dell'inizio riga
+}
+
+// splitLines divide il sorgente in righe mantenendo numeri di riga e offset
+// Il terminatore (\n o \r\n) non fa parte del testo della riga
+func splitLines(data []byte) ([]sourceLine, bool) {
+	lines := []sourceLine{}
+	crlf := false
+	start := 0
+
+	for start < len(data) {
+		end := start
+		for end < len(data) && data[end] != '\n' {
+			end++
+		}
+
+		text := string(data[start:end])
+		if strings.HasSuffix(text, "\r") {
+			text = text[:len(text)-1]
+			crlf = true
+		}
+
+		lines = append(lines, sourceLine{
+			text:   text,
+			num:    len(lines) + 1,
+			offset: start,
+		})
+
+		start = end + 1
+	}
+
+	return lines, crlf
 }
 
 // ValidationResult risultato della validazione
@@ -28,6 +70,15 @@ type ValidationResult struct {
 	Warnings []ValidationError `json:"warnings,omitempty"`
 }
 
+// newValidationResult crea un risultato valido senza errori né warning
+func newValidationResult() *ValidationResult {
+	return &ValidationResult{
+		Valid:    true,
+		Errors:   []ValidationError{},
+		Warnings: []ValidationError{},
+	}
+}
+
 // NewTweeParser crea un nuovo parser
 func NewTweeParser(filepath string) *TweeParser {
 	return &TweeParser{filepath: filepath}
@@ -35,237 +86,554 @@ func NewTweeParser(filepath string) *TweeParser {
 
 // Validate valida il file .twee prima del parsing
 func (tp *TweeParser) Validate() *ValidationResult {
-	result := &ValidationResult{
-		Valid:    true,
-		Errors:   []ValidationError{},
-		Warnings: []ValidationError{},
+	result := newValidationResult()
+
+	data, ok := tp.readFile(result)
+	if !ok {
+		return result
 	}
 
+	scan := tp.scanSource(data, result, nil)
+	checkStory(scan, result)
+
+	return result
+}
+
+// readFile legge il file registrando gli errori di accesso nel risultato
+func (tp *TweeParser) readFile(result *ValidationResult) ([]byte, bool) {
 	// 1. Verifica che il file esista
 	if _, err := os.Stat(tp.filepath); os.IsNotExist(err) {
 		result.Valid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Type:    "error",
 			Message: fmt.Sprintf("File non trovato: %s", tp.filepath),
+			File:    tp.filepath,
 		})
-		return result
+		return nil, false
 	}
 
 	// 2. Verifica che il file sia leggibile
-	file, err := os.Open(tp.filepath)
+	data, err := os.ReadFile(tp.filepath)
 	if err != nil {
 		result.Valid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Type:    "error",
 			Message: fmt.Sprintf("Impossibile leggere il file: %v", err),
+			File:    tp.filepath,
 		})
-		return result
+		return nil, false
+	}
+
+	return data, true
+}
+
+// sourceScan raccoglie le informazioni strutturali trovate durante la validazione
+// Serve a ripetere i controlli a livello di storia su più file (vedi ProjectLoader)
+type sourceScan struct {
+	file         string
+	passages     map[string]SourcePos // Titolo → intestazione della prima definizione
+	files        map[string]string    // Titolo → file della prima definizione
+	kinds        map[string]PassageKind
+	hasStoryData bool
+	dialect      Dialect
+	dialectFile  string // File che ha stabilito il dialetto (vedi ProjectLoader.mergeScan)
+	explicit     bool   // Dialetto forzato o rilevato da indizi, non il default Twee 3
+	start        string
+	startFile    string
+	startPos     SourcePos
+}
+
+// scanSource verifica sintassi delle intestazioni, metadata e duplicati nel file
+// Se story non è nil, nello stesso passaggio costruisce anche i passaggi della storia
+func (tp *TweeParser) scanSource(data []byte, result *ValidationResult, story *Story) *sourceScan {
+	scan := &sourceScan{
+		file:     tp.filepath,
+		passages: map[string]SourcePos{},
+		files:    map[string]string{},
+		kinds:    map[string]PassageKind{},
+	}
+
+	lines, crlf := splitLines(data)
+	var currentPassage *Passage
+
+	scan.dialect = tp.dialect
+	if scan.dialect == DialectAuto {
+		scan.dialect = detectDialect(lines)
+	}
+	scan.explicit = scan.dialect != DialectAuto
+	if !scan.explicit {
+		scan.dialect = DialectTwee3
+	}
+
+	// In un progetto il dialetto della storia è già stabilito da un file precedente
+	if story != nil && story.Dialect == DialectAuto {
+		story.Dialect = scan.dialect
+	}
+
+	// Twee 1 non ha escape nelle intestazioni e usa blocchi posizione <x,y>
+	headerParser := parseHeader
+	if scan.dialect == DialectTwee1 {
+		headerParser = parseHeaderTwee1
 	}
-	defer file.Close()
-
-	// 3. Verifica sintassi e contenuto base
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	hasPassages := false
-	hasStoryData := false
-	startPassage := ""
-	foundPassages := map[string]bool{}
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		// Rileva passaggi
-		if strings.HasPrefix(line, "::") {
-			hasPassages = true
-
-			// Estrai titolo passaggio
-			passageHeaderRegex := regexp.MustCompile(`^::\s*(.+?)(?:\s+\[([^\]]*)\])?(?:\s+\{.*\})?$`)
-			matches := passageHeaderRegex.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				title := strings.TrimSpace(matches[1])
-				foundPassages[title] = true
-
-				// Rileva StoryData
-				if title == "StoryData" {
-					hasStoryData = true
+	var bodyLines []sourceLine
+
+	// finishPassage chiude il passaggio corrente e lo aggiunge alla storia
+	finishPassage := func(nextOffset int) {
+		if currentPassage == nil {
+			return
+		}
+
+		// StoryData: verifica JSON, IFID e passaggio iniziale
+		if currentPassage.Title == "StoryData" {
+			tp.checkStoryData(currentPassage.Header, bodyLines, scan, result)
+		}
+
+		if story != nil {
+			tp.setBody(currentPassage, bodyLines, nextOffset)
+			tp.applySpecialPassage(story, currentPassage)
+			story.AddPassage(currentPassage)
+		}
+
+		currentPassage = nil
+		bodyLines = nil
+	}
+
+	for _, line := range lines {
+		// Nuova intestazione passaggio
+		if strings.HasPrefix(line.text, "::") {
+			// Salva il passaggio precedente se esiste
+			finishPassage(line.offset)
+
+			// Tokenizza l'intestazione: :: Title [tags] {"position":"x,y"}
+			header, err := headerParser(line.text)
+			if err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, ValidationError{
+					Type:    "error",
+					Message: fmt.Sprintf("Intestazione passaggio non valida: %v", err),
+					File:    tp.filepath,
+					Line:    line.num,
+					Column:  1,
+				})
+				continue
+			}
+
+			title := header.Title
+			currentPassage = &Passage{
+				Title:    title,
+				Tags:     header.Tags,
+				Metadata: make(map[string]interface{}),
+				File:     tp.filepath,
+				Header: SourcePos{
+					Line:   line.num,
+					Column: 1,
+					Offset: line.offset,
+				},
+				crlf: crlf,
+			}
+
+			// Titoli duplicati: Tweego li considera un errore
+			if first, exists := scan.passages[title]; exists {
+				result.Valid = false
+				result.Errors = append(result.Errors, ValidationError{
+					Type:    "error",
+					Message: fmt.Sprintf("Passaggio '%s' definito due volte (righe %d e %d)", title, first.Line, line.num),
+					File:    tp.filepath,
+					Line:    line.num,
+					Column:  1,
+				})
+			} else {
+				scan.passages[title] = currentPassage.Header
+				scan.files[title] = tp.filepath
+				scan.kinds[title] = currentPassage.Kind()
+			}
+
+			// Estrai e verifica il blocco metadata {"position":"x,y","size":"w,h"}
+			if header.Metadata != "" {
+				if err := decodeMetadata(header.Metadata, currentPassage); err != nil {
+					result.Valid = false
+					result.Errors = append(result.Errors, ValidationError{
+						Type:    "error",
+						Message: fmt.Sprintf("Metadata non validi nel passaggio '%s': %v", title, err),
+						File:    tp.filepath,
+						Line:    line.num,
+						Column:  header.MetadataColumn,
+					})
 				}
 			}
+
+			// Twee 1: blocco posizione <x,y>
+			if header.Position != "" {
+				x, y, err := parsePair(header.Position)
+				if err != nil {
+					result.Valid = false
+					result.Errors = append(result.Errors, ValidationError{
+						Type:    "error",
+						Message: fmt.Sprintf("Posizione non valida nel passaggio '%s': %v", title, err),
+						File:    tp.filepath,
+						Line:    line.num,
+						Column:  header.PositionColumn,
+					})
+				}
+				currentPassage.Position = Position{X: x, Y: y}
+			}
+
+			// Rileva StoryData
+			if title == "StoryData" {
+				scan.hasStoryData = true
+			}
+		} else if currentPassage != nil {
+			// Aggiungi al contenuto del passaggio corrente
+			// Le righe "\::", "\\::", ... sono righe di contenuto escapate dal writer:
+			// si toglie esattamente un "\"
+			if strings.HasPrefix(line.text, "\\") && isEscapedHeader(line.text) {
+				line.text = line.text[1:]
+			}
+			bodyLines = append(bodyLines, line)
 		}
+	}
+
+	// Salva l'ultimo passaggio
+	finishPassage(len(data))
+
+	return scan
+}
+
+// checkStoryData decodifica StoryData e ne verifica JSON, IFID e passaggio iniziale
+func (tp *TweeParser) checkStoryData(header SourcePos, body []sourceLine, scan *sourceScan, result *ValidationResult) {
+	texts := make([]string, len(body))
+	for i, line := range body {
+		texts[i] = line.text
+	}
+	content := strings.Join(texts, "\n")
 
-		// Estrai start passage da StoryData
-		if hasStoryData && strings.Contains(line, `"start"`) {
-			startRegex := regexp.MustCompile(`"start"\s*:\s*"([^"]+)"`)
-			matches := startRegex.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				startPassage = matches[1]
+	// locate converte un offset nel JSON in una posizione nel file
+	locate := func(offset int) SourcePos {
+		if offset < 0 {
+			return header
+		}
+		for _, line := range body {
+			if offset <= len(line.text) {
+				return SourcePos{Line: line.num, Column: offset + 1, Offset: line.offset + offset}
 			}
+			offset -= len(line.text) + 1
 		}
+		return header
+	}
+
+	if strings.TrimSpace(content) == "" {
+		result.Warnings = append(result.Warnings, ValidationError{
+			Type:    "warning",
+			Message: "StoryData è vuoto",
+			File:    tp.filepath,
+			Line:    header.Line,
+			Column:  header.Column,
+		})
+		return
+	}
+
+	data, keyErrs, err := decodeStoryData(content)
+	if err != nil {
+		pos := locate(storyDataErrorOffset(content, err))
+		result.Warnings = append(result.Warnings, ValidationError{
+			Type:    "warning",
+			Message: fmt.Sprintf("StoryData non valido, verrà ignorato: %v", err),
+			File:    tp.filepath,
+			Line:    pos.Line,
+			Column:  pos.Column,
+		})
+		return
 	}
 
-	if err := scanner.Err(); err != nil {
+	// Una chiave con il tipo sbagliato viene ignorata, le altre restano valide
+	for _, keyErr := range keyErrs {
+		pos := locate(keyOffset(content, keyErr.key))
+		result.Warnings = append(result.Warnings, ValidationError{
+			Type:    "warning",
+			Message: fmt.Sprintf("Il valore di '%s' in StoryData deve essere %s, verrà ignorato", keyErr.key, keyErr.expected),
+			File:    tp.filepath,
+			Line:    pos.Line,
+			Column:  pos.Column,
+		})
+	}
+
+	// L'IFID è obbligatorio per Tweego e deve essere un UUID v4
+	if data.IFID == "" {
+		result.Warnings = append(result.Warnings, ValidationError{
+			Type:    "warning",
+			Message: "IFID mancante in StoryData (generarne uno con GenerateIFID)",
+			File:    tp.filepath,
+			Line:    header.Line,
+			Column:  header.Column,
+		})
+	} else if !ValidIFID(data.IFID) {
+		pos := locate(keyOffset(content, "ifid"))
 		result.Valid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Type:    "error",
-			Message: fmt.Sprintf("Errore lettura file: %v", err),
+			Message: fmt.Sprintf("IFID '%s' non valido: deve essere un UUID versione 4", data.IFID),
+			File:    tp.filepath,
+			Line:    pos.Line,
+			Column:  pos.Column,
 		})
-		return result
 	}
 
+	if data.Start != "" {
+		scan.start = data.Start
+		scan.startFile = tp.filepath
+		scan.startPos = locate(keyOffset(content, "start"))
+	}
+}
+
+// checkStory esegue i controlli che riguardano la storia nel suo complesso
+func checkStory(scan *sourceScan, result *ValidationResult) {
 	// 4. Verifica che ci siano passaggi
-	if !hasPassages {
+	if len(scan.passages) == 0 {
 		result.Valid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Type:    "error",
 			Message: "Nessun passaggio trovato nel file .twee",
+			File:    scan.file,
+			Line:    1,
+			Column:  1,
 		})
 	}
 
 	// 5. Verifica che il passaggio Start esista (se definito)
-	if startPassage != "" && !foundPassages[startPassage] {
+	if _, exists := scan.passages[scan.start]; scan.start != "" && !exists {
 		result.Valid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Type:    "error",
-			Message: fmt.Sprintf("Passaggio iniziale '%s' definito in StoryData ma non trovato", startPassage),
+			Message: fmt.Sprintf("Passaggio iniziale '%s' definito in StoryData ma non trovato", scan.start),
+			File:    scan.startFile,
+			Line:    scan.startPos.Line,
+			Column:  scan.startPos.Column,
+		})
+	} else if kind := scan.kinds[scan.start]; exists && !kind.IsNavigable() {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Type:    "error",
+			Message: fmt.Sprintf("Passaggio iniziale '%s' è un passaggio speciale (%s) e non può essere visitato", scan.start, kind),
+			File:    scan.startFile,
+			Line:    scan.startPos.Line,
+			Column:  scan.startPos.Column,
 		})
 	}
 
-	// 6. Warning se non c'è StoryData
-	if !hasStoryData {
+	// 6. Warning se non c'è StoryData (Twee 1 non lo prevede)
+	if !scan.hasStoryData && scan.dialect != DialectTwee1 {
 		result.Warnings = append(result.Warnings, ValidationError{
 			Type:    "warning",
 			Message: "Nessun passaggio StoryData trovato (opzionale ma raccomandato)",
+			File:    scan.file,
+			Line:    1,
+			Column:  1,
 		})
 	}
-
-	return result
 }
 
 // Parse legge e parsa il file .twee
+// Validazione e parsing avvengono in un'unica lettura del file
 func (tp *TweeParser) Parse() (*Story, error) {
-	// Valida prima di parsare
-	validation := tp.Validate()
-	if !validation.Valid {
-		errMsg := "Validazione fallita:\n"
-		for _, err := range validation.Errors {
-			errMsg += fmt.Sprintf("  - %s\n", err.Message)
-		}
-		return nil, fmt.Errorf(errMsg)
+	result := newValidationResult()
+
+	data, ok := tp.readFile(result)
+	if !ok {
+		return nil, validationError(result)
 	}
 
-	file, err := os.Open(tp.filepath)
+	return tp.parseData(data, result)
+}
+
+// ParseReader valida e parsa un sorgente Twee da un io.Reader
+// name viene usato come nome file nelle posizioni e negli errori
+func ParseReader(r io.Reader, name string) (*Story, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("errore apertura file: %w", err)
+		return nil, fmt.Errorf("errore lettura sorgente: %w", err)
 	}
-	defer file.Close()
 
+	return NewTweeParser(name).parseData(data, newValidationResult())
+}
+
+// ParseString valida e parsa un sorgente Twee in memoria (es. un buffer non salvato)
+func ParseString(content string, name string) (*Story, error) {
+	return ParseReader(strings.NewReader(content), name)
+}
+
+// ValidateString valida un sorgente Twee in memoria
+func ValidateString(content string, name string) *ValidationResult {
+	result := newValidationResult()
+	tp := NewTweeParser(name)
+	scan := tp.scanSource([]byte(content), result, nil)
+	checkStory(scan, result)
+	return result
+}
+
+// parseData valida e parsa il sorgente in un solo passaggio
+func (tp *TweeParser) parseData(data []byte, result *ValidationResult) (*Story, error) {
 	story := &Story{
 		Passages: make(map[string]*Passage),
 	}
 
-	scanner := bufio.NewScanner(file)
-	var currentPassage *Passage
-	var contentBuilder strings.Builder
+	scan := tp.scanSource(data, result, story)
+	checkStory(scan, result)
 
-	// Regex per il formato :: Title [tags] {"position":"x,y"}
-	passageHeaderRegex := regexp.MustCompile(`^::\s*(.+?)(?:\s+\[([^\]]*)\])?(?:\s+\{.*\})?$`)
+	if !result.Valid {
+		return nil, validationError(result)
+	}
+	return story, nil
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+// validationError costruisce l'errore restituito da Parse quando la validazione fallisce
+func validationError(validation *ValidationResult) error {
+	errMsg := "Validazione fallita:\n"
+	for _, err := range validation.Errors {
+		if err.Line > 0 {
+			errMsg += fmt.Sprintf("  - %s:%d:%d: %s\n", err.File, err.Line, err.Column, err.Message)
+		} else {
+			errMsg += fmt.Sprintf("  - %s\n", err.Message)
+		}
+	}
+	return fmt.Errorf("%s", errMsg)
+}
 
-		// Nuova intestazione passaggio
-		if strings.HasPrefix(line, "::") {
-			// Salva il passaggio precedente se esiste
-			if currentPassage != nil {
-				currentPassage.Content = strings.TrimSpace(contentBuilder.String())
-				
-				// Se è StoryData, estrai metadata
-				if currentPassage.Title == "StoryData" {
-					tp.extractStoryData(story, currentPassage.Content)
-				}
-				
-				story.Passages[currentPassage.Title] = currentPassage
-				contentBuilder.Reset()
-			}
+// setBody imposta Content e posizioni del corpo a partire dalle righe raccolte
+// Content è il corpo senza spazi iniziali e finali, come nelle versioni precedenti
+func (tp *TweeParser) setBody(passage *Passage, bodyLines []sourceLine, nextOffset int) {
+	texts := make([]string, len(bodyLines))
+	for i, line := range bodyLines {
+		texts[i] = line.text
+	}
+	body := strings.Join(texts, "\n")
+	passage.Content = strings.TrimSpace(body)
+
+	// Corpo vuoto: inizio e fine coincidono con la riga successiva all'intestazione
+	if passage.Content == "" {
+		pos := SourcePos{Line: passage.Header.Line + 1, Column: 1, Offset: nextOffset}
+		if len(bodyLines) > 0 {
+			pos.Offset = bodyLines[0].offset
+		}
+		passage.BodyStart = pos
+		passage.BodyEnd = pos
+		return
+	}
 
-			// Parsa la nuova intestazione
-			matches := passageHeaderRegex.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				currentPassage = &Passage{
-					Title:    strings.TrimSpace(matches[1]),
-					Tags:     []string{},
-					Metadata: make(map[string]string),
-				}
+	start := len(body) - len(strings.TrimLeftFunc(body, unicode.IsSpace))
+	end := len(strings.TrimRightFunc(body, unicode.IsSpace))
 
-				// Estrai i tag se presenti
-				if len(matches) > 2 && matches[2] != "" {
-					tags := strings.Split(matches[2], " ")
-					for _, tag := range tags {
-						tag = strings.TrimSpace(tag)
-						if tag != "" {
-							currentPassage.Tags = append(currentPassage.Tags, tag)
-						}
-					}
-				}
+	passage.BodyStart = locateInBody(bodyLines, body, start)
+	passage.BodyEnd = locateInBody(bodyLines, body, end)
+}
+
+// locateInBody converte un indice nel corpo unito in una posizione nel file
+func locateInBody(bodyLines []sourceLine, body string, index int) SourcePos {
+	lineIdx := strings.Count(body[:index], "\n")
+	column := index - (strings.LastIndex(body[:index], "\n") + 1)
+	line := bodyLines[lineIdx]
+
+	return SourcePos{
+		Line:   line.num,
+		Column: column + 1,
+		Offset: line.offset + column,
+	}
+}
+
+// decodeMetadata decodifica il blocco metadata Twee 3 di un'intestazione
+// "position" e "size" finiscono nei campi dedicati, le altre chiavi in Metadata
+func decodeMetadata(raw string, passage *Passage) error {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return fmt.Errorf("JSON non valido: %v", err)
+	}
+
+	if passage.Metadata == nil {
+		passage.Metadata = make(map[string]interface{})
+	}
+
+	for key, value := range metadata {
+		switch key {
+		case "position":
+			x, y, err := parsePair(value)
+			if err != nil {
+				return fmt.Errorf("position: %v", err)
 			}
-		} else if currentPassage != nil {
-			// Aggiungi al contenuto del passaggio corrente
-			contentBuilder.WriteString(line)
-			contentBuilder.WriteString("\n")
+			passage.Position = Position{X: x, Y: y}
+		case "size":
+			w, h, err := parsePair(value)
+			if err != nil {
+				return fmt.Errorf("size: %v", err)
+			}
+			passage.Size = Size{Width: w, Height: h}
+		default:
+			passage.Metadata[key] = value
 		}
 	}
 
-	// Salva l'ultimo passaggio
-	if currentPassage != nil {
-		currentPassage.Content = strings.TrimSpace(contentBuilder.String())
-		
-		// Se è StoryData, estrai metadata
-		if currentPassage.Title == "StoryData" {
-			tp.extractStoryData(story, currentPassage.Content)
-		}
-		
-		story.Passages[currentPassage.Title] = currentPassage
+	return nil
+}
+
+// parsePair parsa una coppia "a,b" di numeri come usata da position e size
+func parsePair(value interface{}) (float64, float64, error) {
+	str, ok := value.(string)
+	if !ok {
+		return 0, 0, fmt.Errorf("atteso \"a,b\", trovato %v", value)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("errore lettura file: %w", err)
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("atteso \"a,b\", trovato %q", str)
 	}
 
-	return story, nil
+	a, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("valore non numerico %q", parts[0])
+	}
+	b, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("valore non numerico %q", parts[1])
+	}
+
+	return a, b, nil
+}
+
+// applySpecialPassage copia nella storia i dati dei passaggi speciali (StoryData, StoryTitle, ...)
+func (tp *TweeParser) applySpecialPassage(story *Story, passage *Passage) {
+	switch passage.Kind() {
+	case KindStoryData:
+		tp.extractStoryData(story, passage.Content)
+	case KindStoryTitle:
+		story.Title = passage.Content
+	}
+
+	if story.Dialect == DialectTwee1 {
+		switch passage.Title {
+		case "StorySettings":
+			story.Settings = parseStorySettings(passage.Content)
+		case "StoryIncludes":
+			story.Includes = parseStoryIncludes(passage.Content)
+		}
+	}
 }
 
-// extractStoryData estrae formato, versione, IFID e titolo da StoryData
+// extractStoryData decodifica StoryData nella storia
+// Il JSON non valido e le chiavi del tipo sbagliato sono già segnalati da Validate e vengono ignorati
 func (tp *TweeParser) extractStoryData(story *Story, content string) {
-	// Parsa il JSON contenuto in StoryData
-	var storyData map[string]interface{}
-	
-	if err := json.Unmarshal([]byte(content), &storyData); err != nil {
-		// Se non è JSON valido, ignora silenziosamente
+	data, _, err := decodeStoryData(content)
+	if err != nil {
 		return
 	}
-	
-	// Estrai formato (può essere "Harlowe", "harlowe-3", etc.)
-	if format, ok := storyData["format"].(string); ok {
-		story.Format = strings.ToLower(format)
-		
-		// Normalizza il formato per Tweego
-		// "Harlowe" -> "harlowe"
-		// "SugarCube" -> "sugarcube"
-		story.Format = strings.ToLower(story.Format)
-	}
-	
-	// Estrai versione del formato
-	if formatVersion, ok := storyData["format-version"].(string); ok {
-		story.FormatVersion = formatVersion
-	}
-	
-	// Estrai IFID
-	if ifid, ok := storyData["ifid"].(string); ok {
-		story.IFID = ifid
-	}
-	
-	// Estrai titolo (se presente in StoryData)
-	if title, ok := storyData["name"].(string); ok {
-		story.Title = title
-	}
-}
\ No newline at end of file
+	story.StoryData = *data
+
+	// Estrai titolo (se presente in StoryData, chiave non standard)
+	// Il passaggio StoryTitle ha la precedenza
+	var extra struct {
+		Name string `json:"name"`
+	}
+	if _, hasTitle := story.Passages["StoryTitle"]; hasTitle {
+		return
+	}
+	if json.Unmarshal([]byte(content), &extra) == nil && extra.Name != "" {
+		story.Title = extra.Name
+	}
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ============================================
+// Test: Ordine dei passaggi e duplicati
+// ============================================
+
+func TestPassageOrderPreserved(t *testing.T) {
+	story := parseSource(t, ":: Zeta\nz\n\n:: Alfa\na\n\n:: Mezzo\nm\n")
+
+	expected := []string{"Zeta", "Alfa", "Mezzo"}
+	if !reflect.DeepEqual(story.Order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, story.Order)
+	}
+
+	ordered := story.OrderedPassages()
+	for i, passage := range ordered {
+		if passage.Title != expected[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, expected[i], passage.Title)
+		}
+	}
+
+	t.Log("✅ Source order preserved")
+}
+
+func TestDuplicatePassageIsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dup.twee")
+	source := ":: Inizio\nprimo\n\n:: Altro\nx\n\n:: Inizio\nsecondo\n"
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	validation := NewTweeParser(path).Validate()
+	if validation.Valid {
+		t.Fatal("Expected validation to fail on duplicate title")
+	}
+
+	dupErr := validation.Errors[0]
+	if dupErr.Line != 7 {
+		t.Errorf("Expected error on line 7, got %d", dupErr.Line)
+	}
+	if !strings.Contains(dupErr.Message, "1") || !strings.Contains(dupErr.Message, "7") {
+		t.Errorf("Expected both line numbers in message, got %q", dupErr.Message)
+	}
+
+	if _, err := NewTweeParser(path).Parse(); err == nil {
+		t.Error("Expected Parse to fail on duplicate title")
+	}
+
+	t.Log("✅ Duplicate titles reported with both lines")
+}
+
+// ============================================
+// Test: Parsing da memoria
+// ============================================
+
+func TestParseString(t *testing.T) {
+	story, err := ParseString(":: StoryData\n{\"start\":\"Inizio\"}\n\n:: Inizio\nCiao [[Fine]]\n\n:: Fine\nFine.\n", "buffer.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+
+	inizio := story.Passages["Inizio"]
+	if inizio == nil || inizio.Content != "Ciao [[Fine]]" {
+		t.Fatalf("Unexpected passage: %+v", inizio)
+	}
+	if inizio.File != "buffer.twee" || inizio.Header.Line != 4 {
+		t.Errorf("Expected buffer.twee:4, got %s:%d", inizio.File, inizio.Header.Line)
+	}
+	if story.Start != "Inizio" {
+		t.Errorf("Expected start 'Inizio', got %q", story.Start)
+	}
+
+	t.Log("✅ In-memory content parsed correctly")
+}
+
+func TestParseReaderReportsErrors(t *testing.T) {
+	_, err := ParseReader(strings.NewReader(":: A\nx\n\n:: A [tag\ny\n"), "buffer.twee")
+	if err == nil {
+		t.Fatal("Expected error for malformed header")
+	}
+	if !strings.Contains(err.Error(), "buffer.twee:4:1") {
+		t.Errorf("Expected error position buffer.twee:4:1, got %v", err)
+	}
+
+	validation := ValidateString(":: A\nx\n\n:: A\ny\n", "buffer.twee")
+	if validation.Valid {
+		t.Error("Expected ValidateString to report duplicate title")
+	}
+
+	t.Log("✅ In-memory validation reports errors with positions")
+}
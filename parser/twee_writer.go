@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TweeWriter serializza una Story in sorgente Twee 3
+type TweeWriter struct {
+	w io.Writer
+}
+
+// NewTweeWriter crea un nuovo writer
+func NewTweeWriter(w io.Writer) *TweeWriter {
+	return &TweeWriter{w: w}
+}
+
+// WriteTwee scrive la storia in formato .twee
+func (s *Story) WriteTwee(w io.Writer) error {
+	return NewTweeWriter(w).Write(s)
+}
+
+// Write scrive la storia: StoryTitle, StoryData e poi gli altri passaggi in ordine
+// L'output è stabile: parse → write → parse restituisce la stessa storia
+func (tw *TweeWriter) Write(story *Story) error {
+	var buf bytes.Buffer
+
+	// 1. StoryTitle
+	title := story.Title
+	if title == "" {
+		if passage, exists := story.Passages["StoryTitle"]; exists {
+			title = passage.Content
+		}
+	}
+	if title != "" {
+		tw.writePassage(&buf, &Passage{Title: "StoryTitle", Content: title})
+	}
+
+	// 2. StoryData generato dai campi della storia
+	storyData, err := tw.buildStoryData(story)
+	if err != nil {
+		return err
+	}
+	if storyData != "" {
+		tw.writePassage(&buf, &Passage{Title: "StoryData", Content: storyData})
+	}
+
+	// 3. Tutti gli altri passaggi in ordine di sorgente
+	for _, passage := range story.OrderedPassages() {
+		if passage.Title == "StoryTitle" || passage.Title == "StoryData" {
+			continue
+		}
+		tw.writePassage(&buf, passage)
+	}
+
+	_, err = tw.w.Write(buf.Bytes())
+	return err
+}
+
+// writePassage scrive intestazione e contenuto di un passaggio
+// I passaggi sono separati da due righe vuote
+func (tw *TweeWriter) writePassage(buf *bytes.Buffer, passage *Passage) {
+	if buf.Len() > 0 {
+		buf.WriteString("\n\n")
+	}
+
+	buf.WriteString(formatHeader(passage))
+	buf.WriteString("\n")
+
+	if passage.Content != "" {
+		buf.WriteString(escapeContent(passage.Content))
+		buf.WriteString("\n")
+	}
+}
+
+// formatHeader costruisce ":: Titolo [tag] {metadata}" con gli escape Twee 3
+func formatHeader(passage *Passage) string {
+	var sb strings.Builder
+	sb.WriteString(":: ")
+	sb.WriteString(EscapeTwee(passage.Title))
+
+	if len(passage.Tags) > 0 {
+		tags := make([]string, len(passage.Tags))
+		for i, tag := range passage.Tags {
+			tags[i] = EscapeTwee(tag)
+		}
+		sb.WriteString(" [")
+		sb.WriteString(strings.Join(tags, " "))
+		sb.WriteString("]")
+	}
+
+	if metadata := formatMetadata(passage); metadata != "" {
+		sb.WriteString(" ")
+		sb.WriteString(metadata)
+	}
+
+	return sb.String()
+}
+
+// formatMetadata serializza position, size e metadata extra
+// Le chiavi hanno ordine fisso (position, size, poi le altre in ordine alfabetico)
+func formatMetadata(passage *Passage) string {
+	parts := []string{}
+
+	if passage.Position.X != 0 || passage.Position.Y != 0 {
+		parts = append(parts, fmt.Sprintf(`"position":"%s,%s"`,
+			formatNumber(passage.Position.X), formatNumber(passage.Position.Y)))
+	}
+
+	if passage.Size.Width != 0 || passage.Size.Height != 0 {
+		parts = append(parts, fmt.Sprintf(`"size":"%s,%s"`,
+			formatNumber(passage.Size.Width), formatNumber(passage.Size.Height)))
+	}
+
+	keys := make([]string, 0, len(passage.Metadata))
+	for key := range passage.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyJSON, _ := json.Marshal(key)
+		valueJSON, err := json.Marshal(passage.Metadata[key])
+		if err != nil {
+			continue
+		}
+		parts = append(parts, string(keyJSON)+":"+string(valueJSON))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatNumber formatta un numero senza decimali superflui
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// escapeContent protegge le righe che inizierebbero un nuovo passaggio (:: → \::)
+// Anche le righe già escapate ricevono un "\" in più (\:: → \\::): il parser ne toglie
+// sempre esattamente uno, quindi il contenuto torna identico
+func escapeContent(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if isEscapedHeader(line) {
+			lines[i] = "\\" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isEscapedHeader verifica se una riga è "::" preceduto da zero o più "\"
+func isEscapedHeader(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, "\\"), "::")
+}
+
+// buildStoryData genera il JSON di StoryData dai campi della storia
+// Le chiavi non gestite dal parser vengono conservate dal passaggio StoryData originale
+func (tw *TweeWriter) buildStoryData(story *Story) (string, error) {
+	data := make(map[string]interface{})
+
+	if passage, exists := story.Passages["StoryData"]; exists {
+		// Se il JSON originale non è valido viene rigenerato da zero
+		_ = json.Unmarshal([]byte(passage.Content), &data)
+	}
+
+	if story.IFID != "" {
+		data["ifid"] = story.IFID
+	}
+	if story.Format != "" {
+		// Mantieni le maiuscole originali se il formato non è cambiato
+		original, _ := data["format"].(string)
+		if !strings.EqualFold(original, story.Format) {
+			data["format"] = story.Format
+		}
+	}
+	if story.FormatVersion != "" {
+		data["format-version"] = story.FormatVersion
+	}
+	if story.Start != "" {
+		data["start"] = story.Start
+	}
+	if len(story.TagColors) > 0 {
+		data["tag-colors"] = story.TagColors
+	}
+	if story.Zoom != 0 {
+		data["zoom"] = story.Zoom
+	}
+
+	if len(data) == 0 {
+		return "", nil
+	}
+
+	return marshalOrdered(data, []string{"ifid", "format", "format-version", "start", "tag-colors", "zoom"})
+}
+
+// marshalOrdered serializza una mappa JSON indentata con le chiavi in ordine dato
+// Le chiavi non elencate seguono in ordine alfabetico
+func marshalOrdered(data map[string]interface{}, order []string) (string, error) {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, key := range order {
+		if _, exists := data[key]; exists {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	extra := []string{}
+	for key := range data {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	keys = append(keys, extra...)
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i, key := range keys {
+		keyJSON, _ := json.Marshal(key)
+		valueJSON, err := json.MarshalIndent(data[key], "  ", "  ")
+		if err != nil {
+			return "", fmt.Errorf("errore serializzazione StoryData: %w", err)
+		}
+
+		sb.WriteString("  ")
+		sb.Write(keyJSON)
+		sb.WriteString(": ")
+		sb.Write(valueJSON)
+		if i < len(keys)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("}")
+
+	return sb.String(), nil
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const roundTripSource = `:: StoryTitle
+Round Trip
+
+
+:: StoryData
+{
+  "ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC",
+  "format": "Harlowe",
+  "format-version": "3.2.3",
+  "start": "Inizio"
+}
+
+
+:: Inizio [inizio importante] {"position":"100.5,200","size":"200,100"}
+(set: $vita to 100)
+[[Room \[A\]]]
+
+
+:: Room \[A\] [tag\]x] {"custom":{"a":1}}
+Contenuto
+\:: non è un'intestazione
+
+
+:: Vuoto
+`
+
+// parseSource scrive il sorgente in un file temporaneo e lo parsa
+func parseSource(t *testing.T, source string) *Story {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "story.twee")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Error writing source: %v", err)
+	}
+
+	story, err := NewTweeParser(path).Parse()
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+	return story
+}
+
+// ============================================
+// Test: parse → write → parse
+// ============================================
+
+func TestWriteTweeRoundTrip(t *testing.T) {
+	original := parseSource(t, roundTripSource)
+
+	var buf bytes.Buffer
+	if err := original.WriteTwee(&buf); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+
+	reparsed := parseSource(t, buf.String())
+
+	if reparsed.IFID != original.IFID || reparsed.Format != original.Format ||
+		reparsed.FormatVersion != original.FormatVersion {
+		t.Errorf("StoryData changed: %+v vs %+v", reparsed, original)
+	}
+
+	if len(reparsed.Passages) != len(original.Passages) {
+		t.Fatalf("Expected %d passages, got %d", len(original.Passages), len(reparsed.Passages))
+	}
+
+	for title, passage := range original.Passages {
+		other, exists := reparsed.Passages[title]
+		if !exists {
+			t.Errorf("Passage %q lost in round-trip", title)
+			continue
+		}
+		if other.Content != passage.Content {
+			t.Errorf("[%s] Content changed: %q vs %q", title, other.Content, passage.Content)
+		}
+		if !reflect.DeepEqual(other.Tags, passage.Tags) {
+			t.Errorf("[%s] Tags changed: %v vs %v", title, other.Tags, passage.Tags)
+		}
+		if other.Position != passage.Position || other.Size != passage.Size {
+			t.Errorf("[%s] Layout changed", title)
+		}
+		if !reflect.DeepEqual(other.Metadata, passage.Metadata) {
+			t.Errorf("[%s] Metadata changed: %v vs %v", title, other.Metadata, passage.Metadata)
+		}
+	}
+
+	// Una seconda scrittura deve produrre esattamente gli stessi byte
+	var second bytes.Buffer
+	if err := reparsed.WriteTwee(&second); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+	if second.String() != buf.String() {
+		t.Errorf("Output not stable:\n%s\n---\n%s", buf.String(), second.String())
+	}
+
+	t.Log("✅ parse → write → parse is lossless")
+}
+
+func TestWriteTweeEscapesContent(t *testing.T) {
+	story := parseSource(t, roundTripSource)
+
+	passage := story.Passages["Room [A]"]
+	if passage == nil {
+		t.Fatal("Expected passage 'Room [A]'")
+	}
+	if passage.Content != "Contenuto\n:: non è un'intestazione" {
+		t.Errorf("Unexpected content: %q", passage.Content)
+	}
+
+	t.Log("✅ Content lines starting with :: are escaped")
+}
+
+func TestWriteTweeBackslashContentRoundTrip(t *testing.T) {
+	story := parseSource(t, roundTripSource)
+
+	// Contenuto già escapato, ad esempio importato da un file HTML di Twine
+	content := "::a\n\\::b\n\\\\::c\n\\ testo\n:: fine"
+	story.Passages["Vuoto"].Content = content
+
+	var buf bytes.Buffer
+	if err := story.WriteTwee(&buf); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+
+	reparsed := parseSource(t, buf.String())
+	if got := reparsed.Passages["Vuoto"].Content; got != content {
+		t.Errorf("Content changed in round-trip: %q vs %q", got, content)
+	}
+
+	t.Log("✅ Content lines starting with backslashes survive write → parse")
+}
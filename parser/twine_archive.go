@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Creatore dichiarato negli archivi esportati
+const (
+	ArchiveCreator        = "tweego-editor"
+	ArchiveCreatorVersion = "0.1.0"
+)
+
+// formatDisplayNames mappa i nomi normalizzati dei formati su quelli usati da Twine 2
+var formatDisplayNames = map[string]string{
+	"harlowe":   "Harlowe",
+	"sugarcube": "SugarCube",
+	"snowman":   "Snowman",
+	"chapbook":  "Chapbook",
+	"paperthin": "Paperthin",
+}
+
+// TwineArchiveWriter serializza storie in un archivio della libreria Twine 2
+// L'archivio è importabile dal menu "Library → Import" di Twine
+type TwineArchiveWriter struct {
+	w io.Writer
+}
+
+// NewTwineArchiveWriter crea un nuovo writer di archivi
+func NewTwineArchiveWriter(w io.Writer) *TwineArchiveWriter {
+	return &TwineArchiveWriter{w: w}
+}
+
+// WriteTwineArchive scrive la storia come archivio Twine 2
+func (s *Story) WriteTwineArchive(w io.Writer) error {
+	return NewTwineArchiveWriter(w).Write(s)
+}
+
+// Write scrive un blocco <tw-storydata> per ogni storia
+func (aw *TwineArchiveWriter) Write(stories ...*Story) error {
+	var buf bytes.Buffer
+
+	for i, story := range stories {
+		if i > 0 {
+			buf.WriteString("\n\n")
+		}
+		if err := aw.writeStory(&buf, story); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\n")
+
+	_, err := aw.w.Write(buf.Bytes())
+	return err
+}
+
+// writeStory scrive una storia con stylesheet, script, colori dei tag e passaggi
+func (aw *TwineArchiveWriter) writeStory(buf *bytes.Buffer, story *Story) error {
+	passages := []*Passage{}
+	stylesheets := []string{}
+	scripts := []string{}
+
+	for _, passage := range story.OrderedPassages() {
+		switch {
+		case passage.Kind() == KindStoryTitle || passage.Kind() == KindStoryData:
+			continue
+		case passage.Kind() == KindStylesheet:
+			stylesheets = append(stylesheets, passage.Content)
+		case passage.Kind() == KindScript:
+			scripts = append(scripts, passage.Content)
+		default:
+			passages = append(passages, passage)
+		}
+	}
+
+	// Twine richiede un nodo iniziale: StoryData, poi "Start", poi il primo passaggio
+	pids := make(map[string]int, len(passages))
+	for i, passage := range passages {
+		pids[passage.Title] = i + 1
+	}
+
+	startNode := pids[story.Start]
+	if story.Start != "" && startNode == 0 {
+		return fmt.Errorf("passaggio iniziale '%s' non trovato", story.Start)
+	}
+	if startNode == 0 {
+		startNode = pids["Start"]
+	}
+	if startNode == 0 && len(passages) > 0 {
+		startNode = 1
+	}
+
+	title := story.Title
+	if title == "" {
+		if passage, exists := story.Passages["StoryTitle"]; exists {
+			title = passage.Content
+		}
+	}
+
+	zoom := story.Zoom
+	if zoom == 0 {
+		zoom = 1
+	}
+
+	buf.WriteString("<tw-storydata")
+	writeAttr(buf, "name", title)
+	if startNode > 0 {
+		writeAttr(buf, "startnode", strconv.Itoa(startNode))
+	}
+	writeAttr(buf, "creator", ArchiveCreator)
+	writeAttr(buf, "creator-version", ArchiveCreatorVersion)
+	writeAttr(buf, "format", aw.formatName(story))
+	writeAttr(buf, "format-version", story.FormatVersion)
+	writeAttr(buf, "ifid", story.IFID)
+	writeAttr(buf, "options", "")
+	writeAttr(buf, "tags", "")
+	writeAttr(buf, "zoom", formatNumber(zoom))
+	buf.WriteString(" hidden>")
+
+	// Stylesheet e script vengono scritti senza escape HTML, come fa Twine
+	buf.WriteString(`<style role="stylesheet" id="twine-user-stylesheet" type="text/twine-css">`)
+	buf.WriteString(strings.Join(stylesheets, "\n"))
+	buf.WriteString("</style>")
+	buf.WriteString(`<script role="script" id="twine-user-script" type="text/twine-javascript">`)
+	buf.WriteString(strings.Join(scripts, "\n"))
+	buf.WriteString("</script>")
+
+	tags := make([]string, 0, len(story.TagColors))
+	for tag := range story.TagColors {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		buf.WriteString("<tw-tag")
+		writeAttr(buf, "name", tag)
+		writeAttr(buf, "color", story.TagColors[tag])
+		buf.WriteString("></tw-tag>")
+	}
+
+	for i, passage := range passages {
+		aw.writePassage(buf, passage, i)
+	}
+
+	buf.WriteString("</tw-storydata>")
+	return nil
+}
+
+// writePassage scrive un <tw-passagedata>
+// I passaggi senza posizione vengono disposti su una griglia per non sovrapporsi in Twine
+func (aw *TwineArchiveWriter) writePassage(buf *bytes.Buffer, passage *Passage, index int) {
+	position := passage.Position
+	if position.X == 0 && position.Y == 0 {
+		position = Position{
+			X: float64(100 + (index%10)*125),
+			Y: float64(100 + (index/10)*125),
+		}
+	}
+
+	size := passage.Size
+	if size.Width == 0 && size.Height == 0 {
+		size = Size{Width: 100, Height: 100}
+	}
+
+	buf.WriteString("<tw-passagedata")
+	writeAttr(buf, "pid", strconv.Itoa(index+1))
+	writeAttr(buf, "name", passage.Title)
+	writeAttr(buf, "tags", strings.Join(passage.Tags, " "))
+	writeAttr(buf, "position", formatNumber(position.X)+","+formatNumber(position.Y))
+	writeAttr(buf, "size", formatNumber(size.Width)+","+formatNumber(size.Height))
+	buf.WriteString(">")
+	buf.WriteString(html.EscapeString(passage.Content))
+	buf.WriteString("</tw-passagedata>")
+}
+
+// formatName restituisce il nome del formato come lo scrive Twine ("Harlowe", "SugarCube", ...)
+// Se StoryData è presente viene mantenuto il nome originale
+func (aw *TwineArchiveWriter) formatName(story *Story) string {
+	if passage, exists := story.Passages["StoryData"]; exists {
+		var raw struct {
+			Format string `json:"format"`
+		}
+		if json.Unmarshal([]byte(passage.Content), &raw) == nil && strings.EqualFold(raw.Format, story.Format) {
+			return raw.Format
+		}
+	}
+
+	if name, exists := formatDisplayNames[strings.ToLower(story.Format)]; exists {
+		return name
+	}
+	return story.Format
+}
+
+// writeAttr scrive un attributo HTML con escape del valore
+func writeAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" ")
+	buf.WriteString(name)
+	buf.WriteString(`="`)
+	buf.WriteString(html.EscapeString(value))
+	buf.WriteString(`"`)
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ============================================
+// Test: Export archivio Twine 2
+// ============================================
+
+func TestTwineArchiveRoundTrip(t *testing.T) {
+	original := parseSource(t, `:: StoryTitle
+Prova <Export> & "co"
+
+:: StoryData
+{
+  "ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC",
+  "format": "Harlowe",
+  "format-version": "3.3.8",
+  "start": "Inizio",
+  "tag-colors": {"bar": "green"},
+  "zoom": 0.6
+}
+
+:: Stile [stylesheet]
+body { color: red; }
+
+:: Inizio [bar importante] {"position":"100.5,200","size":"200,100"}
+(if: $x < 2)[[[Vai->Altro]]] & 'fine'
+
+:: Altro
+Fine
+`)
+
+	var buf bytes.Buffer
+	if err := original.WriteTwineArchive(&buf); err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+
+	archive := buf.String()
+	if !strings.Contains(archive, `format="Harlowe"`) || !strings.Contains(archive, `startnode="1"`) {
+		t.Errorf("Unexpected story attributes:\n%s", archive)
+	}
+
+	stories, err := NewTwineImporter("").importData(archive)
+	if err != nil {
+		t.Fatalf("Error importing archive: %v", err)
+	}
+	imported := stories[0]
+
+	if title := original.Passages["StoryTitle"].Content; imported.Title != title {
+		t.Errorf("Title changed: %q vs %q", imported.Title, title)
+	}
+	if !reflect.DeepEqual(imported.StoryData, original.StoryData) {
+		t.Errorf("StoryData changed: %+v vs %+v", imported.StoryData, original.StoryData)
+	}
+
+	for _, title := range []string{"Inizio", "Altro"} {
+		a, b := original.Passages[title], imported.Passages[title]
+		if b == nil {
+			t.Errorf("Passage %q lost in export", title)
+			continue
+		}
+		if a.Content != b.Content || !reflect.DeepEqual(a.Tags, b.Tags) {
+			t.Errorf("[%s] Passage changed: %+v vs %+v", title, b, a)
+		}
+	}
+
+	inizio := imported.Passages["Inizio"]
+	if inizio.Position != (Position{X: 100.5, Y: 200}) || inizio.Size != (Size{Width: 200, Height: 100}) {
+		t.Errorf("Layout changed: %+v %+v", inizio.Position, inizio.Size)
+	}
+	if altro := imported.Passages["Altro"]; altro.Position == (Position{}) {
+		t.Error("Expected a grid position for passage without layout")
+	}
+	if css := imported.Passages["Story Stylesheet"]; css == nil || css.Content != "body { color: red; }" {
+		t.Errorf("Stylesheet not exported: %+v", css)
+	}
+
+	t.Log("✅ Story → Twine archive → Story preserves data")
+}
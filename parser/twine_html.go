@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TwineImporter importa storie da HTML pubblicati e archivi della libreria Twine 2
+// Un HTML pubblicato contiene un solo <tw-storydata>, un archivio uno per storia
+type TwineImporter struct {
+	filepath string
+}
+
+var (
+	storyDataElemRegex   = regexp.MustCompile(`(?is)<tw-storydata\b([^>]*)>(.*?)</tw-storydata\s*>`)
+	passageDataElemRegex = regexp.MustCompile(`(?is)<tw-passagedata\b([^>]*)>(.*?)</tw-passagedata\s*>`)
+	tagElemRegex         = regexp.MustCompile(`(?is)<tw-tag\b([^>]*)>`)
+	styleElemRegex       = regexp.MustCompile(`(?is)<style\b([^>]*)>(.*?)</style\s*>`)
+	scriptElemRegex      = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script\s*>`)
+	htmlAttrRegex        = regexp.MustCompile(`([\w-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+)
+
+// NewTwineImporter crea un nuovo importer per un file .html
+func NewTwineImporter(filepath string) *TwineImporter {
+	return &TwineImporter{filepath: filepath}
+}
+
+// IsTwineHTMLFile verifica se un file ha estensione .html/.htm
+func IsTwineHTMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+
+// Import legge tutte le storie contenute nel file
+func (ti *TwineImporter) Import() ([]*Story, error) {
+	data, err := os.ReadFile(ti.filepath)
+	if err != nil {
+		return nil, fmt.Errorf("errore lettura file: %w", err)
+	}
+
+	return ti.importData(string(data))
+}
+
+// ImportStory legge la storia di un HTML pubblicato
+// Per gli archivi con più storie restituisce un errore (usare Import)
+func (ti *TwineImporter) ImportStory() (*Story, error) {
+	stories, err := ti.Import()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stories) > 1 {
+		return nil, fmt.Errorf("il file contiene %d storie: usare Import per gli archivi", len(stories))
+	}
+	return stories[0], nil
+}
+
+// Validate verifica che il file sia importabile
+// Il risultato ha lo stesso formato della validazione dei sorgenti .twee
+func (ti *TwineImporter) Validate() *ValidationResult {
+	result := &ValidationResult{
+		Valid:    true,
+		Errors:   []ValidationError{},
+		Warnings: []ValidationError{},
+	}
+
+	stories, err := ti.Import()
+	if err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Type:    "error",
+			Message: err.Error(),
+			File:    ti.filepath,
+		})
+		return result
+	}
+
+	for _, story := range stories {
+		if story.IFID != "" && !ValidIFID(story.IFID) {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Type:    "error",
+				Message: fmt.Sprintf("IFID '%s' della storia '%s' non valido: deve essere un UUID versione 4", story.IFID, story.Title),
+				File:    ti.filepath,
+			})
+		}
+		if len(story.Passages) == 0 {
+			result.Warnings = append(result.Warnings, ValidationError{
+				Type:    "warning",
+				Message: fmt.Sprintf("La storia '%s' non contiene passaggi", story.Title),
+				File:    ti.filepath,
+			})
+		}
+	}
+
+	return result
+}
+
+// importData estrae le storie dal sorgente HTML
+func (ti *TwineImporter) importData(data string) ([]*Story, error) {
+	matches := storyDataElemRegex.FindAllStringSubmatchIndex(data, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("nessun elemento <tw-storydata> trovato in %s", ti.filepath)
+	}
+
+	stories := []*Story{}
+	for _, m := range matches {
+		attrs := parseHTMLAttrs(data[m[2]:m[3]])
+		story, err := ti.importStory(attrs, data, m[4], m[5])
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+
+	return stories, nil
+}
+
+// importStory costruisce una Story da un elemento <tw-storydata>
+// bodyStart e bodyEnd delimitano il contenuto dell'elemento in data
+func (ti *TwineImporter) importStory(attrs map[string]string, data string, bodyStart, bodyEnd int) (*Story, error) {
+	story := &Story{
+		Title:    attrs["name"],
+		Passages: make(map[string]*Passage),
+	}
+
+	story.IFID = attrs["ifid"]
+	story.Format = strings.ToLower(attrs["format"])
+	story.FormatVersion = attrs["format-version"]
+	if zoom, err := strconv.ParseFloat(attrs["zoom"], 64); err == nil {
+		story.Zoom = zoom
+	}
+
+	body := data[bodyStart:bodyEnd]
+
+	// Colori dei tag
+	for _, m := range tagElemRegex.FindAllStringSubmatch(body, -1) {
+		tagAttrs := parseHTMLAttrs(m[1])
+		if tagAttrs["name"] != "" && tagAttrs["color"] != "" {
+			if story.TagColors == nil {
+				story.TagColors = make(map[string]string)
+			}
+			story.TagColors[tagAttrs["name"]] = tagAttrs["color"]
+		}
+	}
+
+	// Passaggi
+	names := map[string]string{} // pid → titolo
+	for _, m := range passageDataElemRegex.FindAllStringSubmatchIndex(body, -1) {
+		passageAttrs := parseHTMLAttrs(body[m[2]:m[3]])
+
+		passage := &Passage{
+			Title:    passageAttrs["name"],
+			Tags:     strings.Fields(passageAttrs["tags"]),
+			Content:  html.UnescapeString(body[m[4]:m[5]]),
+			Metadata: make(map[string]interface{}),
+			ParsedAt: time.Now(),
+			File:     ti.filepath,
+			Header:   offsetToPos(data, bodyStart+m[0]),
+		}
+
+		if passage.Title == "" {
+			return nil, fmt.Errorf("%s:%d: passaggio senza nome", ti.filepath, passage.Header.Line)
+		}
+		if _, exists := story.Passages[passage.Title]; exists {
+			return nil, fmt.Errorf("%s:%d: passaggio '%s' definito due volte", ti.filepath, passage.Header.Line, passage.Title)
+		}
+
+		if value, ok := passageAttrs["position"]; ok && value != "" {
+			x, y, err := parsePair(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: position non valida nel passaggio '%s': %v", ti.filepath, passage.Header.Line, passage.Title, err)
+			}
+			passage.Position = Position{X: x, Y: y}
+		}
+		if value, ok := passageAttrs["size"]; ok && value != "" {
+			w, h, err := parsePair(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: size non valida nel passaggio '%s': %v", ti.filepath, passage.Header.Line, passage.Title, err)
+			}
+			passage.Size = Size{Width: w, Height: h}
+		}
+
+		names[passageAttrs["pid"]] = passage.Title
+		story.AddPassage(passage)
+	}
+
+	// Il nodo iniziale è indicato per pid
+	if startNode := attrs["startnode"]; startNode != "" {
+		start, exists := names[startNode]
+		if !exists {
+			return nil, fmt.Errorf("%s: passaggio iniziale con pid %s non trovato", ti.filepath, startNode)
+		}
+		story.Start = start
+	}
+
+	// Stylesheet e script della storia diventano passaggi come nella decompilazione di Tweego
+	if css := userElementContent(styleElemRegex, body); css != "" {
+		story.AddPassage(&Passage{
+			Title:    "Story Stylesheet",
+			Tags:     []string{"stylesheet"},
+			Content:  css,
+			Metadata: make(map[string]interface{}),
+			File:     ti.filepath,
+		})
+	}
+	if js := userElementContent(scriptElemRegex, body); js != "" {
+		story.AddPassage(&Passage{
+			Title:    "Story JavaScript",
+			Tags:     []string{"script"},
+			Content:  js,
+			Metadata: make(map[string]interface{}),
+			File:     ti.filepath,
+		})
+	}
+
+	return story, nil
+}
+
+// userElementContent concatena il contenuto degli elementi utente (<style>/<script>)
+// Twine li scrive con id twine-user-stylesheet/twine-user-script, senza escape HTML
+func userElementContent(re *regexp.Regexp, body string) string {
+	parts := []string{}
+	for _, m := range re.FindAllStringSubmatch(body, -1) {
+		attrs := parseHTMLAttrs(m[1])
+		if !strings.HasPrefix(attrs["id"], "twine-user-") && attrs["role"] == "" {
+			continue
+		}
+		if content := strings.TrimSpace(m[2]); content != "" {
+			parts = append(parts, content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// parseHTMLAttrs estrae gli attributi di un tag HTML (nomi in minuscolo, valori decodificati)
+func parseHTMLAttrs(raw string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range htmlAttrRegex.FindAllStringSubmatch(raw, -1) {
+		value := m[2] + m[3] + m[4]
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(value)
+	}
+	return attrs
+}
+
+// offsetToPos converte un offset in byte in una posizione (riga e colonna)
+func offsetToPos(data string, offset int) SourcePos {
+	before := data[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return SourcePos{Line: line, Column: column, Offset: offset}
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const publishedHTML = `<!DOCTYPE html>
+<html>
+<head><title>Prova</title></head>
+<body>
+<tw-storydata name="Prova &amp; Test" startnode="2" creator="Twine" creator-version="2.6.2" format="Harlowe" format-version="3.3.8" ifid="D674C58C-DEFA-4F70-B7A2-27742230C0FC" options="" tags="" zoom="0.6" hidden><style role="stylesheet" id="twine-user-stylesheet" type="text/twine-css">body { color: red; }</style><script role="script" id="twine-user-script" type="text/twine-javascript">window.x = 1 < 2;</script><tw-tag name="bar" color="green"></tw-tag><tw-passagedata pid="1" name="Altro" tags="" position="300,100" size="100,100">Fine</tw-passagedata>
+<tw-passagedata pid="2" name="Inizio" tags="bar importante" position="100.5,200" size="200,100">(set: $x to 1)
+[[Vai-&gt;Altro]] &quot;ciao&quot;</tw-passagedata></tw-storydata>
+<script>/* codice del formato */</script>
+</body>
+</html>
+`
+
+// writeHTML scrive l'HTML in un file temporaneo
+func writeHTML(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "story.html")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ============================================
+// Test: Import HTML Twine 2
+// ============================================
+
+func TestImportPublishedHTML(t *testing.T) {
+	story, err := NewTwineImporter(writeHTML(t, publishedHTML)).ImportStory()
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+
+	if story.Title != "Prova & Test" || story.Format != "harlowe" || story.FormatVersion != "3.3.8" {
+		t.Errorf("Unexpected story data: %q %q %q", story.Title, story.Format, story.FormatVersion)
+	}
+	if story.Start != "Inizio" || story.Zoom != 0.6 || story.TagColors["bar"] != "green" {
+		t.Errorf("Unexpected StoryData: %+v", story.StoryData)
+	}
+
+	inizio := story.Passages["Inizio"]
+	if inizio == nil {
+		t.Fatal("Expected passage 'Inizio'")
+	}
+	if inizio.Content != "(set: $x to 1)\n[[Vai->Altro]] \"ciao\"" {
+		t.Errorf("Unexpected content: %q", inizio.Content)
+	}
+	if !reflect.DeepEqual(inizio.Tags, []string{"bar", "importante"}) {
+		t.Errorf("Unexpected tags: %v", inizio.Tags)
+	}
+	if inizio.Position != (Position{X: 100.5, Y: 200}) || inizio.Size != (Size{Width: 200, Height: 100}) {
+		t.Errorf("Unexpected layout: %+v %+v", inizio.Position, inizio.Size)
+	}
+	if inizio.Header.Line != 6 {
+		t.Errorf("Expected header on line 6, got %d", inizio.Header.Line)
+	}
+
+	if css := story.Passages["Story Stylesheet"]; css == nil || css.Content != "body { color: red; }" {
+		t.Errorf("Unexpected stylesheet: %+v", css)
+	}
+	if js := story.Passages["Story JavaScript"]; js == nil || js.Content != "window.x = 1 < 2;" {
+		t.Errorf("Unexpected script: %+v", js)
+	}
+
+	expected := []string{"Altro", "Inizio", "Story Stylesheet", "Story JavaScript"}
+	if !reflect.DeepEqual(story.Order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, story.Order)
+	}
+
+	t.Log("✅ Published HTML imported correctly")
+}
+
+func TestImportArchive(t *testing.T) {
+	archive := `<tw-storydata name="Uno" startnode="1" format="Harlowe" ifid="D674C58C-DEFA-4F70-B7A2-27742230C0FC"><tw-passagedata pid="1" name="A">a</tw-passagedata></tw-storydata>
+<tw-storydata name="Due" startnode="1" format="SugarCube" ifid="A674C58C-DEFA-4F70-B7A2-27742230C0FC"><tw-passagedata pid="1" name="B">b</tw-passagedata></tw-storydata>`
+
+	importer := NewTwineImporter(writeHTML(t, archive))
+	stories, err := importer.Import()
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if len(stories) != 2 || stories[0].Title != "Uno" || stories[1].Start != "B" {
+		t.Errorf("Unexpected stories: %+v", stories)
+	}
+
+	if _, err := importer.ImportStory(); err == nil {
+		t.Error("Expected ImportStory to fail on archive")
+	}
+
+	t.Log("✅ Library archive imported correctly")
+}
@@ -0,0 +1,90 @@
+package simulator
+
+// ============================================
+// ENUMERAZIONE DEGLI ESITI CASUALI
+// ============================================
+
+// maxOutcomes limita i rami enumerati, ad esempio con (random: 1, 1000)
+const maxOutcomes = 1000
+
+// Outcome è un ramo della simulazione: una combinazione di scelte casuali
+type Outcome struct {
+	Choices     []int             `json:"choices"`     // Indice scelto a ogni (either:), (random:), ...
+	Probability float64           `json:"probability"` // Probabilità del ramo
+	Result      *SimulationResult `json:"result"`
+}
+
+// OutcomesResult contiene tutti i rami di una simulazione
+type OutcomesResult struct {
+	Path      []string  `json:"path"`
+	Outcomes  []Outcome `json:"outcomes"`
+	Truncated bool      `json:"truncated"` // true se i rami superano maxOutcomes
+}
+
+// choice è una scelta fatta dal generatore: l'indice e il numero di alternative
+type choice struct {
+	index int
+	n     int
+}
+
+// replayRandom ripete le scelte di un prefisso e sceglie 0 per quelle successive
+// Registra tutte le scelte, così i rami non ancora esplorati si ricavano dalle alternative
+type replayRandom struct {
+	prefix  []int
+	choices []choice
+}
+
+// Intn restituisce la scelta del prefisso o 0
+func (r *replayRandom) Intn(n int) int {
+	index := 0
+	if k := len(r.choices); k < len(r.prefix) && r.prefix[k] < n {
+		index = r.prefix[k]
+	}
+	r.choices = append(r.choices, choice{index: index, n: n})
+	return index
+}
+
+// Seed non ha effetto: tutti gli esiti vengono enumerati comunque
+func (r *replayRandom) Seed(seed string) {}
+
+// EnumerateOutcomes simula il percorso per ogni combinazione di scelte casuali
+// Ogni scelta con n alternative divide il ramo corrente in n rami di probabilità 1/n
+func (ps *PathSimulator) EnumerateOutcomes(path []string) *OutcomesResult {
+	result := &OutcomesResult{Path: path, Outcomes: []Outcome{}}
+
+	pending := [][]int{{}}
+	for len(pending) > 0 {
+		prefix := pending[0]
+		pending = pending[1:]
+
+		random := &replayRandom{prefix: prefix}
+		ps.random = random
+		outcome := Outcome{
+			Choices:     []int{},
+			Probability: 1,
+			Result:      ps.simulatePath(path),
+		}
+		for _, c := range random.choices {
+			outcome.Choices = append(outcome.Choices, c.index)
+			outcome.Probability /= float64(c.n)
+		}
+		result.Outcomes = append(result.Outcomes, outcome)
+
+		// Le scelte dopo il prefisso sono state 0: ogni alternativa è un nuovo ramo
+		// Anche i rami in attesa contano nel limite, altrimenti (random: 1, 5000000) riempie la coda
+	branches:
+		for k := len(prefix); k < len(random.choices); k++ {
+			for alt := 1; alt < random.choices[k].n; alt++ {
+				if len(result.Outcomes)+len(pending) >= maxOutcomes {
+					result.Truncated = true
+					break branches
+				}
+				branch := make([]int, k, k+1)
+				copy(branch, outcome.Choices[:k])
+				pending = append(pending, append(branch, alt))
+			}
+		}
+	}
+
+	return result
+}
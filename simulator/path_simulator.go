@@ -2,6 +2,7 @@ package simulator
 
 import (
 	"fmt"
+	"time"
 	"tweego-editor/formats"
 	"tweego-editor/parser"
 )
@@ -12,6 +13,11 @@ type PathSimulator struct {
 	format          formats.StoryFormat
 	visitedPassages map[string]int
 	history         []string
+	debug           bool              // Esegue anche i passaggi debug-startup/header/footer
+	passages        map[string]string // Contenuto dei passaggi per (display:)
+	seed            int64             // Seed delle scelte casuali, se hasSeed
+	hasSeed         bool
+	random          formats.Random // Generatore della simulazione in corso
 }
 
 // VariableChange rappresenta il cambiamento di una variabile
@@ -25,8 +31,12 @@ type VariableChange struct {
 // StepResult risultato di un singolo step
 type StepResult struct {
 	PassageTitle   string                    `json:"passage_title"`
-	PassageIndex   int                       `json:"passage_index"`
+	PassageIndex   int                       `json:"passage_index"`    // Posizione nel path (per gli step forzati, quella dello step di partenza)
+	Forced         bool                      `json:"forced,omitempty"` // Raggiunto con (goto:) invece che con un link
+	File           string                    `json:"file,omitempty"`
+	Line           int                       `json:"line,omitempty"`
 	Changes        map[string]VariableChange `json:"changes"`
+	TempVariables  map[string]interface{}    `json:"temp_variables,omitempty"` // Variabili temporanee (_nome) alla fine del passaggio
 	Warnings       []string                  `json:"warnings,omitempty"`
 	AvailableLinks []string                  `json:"available_links"`
 }
@@ -39,6 +49,7 @@ type SimulationResult struct {
 	FinalState    map[string]interface{} `json:"final_state"`
 	Errors        []string               `json:"errors,omitempty"`
 	TotalWarnings int                    `json:"total_warnings"`
+	Seed          int64                  `json:"seed,omitempty"` // Seed che riproduce le scelte casuali della simulazione
 }
 
 // NewPathSimulator crea un nuovo simulatore
@@ -53,56 +64,126 @@ func NewPathSimulator(story *parser.Story) *PathSimulator {
 		format = formats.GetRegisteredFormat("harlowe")
 	}
 
+	passages := make(map[string]string, len(story.Passages))
+	for title, passage := range story.Passages {
+		passages[title] = passage.Content
+	}
+
 	return &PathSimulator{
 		story:           story,
 		format:          format,
 		visitedPassages: make(map[string]int),
 		history:         []string{},
+		passages:        passages,
+	}
+}
+
+// maxForcedSteps limita i (goto:) consecutivi seguiti dopo uno step, per non ciclare all'infinito
+const maxForcedSteps = 100
+
+// SetDebug abilita i passaggi Harlowe debug-startup, debug-header e debug-footer
+func (ps *PathSimulator) SetDebug(debug bool) {
+	ps.debug = debug
+}
+
+// SetSeed fissa il seed delle scelte casuali: lo stesso seed riproduce la stessa simulazione
+// Senza seed ogni simulazione ne usa uno nuovo, riportato in SimulationResult.Seed
+func (ps *PathSimulator) SetSeed(seed int64) {
+	ps.seed, ps.hasSeed = seed, true
+}
+
+// newRandom crea il generatore per una simulazione e restituisce il suo seed
+func (ps *PathSimulator) newRandom() int64 {
+	seed := ps.seed
+	if !ps.hasSeed {
+		seed = time.Now().UnixNano()
 	}
+	ps.random = formats.NewSeededRandom(seed)
+	return seed
+}
+
+// passageLinks restituisce i link visibili in un passaggio
+// Header e footer vengono mostrati insieme al passaggio, quindi i loro link contano
+func (ps *PathSimulator) passageLinks(passage *parser.Passage) []string {
+	links := []string{}
+	seen := map[string]bool{}
+
+	sources := ps.story.HeaderPassages(ps.debug)
+	sources = append(sources, passage)
+	sources = append(sources, ps.story.FooterPassages(ps.debug)...)
+
+	for _, source := range sources {
+		for _, link := range ps.format.ParseLinks(source.Content) {
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+
+	return links
 }
 
 // ValidatePath verifica che il path sia valido
+// I link vengono controllati simulando il percorso, quindi un link in un hook nascosto
+// nello stato del momento non basta per passare al passaggio successivo
 func (ps *PathSimulator) ValidatePath(path []string) []string {
+	ps.newRandom()
+	errors := ps.validatePassages(path)
+	_, linkErrors := ps.simulate(path)
+	return append(errors, linkErrors...)
+}
+
+// validatePassages verifica che i passaggi del path esistano e siano visitabili
+func (ps *PathSimulator) validatePassages(path []string) []string {
 	errors := []string{}
 
 	for i, passageTitle := range path {
-		if _, exists := ps.story.Passages[passageTitle]; !exists {
+		passage, exists := ps.story.Passages[passageTitle]
+		if !exists {
 			errors = append(errors, fmt.Sprintf("Step %d: passaggio '%s' non esiste", i+1, passageTitle))
+		} else if !passage.IsNavigable() {
+			errors = append(errors, fmt.Sprintf("Step %d: '%s' è un passaggio speciale (%s) e non può essere visitato", i+1, passageTitle, passage.Kind()))
 		}
 	}
 
-	for i := 0; i < len(path)-1; i++ {
-		currentTitle := path[i]
-		nextTitle := path[i+1]
-
-		passage, exists := ps.story.Passages[currentTitle]
-		if !exists {
-			continue
-		}
-
-		links := ps.format.ParseLinks(passage.Content)
+	return errors
+}
 
-		linked := false
-		for _, link := range links {
-			if link == nextTitle {
-				linked = true
-				break
-			}
+// linkError descrive perché dal passaggio dello step non si può andare a nextTitle
+// Restituisce "" se tra i link disponibili c'è nextTitle
+func linkError(step StepResult, nextTitle string, hidden []formats.ResolvedLink) string {
+	for _, link := range step.AvailableLinks {
+		if link == nextTitle {
+			return ""
 		}
+	}
 
-		if !linked {
-			errors = append(errors, fmt.Sprintf(
-				"Step %d→%d: '%s' non ha un link diretto a '%s'. Link disponibili: %v",
-				i+1, i+2, currentTitle, nextTitle, links,
-			))
+	for _, link := range hidden {
+		if link.Target == nextTitle {
+			return fmt.Sprintf(
+				"Step %d→%d: '%s' ha un link a '%s' ma è in un hook nascosto da %s",
+				step.PassageIndex, step.PassageIndex+1, step.PassageTitle, nextTitle, link.HiddenBy,
+			)
 		}
 	}
 
-	return errors
+	return fmt.Sprintf(
+		"Step %d→%d: '%s' non ha un link diretto a '%s'. Link disponibili: %v",
+		step.PassageIndex, step.PassageIndex+1, step.PassageTitle, nextTitle, step.AvailableLinks,
+	)
 }
 
 // SimulatePath simula l'esecuzione di un percorso
 func (ps *PathSimulator) SimulatePath(path []string) *SimulationResult {
+	seed := ps.newRandom()
+	result := ps.simulatePath(path)
+	result.Seed = seed
+	return result
+}
+
+// simulatePath simula un percorso con il generatore casuale già impostato
+func (ps *PathSimulator) simulatePath(path []string) *SimulationResult {
 	result := &SimulationResult{
 		Success:    true,
 		Path:       path,
@@ -111,13 +192,37 @@ func (ps *PathSimulator) SimulatePath(path []string) *SimulationResult {
 		Errors:     []string{},
 	}
 
-	validationErrors := ps.ValidatePath(path)
+	validationErrors := ps.validatePassages(path)
 	if len(validationErrors) > 0 {
 		result.Success = false
 		result.Errors = validationErrors
 		return result
 	}
 
+	simulated, linkErrors := ps.simulate(path)
+	if len(linkErrors) > 0 {
+		result.Success = false
+		result.Errors = linkErrors
+		return result
+	}
+
+	result.Steps = simulated.Steps
+	result.FinalState = simulated.FinalState
+	result.TotalWarnings = simulated.TotalWarnings
+	return result
+}
+
+// simulate esegue i passaggi del percorso e controlla i link tra uno step e il successivo
+// I (goto:) vengono seguiti automaticamente con step forzati, a meno che il path non elenchi
+// già il passaggio di destinazione. I passaggi che non esistono vengono saltati;
+// gli errori dei link vengono restituiti a parte
+func (ps *PathSimulator) simulate(path []string) (*SimulationResult, []string) {
+	result := &SimulationResult{
+		Steps:      []StepResult{},
+		FinalState: make(map[string]interface{}),
+	}
+	linkErrors := []string{}
+
 	// Reset history e visited
 	ps.visitedPassages = make(map[string]int)
 	ps.history = []string{}
@@ -132,77 +237,163 @@ func (ps *PathSimulator) SimulatePath(path []string) *SimulationResult {
 			continue
 		}
 
-		// 1. Aggiorna history e visited
-		ps.visitedPassages[passageTitle]++
-		ps.history = append(ps.history, passageTitle)
+		step, state, hidden, gotoTarget := ps.runStep(passage, i+1, currentState, i == 0)
+		result.Steps = append(result.Steps, step)
+		currentState = state
+
+		// Segue i (goto:) finché non si arriva a un passaggio che aspetta il giocatore
+		for forced := 0; gotoTarget != ""; forced++ {
+			if i < len(path)-1 && path[i+1] == gotoTarget {
+				break
+			}
+			target, exists := ps.story.Passages[gotoTarget]
+			if !exists {
+				linkErrors = append(linkErrors, fmt.Sprintf("Step %d: '%s' esegue (goto:) verso '%s', che non esiste", i+1, step.PassageTitle, gotoTarget))
+				break
+			}
+			if forced == maxForcedSteps {
+				linkErrors = append(linkErrors, fmt.Sprintf("Step %d: più di %d (goto:) consecutivi a partire da '%s'", i+1, maxForcedSteps, passageTitle))
+				break
+			}
 
-		stepResult := StepResult{
-			PassageTitle:   passageTitle,
-			PassageIndex:   i + 1,
-			Changes:        make(map[string]VariableChange),
-			Warnings:       []string{},
-			AvailableLinks: ps.format.ParseLinks(passage.Content),
+			step, currentState, hidden, gotoTarget = ps.runStep(target, i+1, currentState, false)
+			step.Forced = true
+			result.Steps = append(result.Steps, step)
 		}
 
-		// 2. Salva stato PRIMA del processing
-		stateBefore := ps.copyState(currentState)
+		// Verifica che il passaggio successivo sia raggiungibile dall'ultimo passaggio mostrato
+		if i < len(path)-1 && gotoTarget != path[i+1] {
+			if err := linkError(step, path[i+1], hidden); err != "" {
+				linkErrors = append(linkErrors, err)
+			}
+		}
+	}
 
-		// 3. Crea evaluator con lo stato corrente
-		eval := ps.format.CreateEvaluator(currentState)
-		eval.SetVisitedPassages(ps.visitedPassages)
-		eval.SetHistory(ps.history)
-		eval.SetCurrentPassage(passageTitle)
+	result.FinalState = currentState
+	for _, step := range result.Steps {
+		result.TotalWarnings += len(step.Warnings)
+	}
 
-		// 4. CHIAVE: Processa il contenuto usando il formato
-		//    Questo modifica lo stato dell'evaluator
-		if err := ps.format.ProcessPassageContent(passage.Content, eval); err != nil {
-			// Log error ma continua
-			fmt.Printf("⚠️  Warning processing passage %s: %v\n", passageTitle, err)
-		}
+	return result, linkErrors
+}
 
-		// 5. Ottieni il nuovo stato dall'evaluator
-		newState := eval.GetState()
+// runStep esegue un passaggio partendo da state
+// Restituisce lo step, il nuovo stato, i link nascosti e il target di un (goto:) eseguito ("" se non c'è)
+func (ps *PathSimulator) runStep(passage *parser.Passage, index int, state map[string]interface{}, first bool) (StepResult, map[string]interface{}, []formats.ResolvedLink, string) {
+	// 1. Aggiorna history e visited
+	ps.visitedPassages[passage.Title]++
+	ps.history = append(ps.history, passage.Title)
+
+	stepResult := StepResult{
+		PassageTitle:   passage.Title,
+		PassageIndex:   index,
+		File:           passage.File,
+		Line:           passage.Header.Line,
+		Changes:        make(map[string]VariableChange),
+		Warnings:       []string{},
+		AvailableLinks: []string{},
+	}
 
-		// 6. Calcola i cambiamenti
-		for varName, newValue := range newState {
-			previousValue, existed := stateBefore[varName]
+	// 2. Salva stato PRIMA del processing
+	stateBefore := ps.copyState(state)
+
+	// 3. Crea evaluator con lo stato corrente
+	eval := ps.format.CreateEvaluator(state)
+	eval.SetVisitedPassages(ps.visitedPassages)
+	eval.SetHistory(ps.history)
+	eval.SetCurrentPassage(passage.Title)
+	eval.SetPassages(ps.passages)
+	eval.SetRandom(ps.random)
+
+	// 4. CHIAVE: Processa il contenuto usando il formato
+	//    Questo modifica lo stato dell'evaluator e risolve i link con lo stato del momento
+	//    Come in Harlowe: startup (solo al primo passaggio), header, passaggio, footer
+	//    I link dello startup non vengono mostrati insieme al passaggio
+	sources := []*parser.Passage{}
+	if first {
+		sources = append(sources, ps.story.StartupPassages(ps.debug)...)
+	}
+	startupCount := len(sources)
+	sources = append(sources, ps.story.HeaderPassages(ps.debug)...)
+	sources = append(sources, passage)
+	sources = append(sources, ps.story.FooterPassages(ps.debug)...)
+
+	available := []string{}
+	hidden := []formats.ResolvedLink{}
+	gotoTarget := ""
+	processWarnings := []string{}
+	for j, source := range sources {
+		links, err := ps.format.ProcessPassageLinks(source.Content, eval)
+		if err != nil {
+			// L'errore finisce tra i warning del passo e la simulazione continua
+			processWarnings = append(processWarnings, fmt.Sprintf("⚠️ Errore nel passaggio %s (%s:%d): %v",
+				source.Title, source.File, source.Header.Line, err))
+		}
 
-			change := VariableChange{
-				Name:     varName,
-				Previous: previousValue,
-				Current:  newValue,
+		for _, link := range links {
+			switch {
+			case link.Kind == formats.LinkKindDisplay:
+			case !link.Available:
+				hidden = append(hidden, link)
+			case link.Kind == formats.LinkKindGoto:
+				gotoTarget = link.Target
+			case j >= startupCount:
+				available = append(available, link.Target)
 			}
+		}
 
-			if existed {
-				prevNum, prevIsNum := toNumber(previousValue)
-				currNum, currIsNum := toNumber(newValue)
+		// Un (goto:) lascia subito il passaggio: il resto non viene eseguito né mostrato
+		if gotoTarget != "" {
+			available = nil
+			break
+		}
+	}
 
-				if prevIsNum && currIsNum {
-					change.Delta = currNum - prevNum
-				}
-			} else {
-				change.Previous = nil
-			}
+	seen := map[string]bool{}
+	for _, link := range available {
+		if !seen[link] {
+			seen[link] = true
+			stepResult.AvailableLinks = append(stepResult.AvailableLinks, link)
+		}
+	}
+
+	// 5. Ottieni il nuovo stato dall'evaluator
+	//    Le variabili temporanee valgono solo per questo passaggio
+	newState := eval.GetState()
+	stepResult.TempVariables = eval.GetTempState()
 
-			stepResult.Changes[varName] = change
+	// 6. Calcola i cambiamenti
+	for varName, newValue := range newState {
+		previousValue, existed := stateBefore[varName]
+
+		change := VariableChange{
+			Name:     varName,
+			Previous: previousValue,
+			Current:  newValue,
 		}
 
-		// 7. Aggiorna lo stato corrente
-		currentState = newState
+		if existed {
+			prevNum, prevIsNum := toNumber(previousValue)
+			currNum, currIsNum := toNumber(newValue)
 
-		// 8. Genera warnings
-		stepResult.Warnings = ps.generateWarnings(passage, currentState, stepResult.Changes)
-		result.TotalWarnings += len(stepResult.Warnings)
+			if prevIsNum && currIsNum {
+				change.Delta = currNum - prevNum
+			}
+		} else {
+			change.Previous = nil
+		}
 
-		result.Steps = append(result.Steps, stepResult)
+		stepResult.Changes[varName] = change
 	}
 
-	result.FinalState = currentState
+	// 7. Genera warnings, dopo gli errori incontrati processando il contenuto
+	stepResult.Warnings = append(processWarnings, ps.generateWarnings(passage, newState, stepResult.Changes)...)
 
-	return result
+	return stepResult, newState, hidden, gotoTarget
 }
 
-// copyState crea una copia profonda dello stato
+// copyState crea una copia dello stato
+// Basta copiare la mappa: l'evaluator non modifica mai array e datamap, li sostituisce con una copia
 func (ps *PathSimulator) copyState(state map[string]interface{}) map[string]interface{} {
 	copy := make(map[string]interface{})
 	for k, v := range state {
@@ -268,7 +459,7 @@ func (ps *PathSimulator) GetSuggestedPaths(startPassage string, maxDepth int) []
 			continue
 		}
 
-		links := ps.format.ParseLinks(passage.Content)
+		links := ps.passageLinks(passage)
 
 		if len(links) == 0 {
 			paths = append(paths, currentPath)
@@ -283,4 +474,4 @@ func (ps *PathSimulator) GetSuggestedPaths(startPassage string, maxDepth int) []
 	}
 
 	return paths
-}
\ No newline at end of file
+}
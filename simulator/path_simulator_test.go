@@ -0,0 +1,359 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+
+	_ "tweego-editor/formats/harlowe"
+	"tweego-editor/parser"
+)
+
+// parseStory parsa un sorgente in memoria
+func parseStory(t *testing.T, source string) *parser.Story {
+	t.Helper()
+
+	story, err := parser.ParseString(source, "test.twee")
+	if err != nil {
+		t.Fatalf("Error parsing: %v", err)
+	}
+	return story
+}
+
+// ============================================
+// Test: Passaggi speciali nel simulatore
+// ============================================
+
+func TestSimulateStartupHeaderFooter(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Avvio [startup]
+(set: $vita to 100)
+
+:: Menu [header]
+(set: $menu to true)[[Inventario]]
+
+:: Inizio
+(set: $stanza to "inizio")[[Stanza]]
+
+:: Stanza
+(set: $stanza to "stanza")
+
+:: Piede [footer]
+(set: $ultima to $stanza)
+
+:: Inventario
+Zaino
+
+:: Script [script]
+window.x = 1;
+`)
+
+	sim := NewPathSimulator(story)
+	result := sim.SimulatePath([]string{"Inizio", "Stanza", "Inventario"})
+	if !result.Success {
+		t.Fatalf("Expected success, got errors %v", result.Errors)
+	}
+
+	if result.FinalState["vita"] != float64(100) {
+		t.Errorf("Expected vita 100 after startup, got %v", result.FinalState["vita"])
+	}
+	if _, changed := result.Steps[0].Changes["menu"]; !changed {
+		t.Error("Expected header to run with the first passage")
+	}
+	if ultima := result.Steps[1].Changes["ultima"].Current; ultima != "stanza" {
+		t.Errorf("Expected footer to run after the passage, got ultima=%v", ultima)
+	}
+
+	links := result.Steps[0].AvailableLinks
+	if len(links) != 2 || links[0] != "Inventario" || links[1] != "Stanza" {
+		t.Errorf("Expected header and passage links, got %v", links)
+	}
+
+	if errors := sim.ValidatePath([]string{"Inizio", "Script"}); len(errors) == 0 {
+		t.Error("Expected error when visiting a script passage")
+	}
+
+	t.Log("✅ Startup, header and footer passages simulated")
+}
+
+// ============================================
+// Test: Link condizionali
+// ============================================
+
+func TestConditionalLinks(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Ingresso"}
+
+:: Ingresso
+(set: $chiave to false)[[Corridoio]]
+
+:: Corridoio
+(if: $chiave)[[[Porta]]](else:)[[[Ingresso]]]
+(set: $chiave to true)(if: $chiave)[[[Cantina]]]
+
+:: Porta
+Aperta
+
+:: Cantina
+Buio
+`)
+
+	sim := NewPathSimulator(story)
+	result := sim.SimulatePath([]string{"Ingresso", "Corridoio", "Cantina"})
+	if !result.Success {
+		t.Fatalf("Expected success, got errors %v", result.Errors)
+	}
+
+	links := result.Steps[1].AvailableLinks
+	if len(links) != 2 || links[0] != "Ingresso" || links[1] != "Cantina" {
+		t.Errorf("Expected only links in shown hooks, got %v", links)
+	}
+
+	errors := sim.ValidatePath([]string{"Ingresso", "Corridoio", "Porta"})
+	if len(errors) != 1 || !strings.Contains(errors[0], "nascosto") || !strings.Contains(errors[0], "(if: $chiave)") {
+		t.Errorf("Expected hidden link error naming the condition, got %v", errors)
+	}
+
+	errors = sim.ValidatePath([]string{"Ingresso", "Porta"})
+	if len(errors) != 1 || strings.Contains(errors[0], "nascosto") {
+		t.Errorf("Expected missing link error, got %v", errors)
+	}
+
+	t.Log("✅ Links resolved against the simulated state")
+}
+
+func TestLinksInInteractionHooks(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "A"}
+
+:: A
+(link: "Apri la porta")[[[B]]](click: "leva")[(goto: "C")](if: false)[(link: "Entra")[[[D]]]]
+
+:: B
+Stanza
+
+:: C
+Leva
+
+:: D
+Segreto
+`)
+
+	sim := NewPathSimulator(story)
+	if errors := sim.ValidatePath([]string{"A", "B"}); len(errors) != 0 {
+		t.Errorf("Expected link behind (link:) to be reachable, got %v", errors)
+	}
+
+	result := sim.SimulatePath([]string{"A", "C"})
+	if !result.Success {
+		t.Fatalf("Expected (goto:) behind (click:) to be a link, got errors %v", result.Errors)
+	}
+	links := result.Steps[0].AvailableLinks
+	if len(links) != 2 || links[0] != "B" || links[1] != "C" {
+		t.Errorf("Expected links B and C, got %v", links)
+	}
+
+	errors := sim.ValidatePath([]string{"A", "D"})
+	if len(errors) != 1 || !strings.Contains(errors[0], "nascosto da (if: false)") {
+		t.Errorf("Expected link hidden by the (if:), got %v", errors)
+	}
+
+	t.Log("✅ Links behind interaction changers stay reachable")
+}
+
+// ============================================
+// Test: (goto:) e (display:) nel simulatore
+// ============================================
+
+func TestSimulateGotoAndDisplay(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Inizio
+(display: "Statistiche")(link-goto: "Entra", "Atrio")
+
+:: Statistiche
+(set: $vita to 100)
+
+:: Atrio
+(set: $vita to it - 10)(if: $vita < 100)[(goto: "Infermeria")][[Mai]]
+
+:: Infermeria
+(set: $vita to 100)[[Uscita]]
+
+:: Uscita
+Fine
+`)
+
+	sim := NewPathSimulator(story)
+	result := sim.SimulatePath([]string{"Inizio", "Atrio", "Uscita"})
+	if !result.Success {
+		t.Fatalf("Expected success, got errors %v", result.Errors)
+	}
+
+	if len(result.Steps) != 4 || result.Steps[2].PassageTitle != "Infermeria" || !result.Steps[2].Forced {
+		t.Fatalf("Expected a forced step to Infermeria, got %+v", result.Steps)
+	}
+	if len(result.Steps[1].AvailableLinks) != 0 {
+		t.Errorf("Expected no links after (goto:), got %v", result.Steps[1].AvailableLinks)
+	}
+	if result.FinalState["vita"] != float64(100) {
+		t.Errorf("Expected vita 100 after Infermeria, got %v", result.FinalState["vita"])
+	}
+
+	// Il path può anche elencare la destinazione del (goto:)
+	if errors := sim.ValidatePath([]string{"Inizio", "Atrio", "Infermeria", "Uscita"}); len(errors) != 0 {
+		t.Errorf("Expected explicit goto target to be valid, got %v", errors)
+	}
+
+	t.Log("✅ (goto:) followed and (display:) executed in place")
+}
+
+func TestSimulateTempVariables(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Menu [header]
+(set: _turno to (history:)'s length)
+
+:: Inizio
+(set: $oro to 10 * _turno)[[Mercato]]
+
+:: Mercato
+(set: _prezzo to 3)(set: $oro to it - _prezzo)
+`)
+
+	result := NewPathSimulator(story).SimulatePath([]string{"Inizio", "Mercato"})
+	if !result.Success {
+		t.Fatalf("Expected success, got errors %v", result.Errors)
+	}
+
+	if result.FinalState["oro"] != float64(7) {
+		t.Errorf("Expected oro 7 using header temp variable, got %v", result.FinalState["oro"])
+	}
+	if temps := result.Steps[1].TempVariables; temps["prezzo"] != float64(3) || temps["turno"] != float64(2) {
+		t.Errorf("Expected temp variables of the second step, got %v", temps)
+	}
+	if _, exists := result.FinalState["prezzo"]; exists {
+		t.Error("Expected temp variables not to be part of the story state")
+	}
+
+	t.Log("✅ Temp variables reported separately for each step")
+}
+
+func TestSimulateReportsProcessingErrors(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Inizio
+(set: $oro to "3" * 2)[[Fine]]
+
+:: Fine
+Fine.
+`)
+
+	result := NewPathSimulator(story).SimulatePath([]string{"Inizio", "Fine"})
+	if !result.Success {
+		t.Fatalf("Expected success, got errors %v", result.Errors)
+	}
+
+	warnings := result.Steps[0].Warnings
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Inizio") || !strings.Contains(warnings[0], "multiply") {
+		t.Errorf("Expected the processing error among the step warnings, got %v", warnings)
+	}
+	if result.TotalWarnings != 1 {
+		t.Errorf("Expected 1 warning in total, got %d", result.TotalWarnings)
+	}
+
+	t.Log("✅ Processing errors are reported as step warnings")
+}
+
+// ============================================
+// Test: Scelte casuali riproducibili ed enumerate
+// ============================================
+
+const randomStory = `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Inizio
+(set: $moneta to (either: "testa", "croce"))(set: $dado to (random: 1, 3))[[Fine]]
+
+:: Fine
+(if: $moneta is "testa")[(set: $premio to $dado * 10)]
+`
+
+func TestSimulateSeedIsReproducible(t *testing.T) {
+	story := parseStory(t, randomStory)
+
+	sim := NewPathSimulator(story)
+	first := sim.SimulatePath([]string{"Inizio", "Fine"})
+
+	again := NewPathSimulator(story)
+	again.SetSeed(first.Seed)
+	second := again.SimulatePath([]string{"Inizio", "Fine"})
+
+	if second.Seed != first.Seed {
+		t.Fatalf("Expected seed %d to be reported, got %d", first.Seed, second.Seed)
+	}
+	for _, name := range []string{"moneta", "dado", "premio"} {
+		if first.FinalState[name] != second.FinalState[name] {
+			t.Errorf("Expected same $%s with the same seed, got %v and %v", name, first.FinalState[name], second.FinalState[name])
+		}
+	}
+
+	t.Log("✅ Same seed reproduces the same playthrough")
+}
+
+func TestEnumerateOutcomes(t *testing.T) {
+	story := parseStory(t, randomStory)
+
+	result := NewPathSimulator(story).EnumerateOutcomes([]string{"Inizio", "Fine"})
+	if len(result.Outcomes) != 6 || result.Truncated {
+		t.Fatalf("Expected 6 outcomes, got %d (truncated %v)", len(result.Outcomes), result.Truncated)
+	}
+
+	total := 0.0
+	prizes := map[interface{}]float64{}
+	for _, outcome := range result.Outcomes {
+		if !outcome.Result.Success {
+			t.Errorf("Expected every branch to succeed, got %v", outcome.Result.Errors)
+		}
+		total += outcome.Probability
+		prizes[outcome.Result.FinalState["premio"]] += outcome.Probability
+	}
+
+	if total < 0.999 || total > 1.001 {
+		t.Errorf("Expected probabilities to sum to 1, got %v", total)
+	}
+	if p := prizes[nil]; p < 0.499 || p > 0.501 {
+		t.Errorf("Expected no prize with probability 0.5, got %v", p)
+	}
+	if p := prizes[float64(30)]; p < 0.166 || p > 0.167 {
+		t.Errorf("Expected prize 30 with probability 1/6, got %v", p)
+	}
+
+	t.Log("✅ Random choices enumerated with probabilities")
+}
+
+func TestEnumerateOutcomesLimitsPendingBranches(t *testing.T) {
+	story := parseStory(t, `:: StoryData
+{"format": "Harlowe", "start": "Inizio"}
+
+:: Inizio
+(set: $n to (random: 1, 5000000))(set: $m to (random: 1, 5000000))[[Fine]]
+
+:: Fine
+Fine
+`)
+
+	result := NewPathSimulator(story).EnumerateOutcomes([]string{"Inizio", "Fine"})
+	if !result.Truncated {
+		t.Errorf("Expected enumeration to be truncated")
+	}
+	if len(result.Outcomes) != maxOutcomes {
+		t.Errorf("Expected %d outcomes, got %d", maxOutcomes, len(result.Outcomes))
+	}
+
+	t.Log("✅ Huge random ranges stop at the outcome limit")
+}
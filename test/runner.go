@@ -44,6 +44,7 @@ type StoryOutput struct {
 // PassageOutput output di un singolo passaggio
 type PassageOutput struct {
 	Title     string                  `json:"title"`
+	Kind      parser.PassageKind      `json:"kind"`
 	Tags      []string                `json:"tags"`
 	Content   string                  `json:"content"`
 	Links     []string                `json:"links"`
@@ -273,11 +274,23 @@ func (tr *TestRunner) parseFile(filePath string) *ParsedOutput {
 
 	// Processa ogni passaggio usando il parser del formato
 	for title, passage := range story.Passages {
+		// StoryTitle, StoryData, script e stylesheet non contengono codice del formato
+		if !passage.IsNavigable() {
+			storyOutput.Passages[title] = &PassageOutput{
+				Title:   passage.Title,
+				Kind:    passage.Kind(),
+				Tags:    passage.Tags,
+				Content: passage.Content,
+			}
+			continue
+		}
+
 		// Estrai literals usando il metodo del parser (tutta la logica è nel formato!)
 		literals := tr.formatParser.ExtractAllLiterals(passage.Content)
 
 		passageOutput := &PassageOutput{
 			Title:     passage.Title,
+			Kind:      passage.Kind(),
 			Tags:      passage.Tags,
 			Content:   passage.Content,
 			Links:     tr.formatParser.ParseLinks(passage.Content),
@@ -3,8 +3,10 @@ package watcher
 import (
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -22,13 +24,18 @@ type FileWatcher struct {
 	eventChan     chan WatchEvent
 	stopChan      chan bool
 	isRunning     bool
+	project       bool
+	projectRoots  []string
+	parsers       map[string]*parser.IncrementalParser // Storie dei file monitorati
+	parsersMutex  sync.Mutex
 }
 
 // WatchEvent rappresenta un evento del watcher
 type WatchEvent struct {
-	Type      string    // "created", "modified", "deleted", "renamed"
-	Path      string    // Path del file
-	Timestamp time.Time // Quando è successo
+	Type      string            // "created", "modified", "deleted", "renamed", "passages_changed", ...
+	Path      string            // Path del file
+	Timestamp time.Time         // Quando è successo
+	Changes   *parser.ChangeSet // Passaggi cambiati (solo per "passages_changed")
 }
 
 // WatcherConfig configurazione per il watcher
@@ -39,6 +46,7 @@ type WatcherConfig struct {
 	DebounceTime  time.Duration             // Tempo di debounce (default: 500ms)
 	OnEvent       func(WatchEvent)          // Callback per eventi
 	AutoCompile   bool                      // Compila automaticamente (default: true)
+	Project       bool                      // Tratta i path come un unico progetto multi-file
 }
 
 // NewFileWatcher crea un nuovo file watcher
@@ -62,19 +70,67 @@ func NewFileWatcher(config WatcherConfig) (*FileWatcher, error) {
 		eventChan:    make(chan WatchEvent, 100),
 		stopChan:     make(chan bool),
 		isRunning:    false,
+		project:      config.Project,
+		projectRoots: append([]string{}, config.Paths...),
+		parsers:      make(map[string]*parser.IncrementalParser),
 	}
 
-	// Aggiungi i path da monitorare
+	// Aggiungi i path da monitorare (le directory ricorsivamente)
 	for _, path := range config.Paths {
-		if err := watcher.Add(path); err != nil {
+		if err := fw.addRecursive(path); err != nil {
 			return nil, fmt.Errorf("errore aggiunta path %s: %w", path, err)
 		}
-		log.Printf("👀 Watching: %s", path)
+	}
+
+	// Parsing iniziale: le modifiche successive riportano solo i passaggi cambiati
+	if files, err := parser.NewProjectLoader(config.Paths...).Files(); err == nil {
+		for _, file := range files {
+			ip := parser.NewIncrementalParser(file)
+			if _, err := ip.Load(); err != nil {
+				log.Printf("⚠️  Parsing iniziale fallito per %s: %v", filepath.Base(file), err)
+			}
+			fw.parsers[file] = ip
+		}
 	}
 
 	return fw, nil
 }
 
+// addRecursive aggiunge un path al watcher, incluse tutte le sottodirectory
+// fsnotify non è ricorsivo, quindi ogni directory va registrata singolarmente
+func (fw *FileWatcher) addRecursive(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if err := fw.watcher.Add(path); err != nil {
+			return err
+		}
+		log.Printf("👀 Watching: %s", path)
+		return nil
+	}
+
+	return filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		// Salta directory nascoste (.git, .vscode, ...)
+		if p != path && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := fw.watcher.Add(p); err != nil {
+			return err
+		}
+		log.Printf("👀 Watching: %s", p)
+		return nil
+	})
+}
+
 // Start avvia il file watcher
 func (fw *FileWatcher) Start() error {
 	if fw.isRunning {
@@ -95,8 +151,18 @@ func (fw *FileWatcher) Start() error {
 					return
 				}
 
-				// Ignora file non .twee
-				if !strings.HasSuffix(event.Name, ".twee") {
+				// Nuove directory vengono monitorate anch'esse
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := fw.addRecursive(event.Name); err != nil {
+							log.Printf("❌ Errore aggiunta directory %s: %v", event.Name, err)
+						}
+						continue
+					}
+				}
+
+				// Ignora file non .twee/.tw
+				if !parser.IsTweeFile(event.Name) {
 					continue
 				}
 
@@ -132,6 +198,9 @@ func (fw *FileWatcher) Start() error {
 				}
 
 				debounceMap[event.Name] = time.AfterFunc(fw.debounceTime, func() {
+					// Riparsa i passaggi cambiati e notificali
+					fw.reparse(event.Name, eventType)
+
 					// Auto-compila se modificato o creato
 					if (eventType == "modified" || eventType == "created") && fw.compiler != nil {
 						fw.recompile(event.Name)
@@ -184,11 +253,11 @@ func (fw *FileWatcher) IsRunning() bool {
 
 // AddPath aggiunge un path da monitorare
 func (fw *FileWatcher) AddPath(path string) error {
-	if err := fw.watcher.Add(path); err != nil {
+	if err := fw.addRecursive(path); err != nil {
 		return fmt.Errorf("errore aggiunta path: %w", err)
 	}
 	fw.watchedPaths = append(fw.watchedPaths, path)
-	log.Printf("👀 Watching: %s", path)
+	fw.projectRoots = append(fw.projectRoots, path)
 	return nil
 }
 
@@ -205,11 +274,67 @@ func (fw *FileWatcher) RemovePath(path string) error {
 			break
 		}
 	}
+	for i, p := range fw.projectRoots {
+		if p == path {
+			fw.projectRoots = append(fw.projectRoots[:i], fw.projectRoots[i+1:]...)
+			break
+		}
+	}
 	
 	log.Printf("👁️  Stopped watching: %s", path)
 	return nil
 }
 
+// reparse aggiorna la storia del file e invia un evento "passages_changed" con il change set
+// Solo i passaggi toccati dalla modifica vengono riparsati; gli errori di validazione
+// sono segnalati da recompile
+func (fw *FileWatcher) reparse(filePath string, eventType string) {
+	fw.parsersMutex.Lock()
+	defer fw.parsersMutex.Unlock()
+
+	ip, exists := fw.parsers[filePath]
+
+	var changes *parser.ChangeSet
+	switch eventType {
+	case "deleted", "renamed":
+		// Il file non esiste più: tutti i suoi passaggi sono rimossi
+		delete(fw.parsers, filePath)
+		if !exists || ip.Story() == nil {
+			return
+		}
+		changes = &parser.ChangeSet{
+			Added:    []string{},
+			Removed:  append([]string{}, ip.Story().Order...),
+			Modified: []string{},
+		}
+	default:
+		if !exists {
+			ip = parser.NewIncrementalParser(filePath)
+			fw.parsers[filePath] = ip
+		}
+
+		var err error
+		if _, changes, err = ip.Reload(); err != nil {
+			log.Printf("❌ Parsing fallito per %s", filepath.Base(filePath))
+			return
+		}
+	}
+
+	if changes.IsEmpty() {
+		return
+	}
+
+	log.Printf("🧩 Passaggi cambiati in %s: +%d -%d ~%d", filepath.Base(filePath),
+		len(changes.Added), len(changes.Removed), len(changes.Modified))
+
+	fw.eventChan <- WatchEvent{
+		Type:      "passages_changed",
+		Path:      filePath,
+		Timestamp: time.Now(),
+		Changes:   changes,
+	}
+}
+
 // recompile ricompila il file quando viene modificato
 func (fw *FileWatcher) recompile(filePath string) {
 	if fw.compiler == nil || fw.compileOpts == nil {
@@ -218,9 +343,14 @@ func (fw *FileWatcher) recompile(filePath string) {
 
 	log.Printf("🔄 Ricompilazione: %s", filepath.Base(filePath))
 	
+	// In modalità progetto si valida e compila l'intero progetto, non il solo file
+	inputs := []string{filePath}
+	if fw.project {
+		inputs = fw.projectRoots
+	}
+
 	// Valida il file prima di compilare
-	tweeParser := parser.NewTweeParser(filePath)
-	validation := tweeParser.Validate()
+	validation := parser.NewProjectLoader(inputs...).Validate()
 	
 	if !validation.Valid {
 		log.Printf("❌ Validazione fallita per %s:", filepath.Base(filePath))
@@ -245,7 +375,7 @@ func (fw *FileWatcher) recompile(filePath string) {
 	}
 	
 	start := time.Now()
-	result, err := fw.compiler.Compile(filePath, fw.compileOpts)
+	result, err := fw.compiler.CompileProject(inputs, fw.compileOpts)
 	elapsed := time.Since(start)
 
 	if err != nil {